@@ -5,6 +5,8 @@ import (
 	pb "final-project-kodzimo-shared/proto"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -17,8 +19,15 @@ func main() {
 		запускаем gRPC сервер в отдельной горутине, чтобы основной поток мог продолжить и запустить HTTP-сервер.
 	*/
 
-	// Создаем соединение с gRPC сервером
-	conn, err := grpc.Dial("hashing-service:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Создаем соединение с gRPC сервером. Вызовы подтверждаются служебным токеном HASHING_ADMIN_TOKEN
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if token := os.Getenv("HASHING_ADMIN_TOKEN"); token != "" {
+		dialOpts = append(dialOpts,
+			grpc.WithUnaryInterceptor(gateway.ServiceTokenInterceptor(token)),
+			grpc.WithStreamInterceptor(gateway.ServiceTokenStreamInterceptor(token)),
+		)
+	}
+	conn, err := grpc.Dial("hashing-service:50051", dialOpts...)
 	if err != nil {
 		log.Fatalf("failed to dial: %v", err)
 	}
@@ -27,13 +36,49 @@ func main() {
 	// Создаем новый Gateway Service
 	gw := &gateway.GatewayService{
 		HashingClient: pb.NewHashingClient(conn),
+		AdminClient:   pb.NewHashingAdminClient(conn),
+		Auth:          gateway.NewAuthenticator(),
+	}
+
+	// Канонический JSON (RFC 8785) для тел с Content-Type: application/json
+	gw.CanonicalJSON, _ = strconv.ParseBool(os.Getenv("CANONICAL_JSON"))
+
+	// Токены клиентов с их пространствами имен и разрешениями
+	if path := os.Getenv("TOKENS_FILE"); path != "" {
+		if err := gw.Auth.LoadFile(path); err != nil {
+			log.Fatalf("failed to load tokens: %v", err)
+		}
+	}
+
+	// Токен администратора открывает доступ к административным обработчикам (например, удалению хешей)
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		gw.Auth.AddToken(token, gateway.Principal{Name: "admin", Permissions: []gateway.Permission{gateway.PermissionAdmin}})
 	}
 
 	// Регистрируем обработчики HTTP
-	http.HandleFunc("/checkhash", gw.CheckHashHandler)
-	http.HandleFunc("/gethash", gw.GetHashHandler)
-	http.HandleFunc("/createhash", gw.CreateHashHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/checkhash", gw.CheckHashHandler)
+	mux.HandleFunc("/verify", gw.VerifyHashHandler)
+	mux.HandleFunc("/gethash", gw.GetHashHandler)
+	mux.HandleFunc("/createhash", gw.CreateHashHandler)
+	mux.HandleFunc("/createhash/stream", gw.CreateHashStreamHandler)
+	mux.HandleFunc("/similar", gw.FindSimilarHandler)
+	mux.HandleFunc("GET /hashes/{hash}/meta", gw.GetHashMetadataHandler)
+	mux.HandleFunc("POST /hashes/{hash}/touch", gw.TouchHashHandler)
+	mux.HandleFunc("POST /hashes/{hash}/release", gw.ReleaseHashHandler)
+	mux.HandleFunc("POST /passwords/hash", gw.HashPasswordHandler)
+	mux.HandleFunc("POST /passwords/verify", gw.VerifyPasswordHandler)
+	mux.HandleFunc("GET /hashes", gw.RequirePermission(gateway.PermissionAdmin, gw.ListHashesHandler))
+	mux.HandleFunc("DELETE /hashes/{hash}", gw.RequirePermission(gateway.PermissionAdmin, gw.DeleteHashHandler))
+	mux.HandleFunc("POST /admin/namespaces", gw.RequirePermission(gateway.PermissionAdmin, gw.CreateNamespaceHandler))
+	mux.HandleFunc("GET /admin/namespaces", gw.RequirePermission(gateway.PermissionAdmin, gw.ListNamespacesHandler))
+	mux.HandleFunc("GET /admin/namespaces/{name}", gw.RequirePermission(gateway.PermissionAdmin, gw.GetNamespaceHandler))
+	mux.HandleFunc("DELETE /admin/namespaces/{name}", gw.RequirePermission(gateway.PermissionAdmin, gw.DeleteNamespaceHandler))
+	mux.HandleFunc("POST /admin/keys", gw.RequirePermission(gateway.PermissionAdmin, gw.CreateKeyHandler))
+	mux.HandleFunc("GET /admin/keys", gw.RequirePermission(gateway.PermissionAdmin, gw.ListKeysHandler))
+	mux.HandleFunc("GET /admin/keys/{name}", gw.RequirePermission(gateway.PermissionAdmin, gw.GetKeyHandler))
+	mux.HandleFunc("POST /admin/keys/{name}/rotate", gw.RequirePermission(gateway.PermissionAdmin, gw.RotateKeyHandler))
 
-	// Запускаем HTTP-сервер
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// Запускаем HTTP-сервер. Каждый запрос проходит аутентификацию, которая определяет его пространство имен
+	log.Fatal(http.ListenAndServe(":8080", gw.Authenticate(mux)))
 }
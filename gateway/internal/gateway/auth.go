@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+/*
+Аутентификация клиентов gateway. Клиент передает токен в заголовке `Authorization: Bearer <токен>`,
+а Authenticator находит по нему учетную запись (Principal) с пространством имен и набором разрешений.
+Обработчик Authenticate оборачивает весь HTTP-сервер: он определяет учетную запись и передает ее
+пространство имен в Hashing Service через gRPC-метаданные. Обработчики, которым нужно разрешение,
+дополнительно оборачиваются в GatewayService.RequirePermission.
+*/
+
+// Permission - разрешение, которое выдается учетной записи.
+type Permission string
+
+// PermissionAdmin разрешает административные операции: удаление и список хешей, управление пространствами имен.
+const PermissionAdmin Permission = "admin"
+
+// Principal - учетная запись клиента, определенная по токену.
+type Principal struct {
+	Name string `json:"name"`
+	// Namespace - пространство имен, в котором выполняются запросы клиента. Пустое - пространство по умолчанию.
+	Namespace   string       `json:"namespace"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Has сообщает, есть ли у учетной записи разрешение permission.
+func (p Principal) Has(permission Permission) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Authenticator хранит токены в виде SHA-256, поэтому поиск по map не раскрывает через время ответа,
+насколько близок переданный токен к настоящему.
+*/
+type Authenticator struct {
+	principals map[[sha256.Size]byte]Principal
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{principals: make(map[[sha256.Size]byte]Principal)}
+}
+
+// AddToken регистрирует токен учетной записи principal.
+func (a *Authenticator) AddToken(token string, principal Principal) {
+	a.principals[sha256.Sum256([]byte(token))] = principal
+}
+
+/*
+LoadFile регистрирует токены из JSON-файла вида
+`[{"token": "...", "name": "team-a", "namespace": "team-a", "permissions": ["admin"]}]`.
+*/
+func (a *Authenticator) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var entries []struct {
+		Token string `json:"token"`
+		Principal
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parse tokens file %s: %w", path, err)
+	}
+
+	for _, entry := range entries {
+		if entry.Token == "" {
+			return fmt.Errorf("tokens file %s: empty token for %q", path, entry.Name)
+		}
+		a.AddToken(entry.Token, entry.Principal)
+	}
+	return nil
+}
+
+// Authenticate возвращает учетную запись по токену из заголовка Authorization.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, false
+	}
+
+	principal, ok := a.principals[sha256.Sum256([]byte(token))]
+	return principal, ok
+}
+
+// Пространство имен передается в Hashing Service в gRPC-метаданных с этим ключом.
+const namespaceMetadataKey = "x-hash-namespace"
+
+// Служебный токен передается в Hashing Service в gRPC-метаданных с этим ключом.
+const authorizationMetadataKey = "authorization"
+
+/*
+ServiceTokenInterceptor и ServiceTokenStreamInterceptor добавляют служебный токен gateway к исходящим вызовам
+Hashing Service. Hashing Service выполняет административные методы и учитывает пространство имен клиента
+только с этим токеном, а gateway вызывает административные методы лишь после проверки разрешения клиента
+(см. RequirePermission).
+*/
+
+func ServiceTokenInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, authorizationMetadataKey, "Bearer "+token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func ServiceTokenStreamInterceptor(token string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, authorizationMetadataKey, "Bearer "+token)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// namespaceHeader позволяет администратору выполнить запрос в другом пространстве имен.
+const namespaceHeader = "X-Namespace"
+
+type principalContextKey struct{}
+
+/*
+Authenticate определяет учетную запись клиента и передает ее пространство имен в gRPC-метаданных исходящих
+вызовов. Запросы без заголовка Authorization выполняются анонимно в пространстве по умолчанию, с неизвестным
+токеном - отклоняются с 401. Администратор может указать пространство заголовком X-Namespace.
+*/
+func (g *GatewayService) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var principal Principal
+
+		if r.Header.Get("Authorization") != "" {
+			var ok bool
+			if g.Auth != nil {
+				principal, ok = g.Auth.Authenticate(r)
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			ctx = context.WithValue(ctx, principalContextKey{}, principal)
+		}
+
+		namespace := principal.Namespace
+		if override := r.Header.Get(namespaceHeader); override != "" {
+			if !principal.Has(PermissionAdmin) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			namespace = override
+		}
+		if namespace != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, namespaceMetadataKey, namespace)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+RequirePermission пропускает запрос к next, только если Authenticate определил учетную запись с разрешением
+permission. Без учетной записи возвращается 401, без разрешения - 403.
+*/
+func (g *GatewayService) RequirePermission(permission Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := r.Context().Value(principalContextKey{}).(Principal)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !principal.Has(permission) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	pb "final-project-kodzimo-shared/proto"
+)
+
+/*
+Администрирование пространств имен и ключей хеширования. Обработчики вызывают сервис HashingAdmin и регистрируются
+с разрешением PermissionAdmin:
+
+```http
+POST /admin/namespaces HTTP/1.1
+Host: localhost:8080
+Authorization: Bearer <токен администратора>
+Content-Type: application/json
+
+{"name": "team-a", "max_hashes": 100000, "max_payload_size": 1048576}
+```
+
+GET /admin/namespaces возвращает список пространств, GET /admin/namespaces/{name} - пространство
+со статистикой, DELETE /admin/namespaces/{name} удаляет пространство вместе со всеми его хешами.
+
+Ключи хеширования (для хешей с ключом, параметр key): POST /admin/keys с телом {"name": "emails"} создает
+ключ, POST /admin/keys/{name}/rotate добавляет ему новую версию, GET /admin/keys и GET /admin/keys/{name}
+возвращают ключи с версиями. Секреты ключей сервис не возвращает.
+*/
+
+// NamespaceResult - JSON-представление пространства имен.
+type NamespaceResult struct {
+	Name           string                `json:"name"`
+	CreatedAt      *time.Time            `json:"created_at,omitempty"`
+	MaxHashes      int64                 `json:"max_hashes"`
+	MaxPayloadSize int64                 `json:"max_payload_size"`
+	Stats          *NamespaceStatsResult `json:"stats,omitempty"`
+}
+
+type NamespaceStatsResult struct {
+	Hashes    int64 `json:"hashes"`
+	TotalSize int64 `json:"total_size"`
+	ReadCount int64 `json:"read_count"`
+}
+
+func newNamespaceResult(namespace *pb.Namespace) NamespaceResult {
+	result := NamespaceResult{
+		Name:           namespace.Name,
+		CreatedAt:      optionalTime(namespace.CreatedAt),
+		MaxHashes:      namespace.MaxHashes,
+		MaxPayloadSize: namespace.MaxPayloadSize,
+	}
+	if stats := namespace.Stats; stats != nil {
+		result.Stats = &NamespaceStatsResult{
+			Hashes:    stats.Hashes,
+			TotalSize: stats.TotalSize,
+			ReadCount: stats.ReadCount,
+		}
+	}
+	return result
+}
+
+// CreateNamespaceRequest - JSON-тело запроса CreateNamespaceHandler.
+type CreateNamespaceRequest struct {
+	Name           string `json:"name"`
+	MaxHashes      int64  `json:"max_hashes"`
+	MaxPayloadSize int64  `json:"max_payload_size"`
+}
+
+func (g *GatewayService) CreateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	var body CreateNamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := g.AdminClient.CreateNamespace(r.Context(), &pb.CreateNamespaceRequest{
+		Name:           body.Name,
+		MaxHashes:      body.MaxHashes,
+		MaxPayloadSize: body.MaxPayloadSize,
+	})
+	if err != nil {
+		writeGrpcError(w, "CreateNamespace", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newNamespaceResult(res))
+}
+
+func (g *GatewayService) GetNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.GetNamespace(r.Context(), &pb.GetNamespaceRequest{Name: r.PathValue("name")})
+	if err != nil {
+		writeGrpcError(w, "GetNamespace", err)
+		return
+	}
+
+	writeJSON(w, newNamespaceResult(res))
+}
+
+func (g *GatewayService) ListNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.ListNamespaces(r.Context(), &pb.ListNamespacesRequest{})
+	if err != nil {
+		writeGrpcError(w, "ListNamespaces", err)
+		return
+	}
+
+	namespaces := make([]NamespaceResult, 0, len(res.Namespaces))
+	for _, namespace := range res.Namespaces {
+		namespaces = append(namespaces, newNamespaceResult(namespace))
+	}
+	writeJSON(w, map[string][]NamespaceResult{"namespaces": namespaces})
+}
+
+func (g *GatewayService) DeleteNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.DeleteNamespace(r.Context(), &pb.DeleteNamespaceRequest{Name: r.PathValue("name")})
+	if err != nil {
+		writeGrpcError(w, "DeleteNamespace", err)
+		return
+	}
+
+	writeJSON(w, map[string]any{"name": res.Name, "deleted_hashes": res.DeletedHashes})
+}
+
+// KeyResult - JSON-представление ключа хеширования (без секретов).
+type KeyResult struct {
+	Name           string             `json:"name"`
+	CreatedAt      *time.Time         `json:"created_at,omitempty"`
+	CurrentVersion int64              `json:"current_version"`
+	Versions       []KeyVersionResult `json:"versions"`
+}
+
+type KeyVersionResult struct {
+	Version   int64      `json:"version"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+func newKeyResult(key *pb.Key) KeyResult {
+	result := KeyResult{
+		Name:           key.Name,
+		CreatedAt:      optionalTime(key.CreatedAt),
+		CurrentVersion: key.CurrentVersion,
+		Versions:       make([]KeyVersionResult, 0, len(key.Versions)),
+	}
+	for _, version := range key.Versions {
+		result.Versions = append(result.Versions, KeyVersionResult{
+			Version:   version.Version,
+			CreatedAt: optionalTime(version.CreatedAt),
+		})
+	}
+	return result
+}
+
+// CreateKeyRequest - JSON-тело запроса CreateKeyHandler.
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+}
+
+func (g *GatewayService) CreateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var body CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := g.AdminClient.CreateKey(r.Context(), &pb.CreateKeyRequest{Name: body.Name})
+	if err != nil {
+		writeGrpcError(w, "CreateKey", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newKeyResult(res))
+}
+
+func (g *GatewayService) RotateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.RotateKey(r.Context(), &pb.RotateKeyRequest{Name: r.PathValue("name")})
+	if err != nil {
+		writeGrpcError(w, "RotateKey", err)
+		return
+	}
+
+	writeJSON(w, newKeyResult(res))
+}
+
+func (g *GatewayService) GetKeyHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.GetKey(r.Context(), &pb.GetKeyRequest{Name: r.PathValue("name")})
+	if err != nil {
+		writeGrpcError(w, "GetKey", err)
+		return
+	}
+
+	writeJSON(w, newKeyResult(res))
+}
+
+func (g *GatewayService) ListKeysHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := g.AdminClient.ListKeys(r.Context(), &pb.ListKeysRequest{})
+	if err != nil {
+		writeGrpcError(w, "ListKeys", err)
+		return
+	}
+
+	keys := make([]KeyResult, 0, len(res.Keys))
+	for _, key := range res.Keys {
+		keys = append(keys, newKeyResult(key))
+	}
+	writeJSON(w, map[string][]KeyResult{"keys": keys})
+}
@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	pb "final-project-kodzimo-shared/proto"
+)
+
+/*
+Хеширование паролей. Пароль передается в JSON-теле запроса, а не в query-параметрах, чтобы он не попадал
+в логи прокси и историю браузера. Ни gateway, ни Hashing Service пароли и их хеши не сохраняют.
+
+```http
+POST /passwords/hash HTTP/1.1
+Host: localhost:8080
+Content-Type: application/json
+
+{"password": "correct horse battery staple", "algorithm": "argon2id", "params": {"memory": 65536, "iterations": 3}}
+```
+
+Алгоритм (argon2id, bcrypt или scrypt) и параметры необязательны: по умолчанию используется политика
+Hashing Service. Ответ - {"hash": "$argon2id$v=19$m=65536,t=3,p=1$...", "algorithm": "argon2id"}.
+
+POST /passwords/verify с телом {"password": "...", "hash": "$argon2id$..."} проверяет пароль и возвращает
+{"valid": true, "needs_rehash": false, "algorithm": "argon2id"}. needs_rehash означает, что хеш стоит
+пересчитать через /passwords/hash, пока пароль известен.
+*/
+
+// PasswordParams - параметры стоимости хеширования пароля; каждый алгоритм использует только свои поля.
+type PasswordParams struct {
+	Memory            uint32 `json:"memory,omitempty"`
+	Iterations        uint32 `json:"iterations,omitempty"`
+	Parallelism       uint32 `json:"parallelism,omitempty"`
+	Cost              uint32 `json:"cost,omitempty"`
+	LogN              uint32 `json:"log_n,omitempty"`
+	BlockSize         uint32 `json:"block_size,omitempty"`
+	ScryptParallelism uint32 `json:"scrypt_parallelism,omitempty"`
+}
+
+// HashPasswordRequest - JSON-тело запроса HashPasswordHandler.
+type HashPasswordRequest struct {
+	Password  string         `json:"password"`
+	Algorithm string         `json:"algorithm"`
+	Params    PasswordParams `json:"params"`
+}
+
+// HashPasswordResult - JSON-ответ обработчика HashPasswordHandler.
+type HashPasswordResult struct {
+	Hash      string `json:"hash"`
+	Algorithm string `json:"algorithm"`
+}
+
+// VerifyPasswordRequest - JSON-тело запроса VerifyPasswordHandler.
+type VerifyPasswordRequest struct {
+	Password string `json:"password"`
+	Hash     string `json:"hash"`
+}
+
+// VerifyPasswordResult - JSON-ответ обработчика VerifyPasswordHandler.
+type VerifyPasswordResult struct {
+	Valid       bool   `json:"valid"`
+	NeedsRehash bool   `json:"needs_rehash"`
+	Algorithm   string `json:"algorithm"`
+}
+
+func (g *GatewayService) HashPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var body HashPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := g.HashingClient.HashPassword(r.Context(), &pb.HashPasswordRequest{
+		Password:  body.Password,
+		Algorithm: body.Algorithm,
+		Params: &pb.PasswordParams{
+			Memory:            body.Params.Memory,
+			Iterations:        body.Params.Iterations,
+			Parallelism:       body.Params.Parallelism,
+			Cost:              body.Params.Cost,
+			LogN:              body.Params.LogN,
+			BlockSize:         body.Params.BlockSize,
+			ScryptParallelism: body.Params.ScryptParallelism,
+		},
+	})
+	if err != nil {
+		writeGrpcError(w, "HashPassword", err)
+		return
+	}
+
+	writeJSON(w, HashPasswordResult{Hash: res.Hash, Algorithm: res.Algorithm})
+}
+
+func (g *GatewayService) VerifyPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var body VerifyPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := g.HashingClient.VerifyPassword(r.Context(), &pb.VerifyPasswordRequest{
+		Password: body.Password,
+		Hash:     body.Hash,
+	})
+	if err != nil {
+		writeGrpcError(w, "VerifyPassword", err)
+		return
+	}
+
+	writeJSON(w, VerifyPasswordResult{Valid: res.Valid, NeedsRehash: res.NeedsRehash, Algorithm: res.Algorithm})
+}
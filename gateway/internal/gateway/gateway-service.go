@@ -1,11 +1,22 @@
 package gateway
 
 import (
-	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 /*
@@ -19,10 +30,294 @@ import (
 
 type GatewayService struct {
 	HashingClient pb.HashingClient
+	AdminClient   pb.HashingAdminClient
+	// Auth проверяет токены клиентов (см. Authenticate и RequirePermission)
+	Auth *Authenticator
+	// CanonicalJSON включает канонический JSON (RFC 8785) для тел с Content-Type: application/json
+	CanonicalJSON bool
+}
+
+// Алгоритм хеширования можно передать query-параметром или заголовком.
+const (
+	algorithmParam  = "algorithm"
+	algorithmHeader = "X-Hash-Algorithm"
+)
+
+// hashHeader - заголовок ответа gethash с полным хешем (запрос мог содержать сокращенный хеш).
+const hashHeader = "X-Hash"
+
+// createdHeader - заголовок ответа createhash: true, если хеш создан, false, если он уже существовал.
+const createdHeader = "X-Hash-Created"
+
+// algorithmFromRequest возвращает алгоритм из запроса. Query-параметр имеет приоритет над заголовком.
+// Пустая строка означает алгоритм по умолчанию, который выбирает Hashing Service.
+func algorithmFromRequest(r *http.Request) string {
+	if algorithm := r.URL.Query().Get(algorithmParam); algorithm != "" {
+		return algorithm
+	}
+	return r.Header.Get(algorithmHeader)
+}
+
+/*
+Кодировка хеша в ответе (hex, base64url, base32, base58 или multibase) передается query-параметром encoding
+или заголовком X-Hash-Encoding. Пустая кодировка означает hex. Хеш в запросе можно передавать в любой из них.
+*/
+const (
+	encodingParam  = "encoding"
+	encodingHeader = "X-Hash-Encoding"
+)
+
+// encodingFromRequest возвращает кодировку хеша из запроса. Query-параметр имеет приоритет над заголовком.
+func encodingFromRequest(r *http.Request) string {
+	if encoding := r.URL.Query().Get(encodingParam); encoding != "" {
+		return encoding
+	}
+	return r.Header.Get(encodingHeader)
+}
+
+// Параметр multihash=true возвращает хеш в формате multihash (код алгоритма, длина и хеш) в выбранной кодировке.
+const multihashParam = "multihash"
+
+// multihashFromRequest возвращает значение параметра multihash; без параметра - false.
+func multihashFromRequest(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get(multihashParam)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
 }
 
 /*
-Конечно, вот пример HTTP POST запроса, который вы можете использовать для тестирования обработчика `CheckHashHandler`:
+Канонический JSON (RFC 8785): Hashing Service хеширует не тело запроса как есть, а его каноническую форму,
+поэтому документы, отличающиеся только порядком ключей и пробелами, получают один хеш. Режим включается
+query-параметром canonical_json=true, а если параметра нет и шлюз настроен с CanonicalJSON - автоматически
+для тел с Content-Type: application/json.
+*/
+const canonicalJSONParam = "canonical_json"
+
+// canonicalJSONFromRequest сообщает, нужно ли хешировать тело запроса как канонический JSON.
+func (g *GatewayService) canonicalJSONFromRequest(r *http.Request) (bool, error) {
+	if value := r.URL.Query().Get(canonicalJSONParam); value != "" {
+		return strconv.ParseBool(value)
+	}
+	if !g.CanonicalJSON {
+		return false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json", nil
+}
+
+/*
+Нормализация текста перед хешированием: normalize=nfc|nfkc задает форму Unicode, crlf=true заменяет CRLF на LF,
+trim=true обрезает пробелы по краям, case_fold=true приводит регистр. Шаги записываются в метаданные хеша
+и возвращаются в JSON-ответах полем normalization, а в GetHashHandler - заголовком X-Hash-Normalization.
+*/
+const (
+	normalizeParam      = "normalize"
+	crlfParam           = "crlf"
+	trimParam           = "trim"
+	caseFoldParam       = "case_fold"
+	normalizationHeader = "X-Hash-Normalization"
+)
+
+// NormalizationResult - шаги нормализации текста в JSON-ответах gateway.
+type NormalizationResult struct {
+	Form     string `json:"form,omitempty"`
+	CRLF     bool   `json:"crlf,omitempty"`
+	Trim     bool   `json:"trim,omitempty"`
+	CaseFold bool   `json:"case_fold,omitempty"`
+}
+
+// normalizationFromRequest возвращает параметры нормализации из запроса; без параметров - nil.
+func normalizationFromRequest(r *http.Request) (*pb.TextNormalization, error) {
+	query := r.URL.Query()
+	normalization := &pb.TextNormalization{Form: query.Get(normalizeParam)}
+	flags := []struct {
+		param string
+		value *bool
+	}{
+		{crlfParam, &normalization.Crlf},
+		{trimParam, &normalization.Trim},
+		{caseFoldParam, &normalization.CaseFold},
+	}
+	for _, flag := range flags {
+		if query.Get(flag.param) == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(query.Get(flag.param))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", flag.param, err)
+		}
+		*flag.value = parsed
+	}
+	if normalization.Form == "" && !normalization.Crlf && !normalization.Trim && !normalization.CaseFold {
+		return nil, nil
+	}
+	return normalization, nil
+}
+
+// newNormalizationResult переводит шаги нормализации в JSON-ответ; nil, если нормализации нет.
+func newNormalizationResult(n *pb.TextNormalization) *NormalizationResult {
+	if n == nil {
+		return nil
+	}
+	return &NormalizationResult{Form: n.Form, CRLF: n.Crlf, Trim: n.Trim, CaseFold: n.CaseFold}
+}
+
+// setNormalizationHeader передает шаги нормализации через запятую в порядке применения.
+func setNormalizationHeader(w http.ResponseWriter, n *pb.TextNormalization) {
+	if n == nil {
+		return
+	}
+	var steps []string
+	if n.Crlf {
+		steps = append(steps, crlfParam)
+	}
+	if n.CaseFold {
+		steps = append(steps, "casefold")
+	}
+	if n.Form != "" {
+		steps = append(steps, n.Form)
+	}
+	if n.Trim {
+		steps = append(steps, trimParam)
+	}
+	w.Header().Set(normalizationHeader, strings.Join(steps, ","))
+}
+
+// Срок жизни хеша передается query-параметром в формате time.ParseDuration, например ttl=24h.
+const ttlParam = "ttl"
+
+/*
+ttlFromRequest возвращает срок жизни из запроса. nil означает, что срок не указан и Hashing Service
+применит свою политику хранения.
+*/
+func ttlFromRequest(r *http.Request) (*durationpb.Duration, error) {
+	value := r.URL.Query().Get(ttlParam)
+	if value == "" {
+		return nil, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	return durationpb.New(ttl), nil
+}
+
+/*
+Ключ хеширования (его создает администратор, см. gateway-admin.go) передается query-параметром key,
+версия ключа - параметром key_version (без него используется текущая версия). В ответах createhash
+и gethash ключ и его версия возвращаются в заголовках X-Hash-Key и X-Hash-Key-Version.
+*/
+const (
+	keyParam         = "key"
+	keyVersionParam  = "key_version"
+	keyHeader        = "X-Hash-Key"
+	keyVersionHeader = "X-Hash-Key-Version"
+)
+
+// keyFromRequest возвращает ключ и версию ключа из запроса. Пустой ключ означает хеш без ключа.
+func keyFromRequest(r *http.Request) (string, int64, error) {
+	key := r.URL.Query().Get(keyParam)
+	value := r.URL.Query().Get(keyVersionParam)
+	if value == "" {
+		return key, 0, nil
+	}
+
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return key, version, nil
+}
+
+// setKeyHeaders передает в заголовках ответа ключ и его версию, если хеш посчитан с ключом.
+func setKeyHeaders(w http.ResponseWriter, key string, version int64) {
+	if key == "" {
+		return
+	}
+	w.Header().Set(keyHeader, key)
+	w.Header().Set(keyVersionHeader, strconv.FormatInt(version, 10))
+}
+
+// Владелец, берущий ссылку на хеш при создании и освобождающий ее через /hashes/{hash}/release.
+const ownerParam = "owner"
+
+// CheckHashResult - JSON-ответ обработчика CheckHashHandler.
+type CheckHashResult struct {
+	Exists     bool       `json:"exists"`
+	Hash       string     `json:"hash"`
+	Algorithm  string     `json:"algorithm"`
+	Key        string     `json:"key,omitempty"`
+	KeyVersion int64      `json:"key_version,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	Deleted    bool       `json:"deleted,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	// Normalization - шаги нормализации, с которыми проверены данные.
+	Normalization *NormalizationResult `json:"normalization,omitempty"`
+}
+
+// writeJSON отправляет клиенту value в формате JSON.
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+/*
+writeGrpcError переводит ошибку gRPC в HTTP-ответ. Ошибки клиента (например, неизвестный алгоритм)
+возвращаются с кодом 4xx и сообщением от Hashing Service, все остальные - как внутренняя ошибка.
+Удаленный хеш, от которого осталось надгробие, возвращается как 410 Gone, а неоднозначный сокращенный
+хеш - как 409 Conflict со списком кандидатов в сообщении. Коллизия хешей при создании (под тем же хешем
+уже сохранены другие данные) и повторное создание пространства имен - тоже 409 Conflict. Превышение квоты
+пространства имен возвращается как 429 Too Many Requests.
+*/
+
+func writeGrpcError(w http.ResponseWriter, method string, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		code = http.StatusBadRequest
+		if errorReason(err) == reasonAmbiguousPrefix {
+			code = http.StatusConflict
+		}
+	case codes.NotFound:
+		code = http.StatusNotFound
+		if errorReason(err) == reasonHashDeleted {
+			code = http.StatusGone
+		}
+	case codes.AlreadyExists:
+		code = http.StatusConflict
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	}
+
+	http.Error(w, "Error calling "+method+": "+status.Convert(err).Message(), code)
+}
+
+// Причины в ErrorInfo, с которыми Hashing Service возвращает ошибки: NotFound для удаленного хеша
+// и InvalidArgument для неоднозначного сокращенного хеша.
+const (
+	reasonHashDeleted     = "HASH_DELETED"
+	reasonAmbiguousPrefix = "AMBIGUOUS_PREFIX"
+)
+
+// errorReason возвращает причину из ErrorInfo в деталях ошибки gRPC или пустую строку.
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+/*
+Конечно, вот пример HTTP POST запроса, который вы можете использовать для тестирования обработчика `CheckHashHandler`.
+В ответ возвращается JSON вида `{"exists":true,"hash":"315f5b...","algorithm":"sha256","created_at":"..."}`,
+поле created_at есть только у существующих хешей:
 
 ```http
 POST /checkhash HTTP/1.1
@@ -52,20 +347,151 @@ func (g *GatewayService) CheckHashHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Создаем и заполняем HashRequest.
+	// Ключ хеширования необязателен.
+	key, keyVersion, err := keyFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid key_version: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	canonicalJSON, err := g.canonicalJSONFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid canonical_json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalization, err := normalizationFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid normalization: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	multihash, err := multihashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Создаем и заполняем HashRequest. Тело передаем как bytes, чтобы бинарные данные не искажались.
 	req := &pb.HashRequest{
-		Payload: string(body),
+		Data:          body,
+		ContentType:   r.Header.Get("Content-Type"),
+		Algorithm:     algorithmFromRequest(r),
+		Key:           key,
+		KeyVersion:    keyVersion,
+		Encoding:      encodingFromRequest(r),
+		Multihash:     multihash,
+		CanonicalJson: canonicalJSON,
+		Normalization: normalization,
 	}
 
 	// Вызываем метод CheckHash на клиенте gRPC.
-	res, err := g.HashingClient.CheckHash(context.Background(), req)
+	res, err := g.HashingClient.CheckHash(r.Context(), req)
 	if err != nil {
-		http.Error(w, "Error calling CheckHash: "+err.Error(), http.StatusInternalServerError)
+		writeGrpcError(w, "CheckHash", err)
 		return
 	}
 
-	// Возвращаем полученный хеш обратно клиенту.
-	w.Write([]byte(res.Hash))
+	// Возвращаем результат проверки клиенту в виде JSON.
+	writeJSON(w, CheckHashResult{
+		Exists:        res.Exists,
+		Hash:          res.Hash,
+		Algorithm:     res.Algorithm,
+		Key:           res.Key,
+		KeyVersion:    res.KeyVersion,
+		CreatedAt:     optionalTime(res.CreatedAt),
+		Deleted:       res.Deleted,
+		DeletedAt:     optionalTime(res.DeletedAt),
+		Normalization: newNormalizationResult(res.Normalization),
+	})
+}
+
+/*
+```http
+POST /verify?hash=sha256:315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3 HTTP/1.1
+Host: localhost:8080
+Content-Type: text/plain
+Content-Length: 13
+
+Hello, world!
+```
+Этот обработчик пересчитывает хеш тела запроса и сравнивает его с ожидаемым хешем из query-параметра hash
+за постоянное время. Хеш можно передать в самоописывающем формате "<алгоритм>:<hex>" или без префикса -
+тогда используется алгоритм из параметра algorithm (по умолчанию SHA-256). Несовпадение - не ошибка:
+ответ {"match": false, "algorithm": "sha256"} возвращается со статусом 200. Хранилище не используется.
+*/
+
+// expectedHashParam - query-параметр VerifyHashHandler с ожидаемым хешем.
+const expectedHashParam = "hash"
+
+// VerifyHashResult - JSON-ответ обработчика VerifyHashHandler.
+type VerifyHashResult struct {
+	Match      bool   `json:"match"`
+	Algorithm  string `json:"algorithm"`
+	Key        string `json:"key,omitempty"`
+	KeyVersion int64  `json:"key_version,omitempty"`
+}
+
+func (g *GatewayService) VerifyHashHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	expected := r.URL.Query().Get(expectedHashParam)
+	if expected == "" {
+		http.Error(w, "Missing hash", http.StatusBadRequest)
+		return
+	}
+
+	// Ключ хеширования необязателен.
+	key, keyVersion, err := keyFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid key_version: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	canonicalJSON, err := g.canonicalJSONFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid canonical_json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalization, err := normalizationFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid normalization: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Извлекаем полезную нагрузку из тела запроса.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	// Вызываем метод VerifyHash на клиенте gRPC.
+	res, err := g.HashingClient.VerifyHash(r.Context(), &pb.VerifyHashRequest{
+		Data:          body,
+		ExpectedHash:  expected,
+		Algorithm:     algorithmFromRequest(r),
+		Key:           key,
+		KeyVersion:    keyVersion,
+		CanonicalJson: canonicalJSON,
+		Normalization: normalization,
+	})
+	if err != nil {
+		writeGrpcError(w, "VerifyHash", err)
+		return
+	}
+
+	writeJSON(w, VerifyHashResult{
+		Match:      res.Match,
+		Algorithm:  res.Algorithm,
+		Key:        res.Key,
+		KeyVersion: res.KeyVersion,
+	})
 }
 
 /*
@@ -78,8 +504,10 @@ Content-Length: 13
 Hello, world!
 ```
 
-Этот обработчик будет принимать HTTP-запрос, извлекать полезную нагрузку из запроса, вызывать метод GetHash
-на клиенте gRPC, а затем отправлять ответ обратно клиенту.
+Этот обработчик будет принимать HTTP-запрос, извлекать хеш из тела запроса, вызывать метод GetHash
+на клиенте gRPC, а затем отправлять клиенту исходные данные с тем Content-Type, с которым они были созданы.
+Вместо полного хеша можно передать его начало (не короче 6 символов), как в git; полный хеш возвращается
+в заголовке X-Hash.
 */
 
 func (g *GatewayService) GetHashHandler(w http.ResponseWriter, r *http.Request) {
@@ -96,25 +524,43 @@ func (g *GatewayService) GetHashHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	multihash, err := multihashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Создаем и заполняем HashRequest.
 	req := &pb.HashRequest{
-		Payload: string(body),
+		Payload:   string(body),
+		Algorithm: algorithmFromRequest(r),
+		Encoding:  encodingFromRequest(r),
+		Multihash: multihash,
 	}
 
 	// Вызываем метод GetHash на клиенте gRPC.
-	res, err := g.HashingClient.GetHash(context.Background(), req)
+	res, err := g.HashingClient.GetHash(r.Context(), req)
 	if err != nil {
-		http.Error(w, "Error calling GetHash: "+err.Error(), http.StatusInternalServerError)
+		writeGrpcError(w, "GetHash", err)
 		return
 	}
 
-	// Возвращаем полученный хеш обратно клиенту.
-	w.Write([]byte(res.Hash))
+	// Возвращаем исходные данные с исходным типом содержимого, полный хеш и имя алгоритма передаем в заголовках.
+	contentType := res.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(algorithmHeader, res.Algorithm)
+	w.Header().Set(hashHeader, res.Hash)
+	setKeyHeaders(w, res.Key, res.KeyVersion)
+	setNormalizationHeader(w, res.Normalization)
+	w.Write(res.Payload)
 }
 
 /*
 ```http
-POST /createhash HTTP/1.1
+POST /createhash?ttl=24h HTTP/1.1
 Host: localhost:8080
 Content-Type: text/plain
 Content-Length: 13
@@ -122,7 +568,9 @@ Content-Length: 13
 Hello, world!
 ```
 Этот обработчик будет принимать HTTP-запрос, извлекать полезную нагрузку из запроса, вызывать метод CreateHash
-на клиенте gRPC, а затем отправлять ответ обратно клиенту.
+на клиенте gRPC, а затем отправлять ответ обратно клиенту. Query-параметр ttl необязателен: без него
+действует политика хранения Hashing Service. С query-параметром owner владелец берет ссылку на хеш,
+а с simhash=true отпечаток данных добавляется в индекс для поиска похожих (см. FindSimilarHandler).
 */
 
 func (g *GatewayService) CreateHashHandler(w http.ResponseWriter, r *http.Request) {
@@ -139,18 +587,497 @@ func (g *GatewayService) CreateHashHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Создаем и заполняем HashRequest.
+	// Срок жизни хеша необязателен.
+	ttl, err := ttlFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid ttl: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Ключ хеширования необязателен.
+	key, keyVersion, err := keyFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid key_version: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	canonicalJSON, err := g.canonicalJSONFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid canonical_json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalization, err := normalizationFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid normalization: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	multihash, err := multihashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	simhash, err := simhashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid simhash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Создаем и заполняем HashRequest. Тело передаем как bytes, чтобы бинарные данные не искажались.
 	req := &pb.HashRequest{
-		Payload: string(body),
+		Data:          body,
+		ContentType:   r.Header.Get("Content-Type"),
+		Algorithm:     algorithmFromRequest(r),
+		Key:           key,
+		KeyVersion:    keyVersion,
+		Ttl:           ttl,
+		Owner:         r.URL.Query().Get(ownerParam),
+		Encoding:      encodingFromRequest(r),
+		Multihash:     multihash,
+		CanonicalJson: canonicalJSON,
+		Normalization: normalization,
+		Simhash:       simhash,
 	}
 
 	// Вызываем метод CreateHash на клиенте gRPC.
-	res, err := g.HashingClient.CreateHash(context.Background(), req)
+	res, err := g.HashingClient.CreateHash(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "CreateHash", err)
+		return
+	}
+
+	// Возвращаем полученный хеш обратно клиенту, имя алгоритма и признак создания передаем в заголовках.
+	w.Header().Set(algorithmHeader, res.Algorithm)
+	w.Header().Set(createdHeader, strconv.FormatBool(res.Created))
+	setKeyHeaders(w, res.Key, res.KeyVersion)
+	w.Write([]byte(res.Hash))
+}
+
+/*
+```http
+POST /createhash/stream?algorithm=sha256 HTTP/1.1
+Host: localhost:8080
+Content-Type: application/octet-stream
+Transfer-Encoding: chunked
+
+<данные любого размера>
+```
+Этот обработчик не читает тело запроса целиком, а передает его в Hashing Service частями по streamChunkSize
+через client-streaming метод CreateHashStream. Поэтому память gateway не зависит от размера данных.
+*/
+
+// streamChunkSize - размер части, которой тело запроса передается в CreateHashStream.
+const streamChunkSize = 64 << 10
+
+func (g *GatewayService) CreateHashStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Срок жизни хеша необязателен.
+	ttl, err := ttlFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid ttl: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Ключ хеширования необязателен.
+	key, keyVersion, err := keyFromRequest(r)
 	if err != nil {
-		http.Error(w, "Error calling CreateHash: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Invalid key_version: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Возвращаем полученный хеш обратно клиенту.
+	canonicalJSON, err := g.canonicalJSONFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid canonical_json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalization, err := normalizationFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid normalization: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	multihash, err := multihashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	simhash, err := simhashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid simhash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Открываем поток; он будет отменен вместе с контекстом запроса, если клиент отключится.
+	stream, err := g.HashingClient.CreateHashStream(r.Context())
+	if err != nil {
+		writeGrpcError(w, "CreateHashStream", err)
+		return
+	}
+
+	// Первое сообщение содержит только алгоритм, ключ, тип содержимого, срок жизни, владельца и кодировку, дальше идут данные.
+	sendErr := stream.Send(&pb.HashChunk{
+		Algorithm:     algorithmFromRequest(r),
+		Key:           key,
+		KeyVersion:    keyVersion,
+		ContentType:   r.Header.Get("Content-Type"),
+		Ttl:           ttl,
+		Owner:         r.URL.Query().Get(ownerParam),
+		Encoding:      encodingFromRequest(r),
+		Multihash:     multihash,
+		CanonicalJson: canonicalJSON,
+		Normalization: normalization,
+		Simhash:       simhash,
+	})
+
+	// Буфер переиспользуется: Send сериализует сообщение до возврата.
+	buf := make([]byte, streamChunkSize)
+	for sendErr == nil {
+		n, readErr := r.Body.Read(buf)
+		if n > 0 {
+			sendErr = stream.Send(&pb.HashChunk{Data: buf[:n]})
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			http.Error(w, "Error reading request body", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Если Send вернул ошибку, сервер уже закрыл поток - настоящую причину вернет CloseAndRecv.
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		writeGrpcError(w, "CreateHashStream", err)
+		return
+	}
+
+	// Возвращаем полученный хеш обратно клиенту, имя алгоритма и признак создания передаем в заголовках.
+	w.Header().Set(algorithmHeader, res.Algorithm)
+	w.Header().Set(createdHeader, strconv.FormatBool(res.Created))
+	setKeyHeaders(w, res.Key, res.KeyVersion)
 	w.Write([]byte(res.Hash))
 }
+
+/*
+```http
+GET /hashes/315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3/meta HTTP/1.1
+Host: localhost:8080
+```
+Этот обработчик возвращает метаданные сохраненного хеша в виде JSON: алгоритм, тип и размер данных,
+время создания, время последнего чтения, количество чтений, срок жизни (expires_at и ttl_seconds есть
+только у хешей с ограниченным сроком) и число ссылок владельцев (released_at есть, только если последняя
+ссылка освобождена). Query-параметр algorithm необязателен.
+*/
+
+// HashMetadataResult - JSON-ответ обработчика GetHashMetadataHandler.
+type HashMetadataResult struct {
+	Hash           string               `json:"hash"`
+	Algorithm      string               `json:"algorithm"`
+	Key            string               `json:"key,omitempty"`
+	KeyVersion     int64                `json:"key_version,omitempty"`
+	ContentType    string               `json:"content_type"`
+	Size           int64                `json:"size"`
+	CreatedAt      *time.Time           `json:"created_at,omitempty"`
+	LastAccessedAt *time.Time           `json:"last_accessed_at,omitempty"`
+	ReadCount      int64                `json:"read_count"`
+	ExpiresAt      *time.Time           `json:"expires_at,omitempty"`
+	TTLSeconds     *int64               `json:"ttl_seconds,omitempty"`
+	References     int64                `json:"references"`
+	ReleasedAt     *time.Time           `json:"released_at,omitempty"`
+	Normalization  *NormalizationResult `json:"normalization,omitempty"`
+	SimHash        string               `json:"simhash,omitempty"`
+}
+
+// newHashMetadataResult переводит ответ Hashing Service в JSON-ответ gateway.
+func newHashMetadataResult(res *pb.HashMetadata) HashMetadataResult {
+	result := HashMetadataResult{
+		Hash:           res.Hash,
+		Algorithm:      res.Algorithm,
+		Key:            res.Key,
+		KeyVersion:     res.KeyVersion,
+		ContentType:    res.ContentType,
+		Size:           res.Size,
+		CreatedAt:      optionalTime(res.CreatedAt),
+		LastAccessedAt: optionalTime(res.LastAccessedAt),
+		ReadCount:      res.ReadCount,
+		ExpiresAt:      optionalTime(res.ExpiresAt),
+		References:     res.References,
+		ReleasedAt:     optionalTime(res.ReleasedAt),
+		Normalization:  newNormalizationResult(res.Normalization),
+		SimHash:        res.Simhash,
+	}
+	if res.Ttl != nil {
+		seconds := int64(res.Ttl.AsDuration().Seconds())
+		result.TTLSeconds = &seconds
+	}
+	return result
+}
+
+func (g *GatewayService) GetHashMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - GET.
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	multihash, err := multihashFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Хеш берем из пути /hashes/{hash}/meta.
+	req := &pb.HashLookupRequest{
+		Hash:      r.PathValue("hash"),
+		Algorithm: algorithmFromRequest(r),
+		Encoding:  encodingFromRequest(r),
+		Multihash: multihash,
+	}
+
+	// Вызываем метод GetHashMetadata на клиенте gRPC.
+	res, err := g.HashingClient.GetHashMetadata(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "GetHashMetadata", err)
+		return
+	}
+
+	writeJSON(w, newHashMetadataResult(res))
+}
+
+/*
+```http
+POST /hashes/315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3/touch?ttl=720h HTTP/1.1
+Host: localhost:8080
+```
+Этот обработчик продлевает срок жизни хеша: новый срок отсчитывается от текущего момента. Без ttl
+применяется политика хранения Hashing Service. В ответ возвращаются обновленные метаданные.
+*/
+
+func (g *GatewayService) TouchHashHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ttl, err := ttlFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid ttl: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Хеш берем из пути /hashes/{hash}/touch.
+	req := &pb.TouchHashRequest{
+		Hash:      r.PathValue("hash"),
+		Algorithm: algorithmFromRequest(r),
+		Ttl:       ttl,
+	}
+
+	// Вызываем метод TouchHash на клиенте gRPC.
+	res, err := g.HashingClient.TouchHash(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "TouchHash", err)
+		return
+	}
+
+	writeJSON(w, newHashMetadataResult(res))
+}
+
+/*
+```http
+POST /hashes/315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3/release?owner=billing HTTP/1.1
+Host: localhost:8080
+```
+Этот обработчик освобождает ссылку владельца на хеш, взятую при создании с тем же owner. В ответ возвращается
+число оставшихся ссылок; когда их не остается, released_at - время, от которого отсчитывается льготный период
+перед удалением хеша. Если владелец не брал ссылку на хеш, возвращается 400 Bad Request.
+*/
+
+// ReleaseHashResult - JSON-ответ обработчика ReleaseHashHandler.
+type ReleaseHashResult struct {
+	Hash       string     `json:"hash"`
+	References int64      `json:"references"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
+func (g *GatewayService) ReleaseHashHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner := r.URL.Query().Get(ownerParam)
+	if owner == "" {
+		http.Error(w, "Missing owner", http.StatusBadRequest)
+		return
+	}
+
+	// Хеш берем из пути /hashes/{hash}/release.
+	req := &pb.ReleaseHashRequest{
+		Hash:      r.PathValue("hash"),
+		Algorithm: algorithmFromRequest(r),
+		Owner:     owner,
+	}
+
+	// Вызываем метод ReleaseHash на клиенте gRPC.
+	res, err := g.HashingClient.ReleaseHash(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "ReleaseHash", err)
+		return
+	}
+
+	writeJSON(w, ReleaseHashResult{
+		Hash:       res.Hash,
+		References: res.References,
+		ReleasedAt: optionalTime(res.ReleasedAt),
+	})
+}
+
+/*
+```http
+GET /hashes?prefix=315f&limit=50&algorithm=sha256&created_after=2024-03-01T00:00:00Z HTTP/1.1
+Host: localhost:8080
+Authorization: Bearer <токен администратора>
+```
+Этот обработчик возвращает страницу сохраненных хешей с метаданными. Все параметры необязательны:
+cursor - значение next_cursor предыдущей страницы, prefix - начало хеша, limit - размер страницы,
+algorithm - алгоритм, created_after и created_before - диапазон времени создания в RFC 3339.
+Обработчик доступен только с разрешением PermissionAdmin.
+*/
+
+// ListHashesResult - JSON-ответ обработчика ListHashesHandler.
+type ListHashesResult struct {
+	Hashes     []HashMetadataResult `json:"hashes"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func (g *GatewayService) ListHashesHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - GET.
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := &pb.ListHashesRequest{
+		Cursor:    query.Get("cursor"),
+		Prefix:    query.Get("prefix"),
+		Algorithm: algorithmFromRequest(r),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Limit = int32(limit)
+	}
+
+	var err error
+	if req.CreatedAfter, err = timestampParam(r, "created_after"); err != nil {
+		http.Error(w, "Invalid created_after: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CreatedBefore, err = timestampParam(r, "created_before"); err != nil {
+		http.Error(w, "Invalid created_before: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Вызываем метод ListHashes на клиенте gRPC.
+	res, err := g.HashingClient.ListHashes(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "ListHashes", err)
+		return
+	}
+
+	result := ListHashesResult{
+		Hashes:     make([]HashMetadataResult, 0, len(res.Hashes)),
+		NextCursor: res.NextCursor,
+	}
+	for _, meta := range res.Hashes {
+		result.Hashes = append(result.Hashes, newHashMetadataResult(meta))
+	}
+	writeJSON(w, result)
+}
+
+// timestampParam разбирает время в формате RFC 3339 из query-параметра name. Пустой параметр дает nil.
+func timestampParam(r *http.Request, name string) (*timestamppb.Timestamp, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return timestamppb.New(t), nil
+}
+
+/*
+```http
+DELETE /hashes/315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3?tombstone=true HTTP/1.1
+Host: localhost:8080
+Authorization: Bearer <токен администратора>
+```
+Этот обработчик удаляет хеш вместе с исходными данными. С tombstone=true вместо хеша остается надгробие,
+и последующие запросы к нему возвращают 410 Gone вместо 404 Not Found. Обработчик доступен только
+с разрешением PermissionAdmin. В ответ возвращается 204 No Content.
+*/
+
+// tombstoneParam - query-параметр, включающий надгробие при удалении хеша.
+const tombstoneParam = "tombstone"
+
+func (g *GatewayService) DeleteHashHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - DELETE.
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tombstone := false
+	if value := r.URL.Query().Get(tombstoneParam); value != "" {
+		var err error
+		tombstone, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid tombstone: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Хеш берем из пути /hashes/{hash}.
+	req := &pb.DeleteHashRequest{
+		Hash:      r.PathValue("hash"),
+		Algorithm: algorithmFromRequest(r),
+		Tombstone: tombstone,
+	}
+
+	// Вызываем метод DeleteHash на клиенте gRPC.
+	if _, err := g.HashingClient.DeleteHash(r.Context(), req); err != nil {
+		writeGrpcError(w, "DeleteHash", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// optionalTime переводит Timestamp в *time.Time, чтобы отсутствующее время не попадало в JSON.
+func optionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	pb "final-project-kodzimo-shared/proto"
+)
+
+/*
+Поиск похожих данных по отпечаткам SimHash. Хеш, созданный через /createhash?simhash=true, попадает в индекс
+отпечатков, и /similar находит его по похожим (а не только одинаковым) данным.
+
+```http
+POST /similar?max_distance=3&limit=10 HTTP/1.1
+Host: localhost:8080
+Content-Type: text/plain
+Content-Length: 13
+
+Hello, world!
+```
+
+Параметры необязательны: max_distance - наибольшее расстояние Хэмминга между отпечатками (не больше 7; 0 или без параметра - 3),
+limit - наибольшее число хешей (по умолчанию 10), а также encoding, canonical_json и параметры нормализации текста,
+как у /createhash. Ответ - {"fingerprint": "...", "matches": [{"hash": "...", "algorithm": "sha256", "distance": 1}]},
+хеши упорядочены по расстоянию.
+*/
+
+// Параметр simhash=true у /createhash добавляет отпечаток данных в индекс для /similar.
+const simhashParam = "simhash"
+
+// simhashFromRequest возвращает значение параметра simhash; без параметра - false.
+func simhashFromRequest(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get(simhashParam)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// SimilarHashResult - хеш, найденный FindSimilarHandler.
+type SimilarHashResult struct {
+	Hash       string `json:"hash"`
+	Algorithm  string `json:"algorithm"`
+	Distance   int32  `json:"distance"`
+	Key        string `json:"key,omitempty"`
+	KeyVersion int64  `json:"key_version,omitempty"`
+}
+
+// FindSimilarResult - JSON-ответ обработчика FindSimilarHandler.
+type FindSimilarResult struct {
+	Fingerprint string              `json:"fingerprint"`
+	Matches     []SimilarHashResult `json:"matches"`
+}
+
+func (g *GatewayService) FindSimilarHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем, что метод запроса - POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &pb.FindSimilarRequest{Encoding: encodingFromRequest(r)}
+	var err error
+	if req.MaxDistance, err = int32Param(r, "max_distance"); err != nil {
+		http.Error(w, "Invalid max_distance: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Limit, err = int32Param(r, "limit"); err != nil {
+		http.Error(w, "Invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CanonicalJson, err = g.canonicalJSONFromRequest(r); err != nil {
+		http.Error(w, "Invalid canonical_json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Normalization, err = normalizationFromRequest(r); err != nil {
+		http.Error(w, "Invalid normalization: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Извлекаем полезную нагрузку из тела запроса.
+	if req.Data, err = io.ReadAll(r.Body); err != nil {
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	// Вызываем метод FindSimilar на клиенте gRPC.
+	res, err := g.HashingClient.FindSimilar(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, "FindSimilar", err)
+		return
+	}
+
+	result := FindSimilarResult{
+		Fingerprint: res.Fingerprint,
+		Matches:     make([]SimilarHashResult, 0, len(res.Matches)),
+	}
+	for _, match := range res.Matches {
+		result.Matches = append(result.Matches, SimilarHashResult{
+			Hash:       match.Hash,
+			Algorithm:  match.Algorithm,
+			Distance:   match.Distance,
+			Key:        match.Key,
+			KeyVersion: match.KeyVersion,
+		})
+	}
+	writeJSON(w, result)
+}
+
+// int32Param разбирает целое число из query-параметра name. Пустой параметр дает 0.
+func int32Param(r *http.Request, name string) (int32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	return int32(parsed), err
+}
@@ -0,0 +1,319 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AdminClientMock является мок-объектом для pb.HashingAdminClient
+type AdminClientMock struct {
+	mock.Mock
+}
+
+func (m *AdminClientMock) CreateNamespace(ctx context.Context, in *pb.CreateNamespaceRequest, opts ...grpc.CallOption) (*pb.Namespace, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.Namespace), args.Error(1)
+}
+
+func (m *AdminClientMock) GetNamespace(ctx context.Context, in *pb.GetNamespaceRequest, opts ...grpc.CallOption) (*pb.Namespace, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.Namespace), args.Error(1)
+}
+
+func (m *AdminClientMock) ListNamespaces(ctx context.Context, in *pb.ListNamespacesRequest, opts ...grpc.CallOption) (*pb.ListNamespacesResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.ListNamespacesResponse), args.Error(1)
+}
+
+func (m *AdminClientMock) DeleteNamespace(ctx context.Context, in *pb.DeleteNamespaceRequest, opts ...grpc.CallOption) (*pb.DeleteNamespaceResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.DeleteNamespaceResponse), args.Error(1)
+}
+
+func (m *AdminClientMock) CreateKey(ctx context.Context, in *pb.CreateKeyRequest, opts ...grpc.CallOption) (*pb.Key, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.Key), args.Error(1)
+}
+
+func (m *AdminClientMock) RotateKey(ctx context.Context, in *pb.RotateKeyRequest, opts ...grpc.CallOption) (*pb.Key, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.Key), args.Error(1)
+}
+
+func (m *AdminClientMock) GetKey(ctx context.Context, in *pb.GetKeyRequest, opts ...grpc.CallOption) (*pb.Key, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.Key), args.Error(1)
+}
+
+func (m *AdminClientMock) ListKeys(ctx context.Context, in *pb.ListKeysRequest, opts ...grpc.CallOption) (*pb.ListKeysResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.ListKeysResponse), args.Error(1)
+}
+
+// namespaceOf возвращает пространство имен из исходящих gRPC-метаданных контекста
+func namespaceOf(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if values := md.Get(namespaceMetadataKey); len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+/*
+Этот тест проверяет, что Authenticate передает в Hashing Service пространство имен из токена клиента,
+не передает его для анонимных запросов, отклоняет неизвестный токен с 401, а переключение пространства
+заголовком X-Namespace разрешает только администратору.
+*/
+
+func TestAuthenticateNamespace(t *testing.T) {
+	var namespaces []string
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CheckHash", mock.Anything, &pb.HashRequest{Data: []byte("test")}).
+		Run(func(args mock.Arguments) {
+			namespaces = append(namespaces, namespaceOf(args.Get(0).(context.Context)))
+		}).
+		Return(&pb.CheckHashResponse{Exists: true}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+		Auth:          NewAuthenticator(),
+	}
+	gw.Auth.AddToken("admin-token", Principal{Name: "admin", Permissions: []Permission{PermissionAdmin}})
+	gw.Auth.AddToken("team-a-token", Principal{Name: "team-a", Namespace: "team-a"})
+
+	handler := gw.Authenticate(http.HandlerFunc(gw.CheckHashHandler))
+
+	tests := []struct {
+		token     string
+		namespace string
+		code      int
+	}{
+		{"", "", http.StatusOK},
+		{"team-a-token", "", http.StatusOK},
+		{"admin-token", "team-b", http.StatusOK},
+		{"unknown-token", "", http.StatusUnauthorized},
+		{"team-a-token", "team-b", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/checkhash", strings.NewReader("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.namespace != "" {
+			req.Header.Set(namespaceHeader, tt.namespace)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.token+" "+tt.namespace)
+	}
+
+	assert.Equal(t, []string{"", "team-a", "team-b"}, namespaces)
+}
+
+/*
+Этот тест проверяет загрузку токенов из JSON-файла: пространство имен и разрешения учетной записи.
+*/
+
+func TestAuthenticatorLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	err := os.WriteFile(path, []byte(`[
+		{"token": "team-a-token", "name": "team-a", "namespace": "team-a"},
+		{"token": "root-token", "name": "root", "permissions": ["admin"]}
+	]`), 0600)
+	assert.NoError(t, err)
+
+	auth := NewAuthenticator()
+	assert.NoError(t, auth.LoadFile(path))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer team-a-token")
+	principal, ok := auth.Authenticate(req)
+	assert.True(t, ok)
+	assert.Equal(t, "team-a", principal.Namespace)
+	assert.False(t, principal.Has(PermissionAdmin))
+
+	req.Header.Set("Authorization", "Bearer root-token")
+	principal, ok = auth.Authenticate(req)
+	assert.True(t, ok)
+	assert.True(t, principal.Has(PermissionAdmin))
+
+	assert.Error(t, auth.LoadFile(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+/*
+Этот тест проверяет, что служебный токен добавляется в gRPC-метаданные исходящих вызовов и потоков
+вместе с уже переданными метаданными.
+*/
+
+func TestServiceTokenInterceptor(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), namespaceMetadataKey, "team-a")
+	err := ServiceTokenInterceptor("secret")(ctx, "/proto.Hashing/DeleteHash", nil, nil, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer secret"}, md.Get(authorizationMetadataKey))
+	assert.Equal(t, []string{"team-a"}, md.Get(namespaceMetadataKey))
+
+	md = nil
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	}
+	_, err = ServiceTokenStreamInterceptor("secret")(ctx, nil, nil, "/proto.Hashing/CreateHashStream", streamer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer secret"}, md.Get(authorizationMetadataKey))
+	assert.Equal(t, []string{"team-a"}, md.Get(namespaceMetadataKey))
+}
+
+/*
+Этот тест проверяет административные обработчики пространств имен: доступ только администратору,
+создание (201, повторное создание - 409), получение статистики, список и удаление.
+*/
+
+func TestNamespaceAdminHandlers(t *testing.T) {
+	adminClientMock := new(AdminClientMock)
+	adminClientMock.On("CreateNamespace", mock.Anything, &pb.CreateNamespaceRequest{Name: "team-a", MaxHashes: 10}).
+		Return(&pb.Namespace{Name: "team-a", MaxHashes: 10}, nil).Once()
+	adminClientMock.On("CreateNamespace", mock.Anything, &pb.CreateNamespaceRequest{Name: "team-a", MaxHashes: 10}).
+		Return(&pb.Namespace{}, status.Error(codes.AlreadyExists, "namespace already exists"))
+	adminClientMock.On("GetNamespace", mock.Anything, &pb.GetNamespaceRequest{Name: "team-a"}).
+		Return(&pb.Namespace{Name: "team-a", MaxHashes: 10, Stats: &pb.NamespaceStats{Hashes: 2, TotalSize: 8, ReadCount: 3}}, nil)
+	adminClientMock.On("ListNamespaces", mock.Anything, &pb.ListNamespacesRequest{}).
+		Return(&pb.ListNamespacesResponse{Namespaces: []*pb.Namespace{{Name: "default"}, {Name: "team-a", MaxHashes: 10}}}, nil)
+	adminClientMock.On("DeleteNamespace", mock.Anything, &pb.DeleteNamespaceRequest{Name: "team-a"}).
+		Return(&pb.DeleteNamespaceResponse{Name: "team-a", DeletedHashes: 2}, nil)
+
+	gw := &GatewayService{
+		AdminClient: adminClientMock,
+		Auth:        NewAuthenticator(),
+	}
+	gw.Auth.AddToken("admin-token", Principal{Name: "admin", Permissions: []Permission{PermissionAdmin}})
+	gw.Auth.AddToken("user-token", Principal{Name: "user"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/namespaces", gw.RequirePermission(PermissionAdmin, gw.CreateNamespaceHandler))
+	mux.HandleFunc("GET /admin/namespaces", gw.RequirePermission(PermissionAdmin, gw.ListNamespacesHandler))
+	mux.HandleFunc("GET /admin/namespaces/{name}", gw.RequirePermission(PermissionAdmin, gw.GetNamespaceHandler))
+	mux.HandleFunc("DELETE /admin/namespaces/{name}", gw.RequirePermission(PermissionAdmin, gw.DeleteNamespaceHandler))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		code   int
+		want   string
+	}{
+		{"GET", "/admin/namespaces", "", "user-token", http.StatusForbidden, ""},
+		{"POST", "/admin/namespaces", `{"name": "team-a", "max_hashes": 10}`, "admin-token", http.StatusCreated, `"name":"team-a"`},
+		{"POST", "/admin/namespaces", `{"name": "team-a", "max_hashes": 10}`, "admin-token", http.StatusConflict, ""},
+		{"POST", "/admin/namespaces", `not json`, "admin-token", http.StatusBadRequest, ""},
+		{"GET", "/admin/namespaces/team-a", "", "admin-token", http.StatusOK, `"stats":{"hashes":2,"total_size":8,"read_count":3}`},
+		{"GET", "/admin/namespaces", "", "admin-token", http.StatusOK, `{"name":"default","max_hashes":0,"max_payload_size":0}`},
+		{"DELETE", "/admin/namespaces/team-a", "", "admin-token", http.StatusOK, `"deleted_hashes":2`},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		rr := httptest.NewRecorder()
+		gw.Authenticate(mux).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.method+" "+tt.path)
+		assert.Contains(t, rr.Body.String(), tt.want, tt.method+" "+tt.path)
+	}
+
+	adminClientMock.AssertNumberOfCalls(t, "CreateNamespace", 2)
+}
+
+/*
+Этот тест проверяет административные обработчики ключей хеширования: доступ только администратору,
+создание (201, повторное создание - 409), смену версии, получение и список ключей.
+*/
+
+func TestKeyAdminHandlers(t *testing.T) {
+	key := &pb.Key{Name: "emails", CurrentVersion: 1, Versions: []*pb.KeyVersion{{Version: 1}}}
+	rotated := &pb.Key{Name: "emails", CurrentVersion: 2, Versions: []*pb.KeyVersion{{Version: 1}, {Version: 2}}}
+
+	adminClientMock := new(AdminClientMock)
+	adminClientMock.On("CreateKey", mock.Anything, &pb.CreateKeyRequest{Name: "emails"}).Return(key, nil).Once()
+	adminClientMock.On("CreateKey", mock.Anything, &pb.CreateKeyRequest{Name: "emails"}).
+		Return(&pb.Key{}, status.Error(codes.AlreadyExists, "key already exists"))
+	adminClientMock.On("RotateKey", mock.Anything, &pb.RotateKeyRequest{Name: "emails"}).Return(rotated, nil)
+	adminClientMock.On("GetKey", mock.Anything, &pb.GetKeyRequest{Name: "emails"}).Return(rotated, nil)
+	adminClientMock.On("GetKey", mock.Anything, &pb.GetKeyRequest{Name: "phones"}).
+		Return(&pb.Key{}, status.Error(codes.NotFound, "key not found"))
+	adminClientMock.On("ListKeys", mock.Anything, &pb.ListKeysRequest{}).
+		Return(&pb.ListKeysResponse{Keys: []*pb.Key{rotated}}, nil)
+
+	gw := &GatewayService{
+		AdminClient: adminClientMock,
+		Auth:        NewAuthenticator(),
+	}
+	gw.Auth.AddToken("admin-token", Principal{Name: "admin", Permissions: []Permission{PermissionAdmin}})
+	gw.Auth.AddToken("user-token", Principal{Name: "user"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/keys", gw.RequirePermission(PermissionAdmin, gw.CreateKeyHandler))
+	mux.HandleFunc("GET /admin/keys", gw.RequirePermission(PermissionAdmin, gw.ListKeysHandler))
+	mux.HandleFunc("GET /admin/keys/{name}", gw.RequirePermission(PermissionAdmin, gw.GetKeyHandler))
+	mux.HandleFunc("POST /admin/keys/{name}/rotate", gw.RequirePermission(PermissionAdmin, gw.RotateKeyHandler))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		code   int
+		want   string
+	}{
+		{"POST", "/admin/keys", `{"name": "emails"}`, "user-token", http.StatusForbidden, ""},
+		{"POST", "/admin/keys", `{"name": "emails"}`, "admin-token", http.StatusCreated, `"current_version":1`},
+		{"POST", "/admin/keys", `{"name": "emails"}`, "admin-token", http.StatusConflict, ""},
+		{"POST", "/admin/keys/emails/rotate", "", "admin-token", http.StatusOK, `"versions":[{"version":1},{"version":2}]`},
+		{"GET", "/admin/keys/emails", "", "admin-token", http.StatusOK, `"current_version":2`},
+		{"GET", "/admin/keys/phones", "", "admin-token", http.StatusNotFound, ""},
+		{"GET", "/admin/keys", "", "admin-token", http.StatusOK, `{"keys":[{"name":"emails"`},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		rr := httptest.NewRecorder()
+		gw.Authenticate(mux).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.method+" "+tt.path)
+		assert.Contains(t, rr.Body.String(), tt.want, tt.method+" "+tt.path)
+	}
+
+	adminClientMock.AssertNumberOfCalls(t, "CreateKey", 2)
+}
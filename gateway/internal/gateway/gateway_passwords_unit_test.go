@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что обработчики паролей передают пароль, алгоритм и параметры из JSON-тела
+в Hashing Service, возвращают результат в JSON, а некорректное тело и ошибки параметров - как 400.
+*/
+
+func TestPasswordHandlers(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("HashPassword", mock.Anything, &pb.HashPasswordRequest{
+		Password:  "secret",
+		Algorithm: "bcrypt",
+		Params:    &pb.PasswordParams{Cost: 12},
+	}).Return(&pb.HashPasswordResponse{Hash: "$2a$12$hash", Algorithm: "bcrypt"}, nil)
+	hashingClientMock.On("HashPassword", mock.Anything, &pb.HashPasswordRequest{
+		Password:  "secret",
+		Algorithm: "bcrypt",
+		Params:    &pb.PasswordParams{Cost: 40},
+	}).Return(&pb.HashPasswordResponse{}, status.Error(codes.InvalidArgument, "bcrypt cost must be between 4 and 18"))
+	hashingClientMock.On("VerifyPassword", mock.Anything, &pb.VerifyPasswordRequest{Password: "secret", Hash: "$2a$12$hash"}).
+		Return(&pb.VerifyPasswordResponse{Valid: true, NeedsRehash: true, Algorithm: "bcrypt"}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /passwords/hash", gw.HashPasswordHandler)
+	mux.HandleFunc("POST /passwords/verify", gw.VerifyPasswordHandler)
+
+	tests := []struct {
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"/passwords/hash", `{"password": "secret", "algorithm": "bcrypt", "params": {"cost": 12}}`, http.StatusOK, `{"hash":"$2a$12$hash","algorithm":"bcrypt"}`},
+		{"/passwords/hash", `{"password": "secret", "algorithm": "bcrypt", "params": {"cost": 40}}`, http.StatusBadRequest, ""},
+		{"/passwords/hash", `not json`, http.StatusBadRequest, ""},
+		{"/passwords/verify", `{"password": "secret", "hash": "$2a$12$hash"}`, http.StatusOK, `{"valid":true,"needs_rehash":true,"algorithm":"bcrypt"}`},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.wantCode, rr.Code, tt.body)
+		if tt.wantBody != "" {
+			assert.JSONEq(t, tt.wantBody, rr.Body.String(), tt.body)
+		}
+	}
+}
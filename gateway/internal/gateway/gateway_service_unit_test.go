@@ -1,18 +1,25 @@
 package gateway
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	pb "final-project-kodzimo-shared/proto"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // HashingClientMock является мок-объектом для pb.HashingClient
@@ -21,9 +28,9 @@ type HashingClientMock struct {
 }
 
 // CheckHash является фиктивной реализацией метода CheckHash
-func (m *HashingClientMock) CheckHash(ctx context.Context, in *pb.HashRequest, opts ...grpc.CallOption) (*pb.HashResponse, error) {
+func (m *HashingClientMock) CheckHash(ctx context.Context, in *pb.HashRequest, opts ...grpc.CallOption) (*pb.CheckHashResponse, error) {
 	args := m.Called(ctx, in)
-	return args.Get(0).(*pb.HashResponse), args.Error(1)
+	return args.Get(0).(*pb.CheckHashResponse), args.Error(1)
 }
 
 // GetHash является фиктивной реализацией метода CheckHash
@@ -38,6 +45,66 @@ func (m *HashingClientMock) CreateHash(ctx context.Context, in *pb.HashRequest,
 	return args.Get(0).(*pb.HashResponse), args.Error(1)
 }
 
+// CreateHashStream является фиктивной реализацией метода CreateHashStream
+func (m *HashingClientMock) CreateHashStream(ctx context.Context, opts ...grpc.CallOption) (pb.Hashing_CreateHashStreamClient, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(pb.Hashing_CreateHashStreamClient), args.Error(1)
+}
+
+// GetHashMetadata является фиктивной реализацией метода GetHashMetadata
+func (m *HashingClientMock) GetHashMetadata(ctx context.Context, in *pb.HashLookupRequest, opts ...grpc.CallOption) (*pb.HashMetadata, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.HashMetadata), args.Error(1)
+}
+
+// DeleteHash является фиктивной реализацией метода DeleteHash
+func (m *HashingClientMock) DeleteHash(ctx context.Context, in *pb.DeleteHashRequest, opts ...grpc.CallOption) (*pb.DeleteHashResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.DeleteHashResponse), args.Error(1)
+}
+
+// ListHashes является фиктивной реализацией метода ListHashes
+func (m *HashingClientMock) ListHashes(ctx context.Context, in *pb.ListHashesRequest, opts ...grpc.CallOption) (*pb.ListHashesResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.ListHashesResponse), args.Error(1)
+}
+
+// TouchHash является фиктивной реализацией метода TouchHash
+func (m *HashingClientMock) TouchHash(ctx context.Context, in *pb.TouchHashRequest, opts ...grpc.CallOption) (*pb.HashMetadata, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.HashMetadata), args.Error(1)
+}
+
+// ReleaseHash является фиктивной реализацией метода ReleaseHash
+func (m *HashingClientMock) ReleaseHash(ctx context.Context, in *pb.ReleaseHashRequest, opts ...grpc.CallOption) (*pb.ReleaseHashResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.ReleaseHashResponse), args.Error(1)
+}
+
+// HashPassword является фиктивной реализацией метода HashPassword
+func (m *HashingClientMock) HashPassword(ctx context.Context, in *pb.HashPasswordRequest, opts ...grpc.CallOption) (*pb.HashPasswordResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.HashPasswordResponse), args.Error(1)
+}
+
+// VerifyHash является фиктивной реализацией метода VerifyHash
+func (m *HashingClientMock) VerifyHash(ctx context.Context, in *pb.VerifyHashRequest, opts ...grpc.CallOption) (*pb.VerifyHashResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.VerifyHashResponse), args.Error(1)
+}
+
+// VerifyPassword является фиктивной реализацией метода VerifyPassword
+func (m *HashingClientMock) VerifyPassword(ctx context.Context, in *pb.VerifyPasswordRequest, opts ...grpc.CallOption) (*pb.VerifyPasswordResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.VerifyPasswordResponse), args.Error(1)
+}
+
+// FindSimilar является фиктивной реализацией метода FindSimilar
+func (m *HashingClientMock) FindSimilar(ctx context.Context, in *pb.FindSimilarRequest, opts ...grpc.CallOption) (*pb.FindSimilarResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.FindSimilarResponse), args.Error(1)
+}
+
 /*
 Этот тест проверяет, что CheckHashHandler возвращает статус 200 OK при получении POST-запроса.
 В этом примере мы создаем мок-объект HashingClientMock, который возвращает фиктивный хеш и nil-ошибку
@@ -46,7 +113,13 @@ func (m *HashingClientMock) CreateHash(ctx context.Context, in *pb.HashRequest,
 
 func TestCheckHashHandler(t *testing.T) {
 	hashingClientMock := new(HashingClientMock)
-	hashingClientMock.On("CheckHash", mock.Anything, mock.Anything).Return(&pb.HashResponse{Hash: "testhash"}, nil)
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hashingClientMock.On("CheckHash", mock.Anything, mock.Anything).Return(&pb.CheckHashResponse{
+		Exists:    true,
+		Hash:      "testhash",
+		Algorithm: "sha256",
+		CreatedAt: timestamppb.New(createdAt),
+	}, nil)
 
 	gw := &GatewayService{
 		HashingClient: hashingClientMock,
@@ -63,6 +136,8 @@ func TestCheckHashHandler(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"exists":true,"hash":"testhash","algorithm":"sha256","created_at":"2024-03-01T12:00:00Z"}`, rr.Body.String())
 
 	/*
 	   В этом тесте мы будем проверять, что обработчик возвращает http.StatusMethodNotAllowed при получении
@@ -81,7 +156,21 @@ func TestCheckHashHandler(t *testing.T) {
 
 	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
 
-	// Здесь вы можете добавить дополнительные проверки для тела ответа
+	// Несуществующий хеш - это не ошибка, а exists = false без created_at
+	hashingClientMock = new(HashingClientMock)
+	hashingClientMock.On("CheckHash", mock.Anything, mock.Anything).Return(&pb.CheckHashResponse{Hash: "testhash", Algorithm: "sha256"}, nil)
+	gw.HashingClient = hashingClientMock
+
+	req, err = http.NewRequest("POST", "/checkhash", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"exists":false,"hash":"testhash","algorithm":"sha256"}`, rr.Body.String())
 }
 
 /*
@@ -94,7 +183,7 @@ func TestCheckHashHandler(t *testing.T) {
 
 func TestCheckHashHandlerGrpcError(t *testing.T) {
 	hashingClientMock := new(HashingClientMock)
-	hashingClientMock.On("CheckHash", mock.Anything, mock.Anything).Return(&pb.HashResponse{}, errors.New("forced error"))
+	hashingClientMock.On("CheckHash", mock.Anything, mock.Anything).Return(&pb.CheckHashResponse{}, errors.New("forced error"))
 
 	gw := &GatewayService{
 		HashingClient: hashingClientMock,
@@ -113,6 +202,53 @@ func TestCheckHashHandlerGrpcError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 }
 
+/*
+Этот тест проверяет, что VerifyHashHandler передает тело запроса, ожидаемый хеш и алгоритм в VerifyHash,
+возвращает несовпадение со статусом 200, а запрос без хеша и некорректный хеш - как 400.
+*/
+
+func TestVerifyHashHandler(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("VerifyHash", mock.Anything, &pb.VerifyHashRequest{Data: []byte("test"), ExpectedHash: "sha1:abcd"}).
+		Return(&pb.VerifyHashResponse{Match: true, Algorithm: "sha1"}, nil)
+	hashingClientMock.On("VerifyHash", mock.Anything, &pb.VerifyHashRequest{Data: []byte("other"), ExpectedHash: "abcd", Algorithm: "sha512"}).
+		Return(&pb.VerifyHashResponse{Algorithm: "sha512"}, nil)
+	hashingClientMock.On("VerifyHash", mock.Anything, &pb.VerifyHashRequest{Data: []byte("test"), ExpectedHash: "xyz"}).
+		Return(&pb.VerifyHashResponse{}, status.Error(codes.InvalidArgument, "expected hash must be a hex string"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+	handler := http.HandlerFunc(gw.VerifyHashHandler)
+
+	tests := []struct {
+		url      string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"/verify?hash=sha1:abcd", "test", http.StatusOK, `{"match":true,"algorithm":"sha1"}`},
+		{"/verify?hash=abcd&algorithm=sha512", "other", http.StatusOK, `{"match":false,"algorithm":"sha512"}`},
+		{"/verify?hash=xyz", "test", http.StatusBadRequest, ""},
+		{"/verify", "test", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.wantCode, rr.Code, tt.url)
+		if tt.wantBody != "" {
+			assert.JSONEq(t, tt.wantBody, rr.Body.String(), tt.url)
+		}
+	}
+	hashingClientMock.AssertNumberOfCalls(t, "VerifyHash", 3)
+}
+
 /*
 В этом тесте мы будем проверять, что обработчик корректно обрабатывает HTTP-запросы и возвращает ожидаемый HTTP-статус
 и тело ответа. В этом тесте мы создаем мок-объект HashingClientMock, который возвращает фиктивный хеш и nil-ошибку
@@ -122,7 +258,7 @@ POST-запрос к GetHashHandler и проверяем, что возвращ
 
 func TestGetHashHandler(t *testing.T) {
 	hashingClientMock := new(HashingClientMock)
-	hashingClientMock.On("GetHash", mock.Anything, mock.Anything).Return(&pb.HashResponse{Hash: "testhash"}, nil)
+	hashingClientMock.On("GetHash", mock.Anything, mock.Anything).Return(&pb.HashResponse{Hash: "testhash", Payload: []byte("test")}, nil)
 
 	gw := &GatewayService{
 		HashingClient: hashingClientMock,
@@ -139,7 +275,8 @@ func TestGetHashHandler(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, "testhash", rr.Body.String())
+	assert.Equal(t, "test", rr.Body.String())
+	assert.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"))
 
 	/*
 	   В этом примере мы создаем GET-запрос вместо POST-запроса. Мы ожидаем, что обработчик вернет код
@@ -190,6 +327,791 @@ func TestGetHashHandlerInternalError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 }
 
+/*
+Этот тест проверяет, что CreateHashHandler передает алгоритм из query-параметра или заголовка
+в Hashing Service и возвращает имя алгоритма в заголовке ответа.
+*/
+
+func TestCreateHashHandlerAlgorithm(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, mock.MatchedBy(func(req *pb.HashRequest) bool {
+		return req.GetAlgorithm() == "sha512"
+	})).Return(&pb.HashResponse{Hash: "testhash", Algorithm: "sha512"}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+	handler := http.HandlerFunc(gw.CreateHashHandler)
+
+	// Алгоритм в query-параметре
+	req, err := http.NewRequest("POST", "/createhash?algorithm=sha512", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "testhash", rr.Body.String())
+	assert.Equal(t, "sha512", rr.Header().Get("X-Hash-Algorithm"))
+
+	// Алгоритм в заголовке
+	req, err = http.NewRequest("POST", "/createhash", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Hash-Algorithm", "sha512")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	hashingClientMock.AssertNumberOfCalls(t, "CreateHash", 2)
+}
+
+/*
+Этот тест проверяет, что CreateHashHandler сообщает в заголовке X-Hash-Created, создан ли хеш, а коллизию
+хешей (AlreadyExists) возвращает как 409 Conflict.
+*/
+
+func TestCreateHashHandlerCreated(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("new")}).
+		Return(&pb.HashResponse{Hash: "newhash", Algorithm: "sha256", Created: true}, nil)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("existing")}).
+		Return(&pb.HashResponse{Hash: "existinghash", Algorithm: "sha256"}, nil)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("collision")}).
+		Return(&pb.HashResponse{}, status.Error(codes.AlreadyExists, "hash collision"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+	handler := http.HandlerFunc(gw.CreateHashHandler)
+
+	tests := []struct {
+		body    string
+		code    int
+		created string
+	}{
+		{"new", http.StatusOK, "true"},
+		{"existing", http.StatusOK, "false"},
+		{"collision", http.StatusConflict, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/createhash", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.body)
+		assert.Equal(t, tt.created, rr.Header().Get("X-Hash-Created"), tt.body)
+	}
+}
+
+/*
+Этот тест проверяет, что CreateHashHandler и CheckHashHandler передают ключ и его версию из query-параметров,
+возвращают их в заголовках и JSON, а некорректную версию ключа отклоняют с 400.
+*/
+
+func TestHashHandlersKey(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("alice@example.com"), Key: "emails"}).
+		Return(&pb.HashResponse{Hash: "keyedhash", Algorithm: "sha256", Key: "emails", KeyVersion: 2, Created: true}, nil)
+	hashingClientMock.On("CheckHash", mock.Anything, &pb.HashRequest{Data: []byte("alice@example.com"), Key: "emails", KeyVersion: 1}).
+		Return(&pb.CheckHashResponse{Hash: "oldhash", Algorithm: "sha256", Key: "emails", KeyVersion: 1, Exists: true}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash?key=emails", strings.NewReader("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "keyedhash", rr.Body.String())
+	assert.Equal(t, "emails", rr.Header().Get("X-Hash-Key"))
+	assert.Equal(t, "2", rr.Header().Get("X-Hash-Key-Version"))
+
+	req, err = http.NewRequest("POST", "/checkhash?key=emails&key_version=1", strings.NewReader("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CheckHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"exists":true,"hash":"oldhash","algorithm":"sha256","key":"emails","key_version":1}`, rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/checkhash?key=emails&key_version=latest", strings.NewReader("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CheckHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	hashingClientMock.AssertNumberOfCalls(t, "CheckHash", 1)
+}
+
+/*
+Этот тест проверяет, что кодировка хеша из query-параметра encoding или заголовка X-Hash-Encoding
+передается в Hashing Service, а неизвестная кодировка возвращается как 400 Bad Request.
+*/
+
+func TestHashHandlersEncoding(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("test"), Encoding: "base58"}).
+		Return(&pb.HashResponse{Hash: "Bjj4AWTNrjQVHqgWbP2XaxXz4DYH1WZMyERHxsad7b2w", Algorithm: "sha256", Created: true}, nil)
+	hashingClientMock.On("CheckHash", mock.Anything, &pb.HashRequest{Data: []byte("test"), Encoding: "base64url"}).
+		Return(&pb.CheckHashResponse{Hash: "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg", Algorithm: "sha256", Exists: true}, nil)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("test"), Encoding: "base16"}).
+		Return(&pb.HashResponse{}, status.Error(codes.InvalidArgument, "unknown hash encoding \"base16\""))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash?encoding=base58", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Bjj4AWTNrjQVHqgWbP2XaxXz4DYH1WZMyERHxsad7b2w", rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/checkhash", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Hash-Encoding", "base64url")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CheckHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"exists":true,"hash":"n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg","algorithm":"sha256"}`, rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/createhash?encoding=base16", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+/*
+Этот тест проверяет, что параметр multihash передается в Hashing Service, а некорректное значение
+параметра возвращает 400 Bad Request без обращения к сервису.
+*/
+
+func TestHashHandlersMultihash(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("test"), Encoding: "base58", Multihash: true}).
+		Return(&pb.HashResponse{Hash: "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w", Algorithm: "sha256", Created: true}, nil)
+	hashingClientMock.On("GetHashMetadata", mock.Anything, &pb.HashLookupRequest{Hash: "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w", Multihash: true}).
+		Return(&pb.HashMetadata{Hash: "1220testhash", Algorithm: "sha256"}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash?encoding=base58&multihash=true", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w", rr.Body.String())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hashes/{hash}/meta", gw.GetHashMetadataHandler)
+
+	req, err = http.NewRequest("GET", "/hashes/QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w/meta?multihash=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"hash":"1220testhash"`)
+
+	req, err = http.NewRequest("POST", "/checkhash?multihash=maybe", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CheckHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	hashingClientMock.AssertNotCalled(t, "CheckHash", mock.Anything, mock.Anything)
+}
+
+/*
+Этот тест проверяет, что шлюз с CanonicalJSON включает канонический JSON для тел application/json,
+параметр canonical_json имеет приоритет над Content-Type, а ошибка INVALID_JSON возвращается как 400.
+*/
+
+func TestCreateHashHandlerCanonicalJSON(t *testing.T) {
+	body := `{"b": 2, "a": 1}`
+	invalid := status.New(codes.InvalidArgument, "invalid JSON payload at offset 5: invalid character")
+	invalid, _ = invalid.WithDetails(&errdetails.ErrorInfo{Reason: "INVALID_JSON", Domain: "hashing"})
+
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte(body), ContentType: "application/json; charset=utf-8", CanonicalJson: true}).
+		Return(&pb.HashResponse{Hash: "canonicalhash", Created: true}, nil)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte(body), ContentType: "application/json; charset=utf-8"}).
+		Return(&pb.HashResponse{Hash: "rawhash", Created: true}, nil)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte(body), ContentType: "text/plain", CanonicalJson: true}).
+		Return(&pb.HashResponse{}, invalid.Err())
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+		CanonicalJSON: true,
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		code        int
+		body        string
+	}{
+		{"application/json", "/createhash", "application/json; charset=utf-8", http.StatusOK, "canonicalhash"},
+		{"disabled by parameter", "/createhash?canonical_json=false", "application/json; charset=utf-8", http.StatusOK, "rawhash"},
+		{"enabled by parameter", "/createhash?canonical_json=true", "text/plain", http.StatusBadRequest, ""},
+		{"invalid parameter", "/createhash?canonical_json=yes", "text/plain", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tt.contentType)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.name)
+		if tt.body != "" {
+			assert.Equal(t, tt.body, rr.Body.String(), tt.name)
+		}
+	}
+	hashingClientMock.AssertNumberOfCalls(t, "CreateHash", 3)
+}
+
+/*
+Этот тест проверяет, что query-параметры normalize, crlf, trim и case_fold передаются в Hashing Service,
+а шаги нормализации возвращаются в JSON-ответе CheckHashHandler и заголовке X-Hash-Normalization.
+*/
+
+func TestHashHandlersNormalization(t *testing.T) {
+	normalization := &pb.TextNormalization{Form: "nfc", Crlf: true, Trim: true, CaseFold: true}
+
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("Hello\r\n"), Normalization: normalization}).
+		Return(&pb.HashResponse{Hash: "testhash", Created: true, Normalization: normalization}, nil)
+	hashingClientMock.On("CheckHash", mock.Anything, &pb.HashRequest{Data: []byte("Hello\r\n"), Normalization: &pb.TextNormalization{Trim: true}}).
+		Return(&pb.CheckHashResponse{Exists: true, Hash: "testhash", Algorithm: "sha256", Normalization: &pb.TextNormalization{Trim: true}}, nil)
+	hashingClientMock.On("GetHash", mock.Anything, &pb.HashRequest{Payload: "testhash"}).
+		Return(&pb.HashResponse{Hash: "testhash", Payload: []byte("hello"), Normalization: normalization}, nil)
+
+	gw := &GatewayService{HashingClient: hashingClientMock}
+
+	req := httptest.NewRequest("POST", "/createhash?normalize=nfc&crlf=true&trim=1&case_fold=true", strings.NewReader("Hello\r\n"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "testhash", rr.Body.String())
+
+	req = httptest.NewRequest("POST", "/checkhash?trim=true&crlf=false", strings.NewReader("Hello\r\n"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CheckHashHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"exists":true,"hash":"testhash","algorithm":"sha256","normalization":{"trim":true}}`, rr.Body.String())
+
+	req = httptest.NewRequest("POST", "/gethash", strings.NewReader("testhash"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.GetHashHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "crlf,casefold,nfc,trim", rr.Header().Get("X-Hash-Normalization"))
+
+	req = httptest.NewRequest("POST", "/createhash?trim=maybe", strings.NewReader("Hello"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	hashingClientMock.AssertExpectations(t)
+}
+
+/*
+Этот тест проверяет, что бинарное тело запроса передается в CreateHash как bytes без искажений,
+а GetHashHandler возвращает данные байт в байт с исходным Content-Type.
+*/
+
+func TestBinaryPayloadHandlers(t *testing.T) {
+	data := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe}
+
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, mock.MatchedBy(func(req *pb.HashRequest) bool {
+		return bytes.Equal(req.GetData(), data) && req.GetContentType() == "image/png"
+	})).Return(&pb.HashResponse{Hash: "testhash"}, nil)
+	hashingClientMock.On("GetHash", mock.Anything, mock.Anything).
+		Return(&pb.HashResponse{Hash: "testhash", Payload: data, ContentType: "image/png"}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "image/png")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(gw.CreateHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "testhash", rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/gethash", strings.NewReader("testhash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(gw.GetHashHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, data, rr.Body.Bytes())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+}
+
+/*
+Этот тест проверяет, что GetHashMetadataHandler берет хеш из пути /hashes/{hash}/meta, возвращает метаданные
+в JSON, а для несуществующего хеша отвечает 404 Not Found.
+*/
+
+func TestGetHashMetadataHandler(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("GetHashMetadata", mock.Anything, &pb.HashLookupRequest{Hash: "testhash"}).Return(&pb.HashMetadata{
+		Hash:        "testhash",
+		Algorithm:   "sha256",
+		ContentType: "text/plain",
+		Size:        4,
+		CreatedAt:   timestamppb.New(createdAt),
+		ReadCount:   0,
+	}, nil)
+	hashingClientMock.On("GetHashMetadata", mock.Anything, &pb.HashLookupRequest{Hash: "missing"}).
+		Return(&pb.HashMetadata{}, status.Error(codes.NotFound, "hash not found"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	// Обработчик вызываем через ServeMux, чтобы заполнить {hash} из пути
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hashes/{hash}/meta", gw.GetHashMetadataHandler)
+
+	req, err := http.NewRequest("GET", "/hashes/testhash/meta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"hash":"testhash","algorithm":"sha256","content_type":"text/plain","size":4,"created_at":"2024-03-01T12:00:00Z","read_count":0,"references":0}`, rr.Body.String())
+
+	req, err = http.NewRequest("GET", "/hashes/missing/meta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+/*
+Этот тест проверяет, что неизвестный алгоритм (ошибка InvalidArgument от Hashing Service) возвращается
+клиенту как 400 Bad Request с понятным сообщением.
+*/
+
+func TestCreateHashHandlerUnknownAlgorithm(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, mock.Anything).
+		Return(&pb.HashResponse{}, status.Error(codes.InvalidArgument, `unknown hash algorithm "md5"`))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash?algorithm=md5", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(gw.CreateHashHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `unknown hash algorithm "md5"`)
+}
+
+/*
+Этот тест проверяет, что query-параметр ttl передается в CreateHash как Duration, а некорректное
+значение отклоняется с 400 Bad Request без вызова Hashing Service.
+*/
+
+func TestCreateHashHandlerTTL(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("test"), Ttl: durationpb.New(24 * time.Hour)}).
+		Return(&pb.HashResponse{Hash: "testhash", Algorithm: "sha256"}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+	handler := http.HandlerFunc(gw.CreateHashHandler)
+
+	req, err := http.NewRequest("POST", "/createhash?ttl=24h", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "testhash", rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/createhash?ttl=tomorrow", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	hashingClientMock.AssertNumberOfCalls(t, "CreateHash", 1)
+}
+
+/*
+Этот тест проверяет, что TouchHashHandler берет хеш из пути /hashes/{hash}/touch, передает ttl
+и возвращает обновленные метаданные со сроком жизни.
+*/
+
+func TestTouchHashHandler(t *testing.T) {
+	expiresAt := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("TouchHash", mock.Anything, &pb.TouchHashRequest{Hash: "testhash", Ttl: durationpb.New(time.Hour)}).Return(&pb.HashMetadata{
+		Hash:      "testhash",
+		Algorithm: "sha256",
+		Size:      4,
+		ExpiresAt: timestamppb.New(expiresAt),
+		Ttl:       durationpb.New(time.Hour),
+	}, nil)
+	hashingClientMock.On("TouchHash", mock.Anything, &pb.TouchHashRequest{Hash: "missing"}).
+		Return(&pb.HashMetadata{}, status.Error(codes.NotFound, "hash not found"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hashes/{hash}/touch", gw.TouchHashHandler)
+
+	req, err := http.NewRequest("POST", "/hashes/testhash/touch?ttl=1h", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"hash":"testhash","algorithm":"sha256","content_type":"","size":4,"read_count":0,"references":0,"expires_at":"2024-04-01T12:00:00Z","ttl_seconds":3600}`, rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/hashes/missing/touch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+/*
+Этот тест проверяет, что ReleaseHashHandler передает владельца в ReleaseHash и возвращает число оставшихся
+ссылок, требует owner и отвечает 400 Bad Request, если владелец не брал ссылку на хеш.
+*/
+
+func TestReleaseHashHandler(t *testing.T) {
+	releasedAt := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("ReleaseHash", mock.Anything, &pb.ReleaseHashRequest{Hash: "testhash", Owner: "billing"}).Return(&pb.ReleaseHashResponse{
+		Hash:       "testhash",
+		ReleasedAt: timestamppb.New(releasedAt),
+	}, nil)
+	hashingClientMock.On("ReleaseHash", mock.Anything, &pb.ReleaseHashRequest{Hash: "testhash", Owner: "search"}).
+		Return(&pb.ReleaseHashResponse{}, status.Error(codes.FailedPrecondition, "owner holds no reference"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hashes/{hash}/release", gw.ReleaseHashHandler)
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"/hashes/testhash/release?owner=billing", http.StatusOK, `{"hash":"testhash","references":0,"released_at":"2024-04-01T12:00:00Z"}`},
+		{"/hashes/testhash/release?owner=search", http.StatusBadRequest, ""},
+		{"/hashes/testhash/release", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.wantCode, rr.Code, tt.url)
+		if tt.wantBody != "" {
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+		}
+	}
+	hashingClientMock.AssertNumberOfCalls(t, "ReleaseHash", 2)
+}
+
+/*
+Этот тест проверяет, что DeleteHashHandler доступен только администратору, передает tombstone
+и отвечает 204 No Content, а для несуществующего хеша - 404 Not Found.
+*/
+
+func TestDeleteHashHandler(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("DeleteHash", mock.Anything, &pb.DeleteHashRequest{Hash: "testhash", Tombstone: true}).
+		Return(&pb.DeleteHashResponse{Hash: "testhash", Tombstone: true}, nil)
+	hashingClientMock.On("DeleteHash", mock.Anything, &pb.DeleteHashRequest{Hash: "missing"}).
+		Return(&pb.DeleteHashResponse{}, status.Error(codes.NotFound, "hash not found"))
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+		Auth:          NewAuthenticator(),
+	}
+	gw.Auth.AddToken("admin-token", Principal{Name: "admin", Permissions: []Permission{PermissionAdmin}})
+	gw.Auth.AddToken("user-token", Principal{Name: "user"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /hashes/{hash}", gw.RequirePermission(PermissionAdmin, gw.DeleteHashHandler))
+
+	tests := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/hashes/testhash?tombstone=true", "", http.StatusUnauthorized},
+		{"/hashes/testhash?tombstone=true", "user-token", http.StatusForbidden},
+		{"/hashes/testhash?tombstone=yes-please", "admin-token", http.StatusBadRequest},
+		{"/hashes/testhash?tombstone=true", "admin-token", http.StatusNoContent},
+		{"/hashes/missing", "admin-token", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("DELETE", tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		rr := httptest.NewRecorder()
+		gw.Authenticate(mux).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, tt.path+" "+tt.token)
+	}
+
+	hashingClientMock.AssertNumberOfCalls(t, "DeleteHash", 2)
+}
+
+/*
+Этот тест проверяет, что запрос к удаленному хешу, от которого осталось надгробие (NotFound с причиной
+HASH_DELETED), возвращается клиенту как 410 Gone.
+*/
+
+func TestGetHashHandlerDeleted(t *testing.T) {
+	st, err := status.New(codes.NotFound, "hash deleted").WithDetails(&errdetails.ErrorInfo{Reason: reasonHashDeleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("GetHash", mock.Anything, mock.Anything).Return(&pb.HashResponse{}, st.Err())
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/gethash", strings.NewReader("testhash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(gw.GetHashHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Contains(t, rr.Body.String(), "hash deleted")
+}
+
+/*
+Этот тест проверяет, что ListHashesHandler передает параметры запроса в ListHashes и возвращает страницу
+хешей с курсором следующей страницы, а некорректные параметры отклоняет с 400 Bad Request.
+*/
+
+func TestListHashesHandler(t *testing.T) {
+	createdAfter := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("ListHashes", mock.Anything, &pb.ListHashesRequest{
+		Cursor:       "next",
+		Limit:        2,
+		Prefix:       "ab",
+		Algorithm:    "sha256",
+		CreatedAfter: timestamppb.New(createdAfter),
+	}).Return(&pb.ListHashesResponse{
+		Hashes: []*pb.HashMetadata{
+			{Hash: "ab01", Algorithm: "sha256", Size: 4},
+			{Hash: "ab02", Algorithm: "sha256", Size: 5},
+		},
+		NextCursor: "after-ab02",
+	}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+		Auth:          NewAuthenticator(),
+	}
+	gw.Auth.AddToken("admin-token", Principal{Name: "admin", Permissions: []Permission{PermissionAdmin}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hashes", gw.RequirePermission(PermissionAdmin, gw.ListHashesHandler))
+
+	req, err := http.NewRequest("GET", "/hashes?cursor=next&limit=2&prefix=ab&algorithm=sha256&created_after=2024-03-01T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-token")
+
+	rr := httptest.NewRecorder()
+	gw.Authenticate(mux).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"hashes":[
+		{"hash":"ab01","algorithm":"sha256","content_type":"","size":4,"read_count":0,"references":0},
+		{"hash":"ab02","algorithm":"sha256","content_type":"","size":5,"read_count":0,"references":0}
+	],"next_cursor":"after-ab02"}`, rr.Body.String())
+
+	for _, path := range []string{"/hashes?limit=many", "/hashes?created_before=yesterday"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer admin-token")
+
+		rr := httptest.NewRecorder()
+		gw.Authenticate(mux).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+
+	// Без токена список недоступен
+	req, err = http.NewRequest("GET", "/hashes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	gw.Authenticate(mux).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	hashingClientMock.AssertNumberOfCalls(t, "ListHashes", 1)
+}
+
+/*
+Этот тест проверяет, что GetHashHandler возвращает полный хеш по сокращенному в заголовке X-Hash,
+а неоднозначное сокращение возвращается клиенту как 409 Conflict со списком кандидатов.
+*/
+
+func TestGetHashHandlerAbbreviated(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, `ambiguous hash prefix "abcdef", candidates: abcdef01, abcdef02`).
+		WithDetails(&errdetails.ErrorInfo{Reason: reasonAmbiguousPrefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("GetHash", mock.Anything, &pb.HashRequest{Payload: "9f86d0"}).
+		Return(&pb.HashResponse{Hash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", Algorithm: "sha256", Payload: []byte("test")}, nil)
+	hashingClientMock.On("GetHash", mock.Anything, &pb.HashRequest{Payload: "abcdef"}).Return(&pb.HashResponse{}, st.Err())
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+	handler := http.HandlerFunc(gw.GetHashHandler)
+
+	req, err := http.NewRequest("POST", "/gethash", strings.NewReader("9f86d0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", rr.Header().Get("X-Hash"))
+	assert.Equal(t, "test", rr.Body.String())
+
+	req, err = http.NewRequest("POST", "/gethash", strings.NewReader("abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "abcdef01, abcdef02")
+}
+
 /*
 Unit-тесты могут быть написаны для каждого из ваших обработчиков HTTP (CheckHashHandler,
 GetHashHandler, CreateHashHandler). Эти тесты могут проверять, что обработчики правильно
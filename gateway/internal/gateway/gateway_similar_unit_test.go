@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что FindSimilarHandler передает тело и параметры в FindSimilar и возвращает найденные
+хеши в JSON, некорректные параметры и ошибки параметров Hashing Service возвращаются как 400, а /createhash
+передает параметр simhash.
+*/
+
+func TestFindSimilarHandler(t *testing.T) {
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("FindSimilar", mock.Anything, &pb.FindSimilarRequest{Data: []byte("Hello, world!"), MaxDistance: 5, Limit: 2}).
+		Return(&pb.FindSimilarResponse{
+			Fingerprint: "0123456789abcdef",
+			Matches: []*pb.SimilarHash{
+				{Hash: "hash1", Algorithm: "sha256"},
+				{Hash: "hash2", Algorithm: "sha256", Distance: 4, Key: "emails", KeyVersion: 2},
+			},
+		}, nil)
+	hashingClientMock.On("FindSimilar", mock.Anything, &pb.FindSimilarRequest{Data: []byte("Hello, world!"), Normalization: &pb.TextNormalization{Trim: true}}).
+		Return(&pb.FindSimilarResponse{Fingerprint: "0123456789abcdef"}, nil)
+	hashingClientMock.On("FindSimilar", mock.Anything, &pb.FindSimilarRequest{Data: []byte("Hello, world!"), MaxDistance: 9}).
+		Return(&pb.FindSimilarResponse{}, status.Error(codes.InvalidArgument, "max_distance must be between 0 and 7, 0 selects the default of 3"))
+	hashingClientMock.On("CreateHash", mock.Anything, &pb.HashRequest{Data: []byte("Hello, world!"), Simhash: true}).
+		Return(&pb.HashResponse{Hash: "hash1", Algorithm: "sha256", Created: true}, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/similar", gw.FindSimilarHandler)
+	mux.HandleFunc("/createhash", gw.CreateHashHandler)
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"/similar?max_distance=5&limit=2", http.StatusOK, `{"fingerprint":"0123456789abcdef","matches":[{"hash":"hash1","algorithm":"sha256","distance":0},{"hash":"hash2","algorithm":"sha256","distance":4,"key":"emails","key_version":2}]}`},
+		{"/similar?trim=true", http.StatusOK, `{"fingerprint":"0123456789abcdef","matches":[]}`},
+		{"/similar?max_distance=9", http.StatusBadRequest, ""},
+		{"/similar?limit=ten", http.StatusBadRequest, ""},
+		{"/createhash?simhash=true", http.StatusOK, ""},
+		{"/createhash?simhash=maybe", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.url, strings.NewReader("Hello, world!"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.wantCode, rr.Code, tt.url)
+		if tt.wantBody != "" {
+			assert.JSONEq(t, tt.wantBody, rr.Body.String(), tt.url)
+		}
+	}
+
+	req, err := http.NewRequest("GET", "/similar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	hashingClientMock.AssertExpectations(t)
+}
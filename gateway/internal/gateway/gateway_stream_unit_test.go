@@ -0,0 +1,135 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+)
+
+/*
+hashChunkClientStream - фиктивный поток CreateHashStream на стороне клиента. Он не хранит полученные
+данные, а только считает их, запоминая алгоритм и размер самой большой части.
+*/
+type hashChunkClientStream struct {
+	grpc.ClientStream
+	algorithm string
+	messages  int
+	total     int
+	maxChunk  int
+	data      strings.Builder
+	keepData  bool
+}
+
+func (s *hashChunkClientStream) Send(chunk *pb.HashChunk) error {
+	if s.messages == 0 {
+		s.algorithm = chunk.GetAlgorithm()
+	}
+	s.messages++
+	s.total += len(chunk.GetData())
+	s.maxChunk = max(s.maxChunk, len(chunk.GetData()))
+	if s.keepData {
+		s.data.Write(chunk.GetData())
+	}
+	return nil
+}
+
+func (s *hashChunkClientStream) CloseAndRecv() (*pb.HashResponse, error) {
+	return &pb.HashResponse{Hash: "testhash", Algorithm: s.algorithm}, nil
+}
+
+// zeroReader бесконечно отдает нули, не выделяя память.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+/*
+Этот тест проверяет, что CreateHashStreamHandler передает тело запроса и алгоритм в поток без изменений.
+*/
+
+func TestCreateHashStreamHandler(t *testing.T) {
+	stream := &hashChunkClientStream{keepData: true}
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHashStream", mock.Anything).Return(stream, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash/stream?algorithm=sha512", strings.NewReader("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(gw.CreateHashStreamHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "testhash", rr.Body.String())
+	assert.Equal(t, "sha512", rr.Header().Get("X-Hash-Algorithm"))
+	assert.Equal(t, "test", stream.data.String())
+
+	// GET-запрос не поддерживается
+	req, err = http.NewRequest("GET", "/createhash/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+/*
+Этот тест отправляет через CreateHashStreamHandler 256 МБ и проверяет, что gateway не буферизует тело
+запроса: данные уходят частями не больше streamChunkSize, а выделенная память намного меньше их объема.
+*/
+
+func TestCreateHashStreamHandlerMemoryBounded(t *testing.T) {
+	const size = 256 << 20
+
+	stream := &hashChunkClientStream{}
+	hashingClientMock := new(HashingClientMock)
+	hashingClientMock.On("CreateHashStream", mock.Anything).Return(stream, nil)
+
+	gw := &GatewayService{
+		HashingClient: hashingClientMock,
+	}
+
+	req, err := http.NewRequest("POST", "/createhash/stream", io.LimitReader(zeroReader{}, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(gw.CreateHashStreamHandler)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	handler.ServeHTTP(rr, req)
+
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, size, stream.total)
+	assert.LessOrEqual(t, stream.maxChunk, streamChunkSize)
+
+	allocated := after.TotalAlloc - before.TotalAlloc
+	assert.Less(t, allocated, uint64(size/8), "allocated %d bytes", allocated)
+}
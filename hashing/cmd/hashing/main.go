@@ -1,22 +1,162 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"final-project-kodzimo-hashing/internal/hashing"
 	"final-project-kodzimo-hashing/internal/storage"
 	pb "final-project-kodzimo-shared/proto"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"google.golang.org/grpc"
 )
 
 func main() {
-	redisClient, err := storage.ConnectToRedis()
+	// Хранилище выбирается переменной окружения STORAGE_BACKEND (redis, memory или bolt)
+	store, err := storage.ConnectToStore()
 	if err != nil {
-		log.Fatalf("failed to connect to Redis: %v", err)
+		log.Fatalf("failed to connect to storage: %v", err)
 	}
+	defer store.Close()
 
-	hashingService := hashing.NewHashingService(redisClient)
+	var opts []hashing.Option
+	if size := os.Getenv("MAX_STORED_PAYLOAD_SIZE"); size != "" {
+		maxSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			log.Fatalf("Error converting MAX_STORED_PAYLOAD_SIZE to integer: %v", err)
+		}
+		opts = append(opts, hashing.WithMaxStoredPayloadSize(maxSize))
+	}
+
+	// Политика хранения: срок жизни хешей, для которых TTL не указан в запросе (например, 720h)
+	if ttl := os.Getenv("DEFAULT_TTL"); ttl != "" {
+		defaultTTL, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Error parsing DEFAULT_TTL: %v", err)
+		}
+		opts = append(opts, hashing.WithDefaultTTL(defaultTTL))
+	}
+
+	// Фильтр Блума перед CheckHash (только если в хранилище пишет один экземпляр сервиса)
+	if enabled, _ := strconv.ParseBool(os.Getenv("BLOOM_FILTER")); enabled {
+		opts = append(opts, hashing.WithHashFilter(hashing.DefaultFilterCapacity, hashing.DefaultFilterFalsePositiveRate))
+	}
+
+	// Кеш GetHash включается ограничением по числу записей и/или по суммарному размеру в байтах
+	cacheEntries, cacheBytes := os.Getenv("CACHE_MAX_ENTRIES"), os.Getenv("CACHE_MAX_BYTES")
+	if cacheEntries != "" || cacheBytes != "" {
+		var maxEntries, maxBytes int64
+		if cacheEntries != "" {
+			if maxEntries, err = strconv.ParseInt(cacheEntries, 10, 0); err != nil {
+				log.Fatalf("Error converting CACHE_MAX_ENTRIES to integer: %v", err)
+			}
+		}
+		if cacheBytes != "" {
+			if maxBytes, err = strconv.ParseInt(cacheBytes, 10, 64); err != nil {
+				log.Fatalf("Error converting CACHE_MAX_BYTES to integer: %v", err)
+			}
+		}
+		opts = append(opts, hashing.WithPayloadCache(int(maxEntries), maxBytes))
+	}
+
+	// Льготный период перед удалением хешей, на которые не осталось ссылок (например, 1h)
+	if grace := os.Getenv("GC_GRACE_PERIOD"); grace != "" {
+		gracePeriod, err := time.ParseDuration(grace)
+		if err != nil {
+			log.Fatalf("Error parsing GC_GRACE_PERIOD: %v", err)
+		}
+		opts = append(opts, hashing.WithGCGracePeriod(gracePeriod))
+	}
+
+	// Срок хранения надгробий удаленных хешей (например, 720h); 0 - надгробия хранятся бессрочно
+	if retention := os.Getenv("TOMBSTONE_RETENTION"); retention != "" {
+		tombstoneRetention, err := time.ParseDuration(retention)
+		if err != nil {
+			log.Fatalf("Error parsing TOMBSTONE_RETENTION: %v", err)
+		}
+		opts = append(opts, hashing.WithTombstoneRetention(tombstoneRetention))
+	}
+
+	// Хеширование паролей: алгоритм (argon2id, bcrypt или scrypt) и параметры в нотации PHC, например m=65536,t=3,p=2
+	passwordAlgorithm, passwordParams := os.Getenv("PASSWORD_ALGORITHM"), os.Getenv("PASSWORD_PARAMS")
+	if passwordAlgorithm != "" || passwordParams != "" {
+		policy, err := hashing.ParsePasswordPolicy(passwordAlgorithm, passwordParams)
+		if err != nil {
+			log.Fatalf("Error parsing password policy: %v", err)
+		}
+		opts = append(opts, hashing.WithPasswordPolicy(policy))
+	}
+	// Сколько хешей паролей вычисляется одновременно: каждый занимает память, заданную политикой
+	if concurrency := os.Getenv("PASSWORD_CONCURRENCY"); concurrency != "" {
+		passwordConcurrency, err := strconv.Atoi(concurrency)
+		if err != nil || passwordConcurrency <= 0 {
+			log.Fatalf("Error parsing PASSWORD_CONCURRENCY: must be a positive integer, got %q", concurrency)
+		}
+		opts = append(opts, hashing.WithPasswordConcurrency(passwordConcurrency))
+	}
+
+	// Записи первых версий сервиса переводятся в текущий формат в фоне; до этого Get переводит их при чтении
+	if migrator, ok := store.(storage.LegacyMigrator); ok {
+		go migrateLegacy(migrator)
+	}
+
+	// Хранилища, которым нужна очистка истекших записей и индекса, периодически очищаются здесь
+	if purger, ok := store.(storage.ExpiredPurger); ok {
+		go purgeExpired(purger)
+	}
+
+	// Данные шифруются ключами из ENCRYPTION_KEYRING_FILE или ENCRYPTION_KEYS, если они заданы
+	keyring, err := storage.KeyringFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load encryption keyring: %v", err)
+	}
+	var payloads storage.Store = store
+	if keyring != nil {
+		payloads = storage.Encrypt(store, keyring)
+		// Записи, зашифрованные старыми ключами или сохраненные без шифрования, переводятся на основной ключ в фоне
+		go reencrypt(store, keyring)
+	}
+
+	// Данные сжимаются кодеком из COMPRESSION; записи, сжатые ранее, читаются при любом значении
+	codec, threshold, err := storage.CompressionFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure compression: %v", err)
+	}
+	compressed, err := storage.Compress(payloads, codec, threshold)
+	if err != nil {
+		log.Fatalf("failed to configure compression: %v", err)
+	}
+
+	hashingService := hashing.NewHashingService(compressed, opts...)
+
+	// Фильтр строится по хранилищу в фоне; пока он не готов, CheckHash обращается к хранилищу
+	go func() {
+		if err := hashingService.RebuildFilter(context.Background()); err != nil {
+			log.Printf("failed to build hash filter: %v", err)
+		}
+	}()
+
+	go collectGarbage(hashingService)
+
+	// Метрики публикуются через expvar на /debug/vars по адресу METRICS_ADDR (например, :9090)
+	expvar.Publish("bloom_filter", expvar.Func(func() any {
+		stats, _ := hashingService.FilterStats()
+		return stats
+	}))
+	expvar.Publish("payload_cache", expvar.Func(func() any {
+		stats, _ := hashingService.CacheStats()
+		return stats
+	}))
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			log.Printf("metrics server stopped: %v", http.ListenAndServe(addr, nil))
+		}()
+	}
 
 	/*
 		Этот код (ниже) создает gRPC сервер и регистрирует ваш Hashing Service на этом сервере.
@@ -27,9 +167,92 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	// Административные методы требуют служебного токена HASHING_ADMIN_TOKEN (без него они выключены),
+	// пространство имен запроса берется из gRPC-метаданных вызовов с этим токеном
+	adminToken := os.Getenv("HASHING_ADMIN_TOKEN")
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(hashing.AdminUnaryInterceptor(adminToken), hashing.NamespaceUnaryInterceptor),
+		grpc.ChainStreamInterceptor(hashing.AdminStreamInterceptor(adminToken), hashing.NamespaceStreamInterceptor),
+	)
 	pb.RegisterHashingServer(s, &hashing.Server{HashingService: hashingService})
+	pb.RegisterHashingAdminServer(s, &hashing.AdminServer{HashingService: hashingService})
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+func migrateLegacy(migrator storage.LegacyMigrator) {
+	migrated, err := migrator.MigrateLegacy(context.Background())
+	if err != nil {
+		log.Printf("failed to migrate legacy hashes: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("migrated %d legacy hashes", migrated)
+	}
+}
+
+// Интервал фоновой очистки записей с истекшим сроком жизни
+const purgeInterval = time.Minute
+
+func purgeExpired(purger storage.ExpiredPurger) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := purger.PurgeExpired(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("failed to purge expired hashes: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("purged %d expired hashes", purged)
+		}
+	}
+}
+
+// Интервал сборки мусора - удаления хешей без ссылок
+const gcInterval = time.Minute
+
+func collectGarbage(hashingService *hashing.HashingService) {
+	ticker := time.NewTicker(gcInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		collected, err := hashingService.CollectGarbage(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("failed to collect unreferenced hashes: %v", err)
+			continue
+		}
+		if collected > 0 {
+			log.Printf("collected %d unreferenced hashes", collected)
+		}
+	}
+}
+
+// Размер страницы и пауза между страницами фонового перешифрования, чтобы не нагружать хранилище
+const (
+	reencryptBatch = 100
+	reencryptPause = 100 * time.Millisecond
+)
+
+func reencrypt(store storage.Store, keyring *storage.Keyring) {
+	log.Printf("encryption enabled with primary key %q, keys %v", keyring.Primary, keyring.KeyIDs())
+	// Секреты ключей хеширования немногочисленны и перешифровываются сразу
+	keys, err := storage.ReencryptMACKeys(context.Background(), store, keyring)
+	if err != nil {
+		log.Printf("failed to re-encrypt hashing keys: %v", err)
+	} else if keys > 0 {
+		log.Printf("re-encrypted %d hashing keys with key %q", keys, keyring.Primary)
+	}
+
+	stats, err := storage.Reencrypt(context.Background(), store, keyring, reencryptBatch, func(storage.ReencryptStats) error {
+		time.Sleep(reencryptPause)
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to re-encrypt payloads: %v", err)
+		return
+	}
+	log.Printf("re-encryption finished: re-encrypted %d payloads with key %q, encrypted %d unencrypted payloads",
+		stats.Reencrypted, keyring.Primary, stats.Encrypted)
+}
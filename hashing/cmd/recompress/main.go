@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"final-project-kodzimo-hashing/internal/storage"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+/*
+Команда recompress перепаковывает данные уже сохраненных хешей кодеком из COMPRESSION (или флага -codec):
+сжимает записи, сохраненные до включения сжатия, и пересжимает записи другим кодеком. Хранилище выбирается
+теми же переменными окружения, что и в Hashing Service. Команда работает рядом с запущенным сервисом:
+записи обходятся страницами с паузой между ними, а данные заменяются атомарно.
+
+	docker compose exec hashing ./recompress -batch 500 -pause 50ms
+*/
+
+func main() {
+	codec, threshold, err := storage.CompressionFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure compression: %v", err)
+	}
+
+	flag.StringVar(&codec, "codec", codec, "codec to recompress payloads with: gzip, zstd or empty to decompress")
+	flag.IntVar(&threshold, "threshold", threshold, "minimal payload size in bytes to compress")
+	batch := flag.Int("batch", 100, "number of records per page")
+	pause := flag.Duration("pause", 100*time.Millisecond, "pause between pages")
+	flag.Parse()
+	if *batch <= 0 {
+		log.Fatalf("-batch must be positive, got %d", *batch)
+	}
+
+	store, err := storage.ConnectToStore()
+	if err != nil {
+		log.Fatalf("failed to connect to storage: %v", err)
+	}
+	defer store.Close()
+
+	// Сжимаются расшифрованные данные, поэтому при включенном шифровании обходится зашифрованное хранилище
+	var payloads storage.Store = store
+	keyring, err := storage.KeyringFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load encryption keyring: %v", err)
+	}
+	if keyring != nil {
+		payloads = storage.Encrypt(store, keyring)
+	}
+
+	// По Ctrl+C обход останавливается после текущей записи; перепакованные записи остаются перепакованными
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("recompressing payloads with codec %q, threshold %d bytes", codec, threshold)
+	stats, err := storage.Recompress(ctx, payloads, codec, threshold, *batch, func(stats storage.RecompressStats) error {
+		log.Printf("scanned %d records, recompressed %d", stats.Scanned, stats.Recompressed)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*pause):
+			return nil
+		}
+	})
+	log.Printf("scanned %d records, recompressed %d: %d bytes -> %d bytes",
+		stats.Scanned, stats.Recompressed, stats.BytesBefore, stats.BytesAfter)
+	if err != nil {
+		log.Fatalf("recompression stopped: %v", err)
+	}
+}
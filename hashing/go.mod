@@ -5,12 +5,28 @@ go 1.22.1
 require google.golang.org/grpc v1.62.1
 
 require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/bits-and-blooms/bitset v1.2.0 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/multiformats/go-base32 v0.0.3 // indirect
+	github.com/multiformats/go-base36 v0.1.0 // indirect
+	github.com/spaolacci/murmur3 v1.1.0 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20220504180219-658193537a64 // indirect
 )
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
+	github.com/bits-and-blooms/bloom/v3 v3.0.1
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/gowebpki/jcs v1.0.1
 	github.com/golang/protobuf v1.5.3 // indirect
-	google.golang.org/protobuf v1.32.0 // indirect
+	github.com/klauspost/compress v1.18.0
+	github.com/mr-tron/base58 v1.2.0
+	github.com/multiformats/go-multibase v0.2.0
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/crypto v0.18.0
+	golang.org/x/text v0.14.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80
+	google.golang.org/protobuf v1.32.0
 )
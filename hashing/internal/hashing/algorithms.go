@@ -0,0 +1,147 @@
+package hashing
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+/*
+Реестр алгоритмов хеширования. По умолчанию в нем зарегистрированы SHA-256, SHA-512, SHA3-256,
+BLAKE2b (256 и 512 бит) и SHA-1 (только для совместимости со старыми системами). Дополнительные
+алгоритмы можно добавить через Register, не меняя код HashingService.
+*/
+
+// DefaultAlgorithm используется, если в запросе алгоритм не указан.
+const DefaultAlgorithm = "sha256"
+
+// ErrUnknownAlgorithm возвращается, если алгоритм с таким именем не зарегистрирован.
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+
+// Algorithm описывает зарегистрированный алгоритм хеширования.
+type Algorithm struct {
+	Name string
+	New  func() hash.Hash
+	// NewKeyed создает хеш с секретным ключом для алгоритмов со встроенным режимом MAC (BLAKE2b).
+	// Если он не задан, хеш с ключом считается как HMAC.
+	NewKeyed func(key []byte) hash.Hash
+}
+
+// Keyed возвращает конструктор хеша с секретным ключом key.
+func (a Algorithm) Keyed(key []byte) func() hash.Hash {
+	if a.NewKeyed != nil {
+		return func() hash.Hash { return a.NewKeyed(key) }
+	}
+	return func() hash.Hash { return hmac.New(a.New, key) }
+}
+
+// Sum вычисляет хеш от data и возвращает его в виде строки шестнадцатеричных символов.
+func (a Algorithm) Sum(data []byte) string {
+	h := a.New()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+type AlgorithmRegistry struct {
+	mu         sync.RWMutex
+	algorithms map[string]Algorithm
+}
+
+func NewAlgorithmRegistry() *AlgorithmRegistry {
+	r := &AlgorithmRegistry{algorithms: make(map[string]Algorithm)}
+
+	r.Register("sha1", sha1.New)
+	r.Register("sha256", sha256.New)
+	r.Register("sha512", sha512.New)
+	r.Register("sha3-256", sha3.New256)
+	// Ошибка BLAKE2b возможна только при ключе длиннее 64 байт, а секреты ключей короче
+	r.RegisterKeyed("blake2b-256", func(key []byte) hash.Hash {
+		h, _ := blake2b.New256(key)
+		return h
+	})
+	r.RegisterKeyed("blake2b-512", func(key []byte) hash.Hash {
+		h, _ := blake2b.New512(key)
+		return h
+	})
+
+	return r
+}
+
+// Register добавляет алгоритм в реестр или заменяет уже зарегистрированный с тем же именем.
+func (r *AlgorithmRegistry) Register(name string, newHash func() hash.Hash) {
+	name = normalizeAlgorithmName(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.algorithms[name] = Algorithm{Name: name, New: newHash}
+}
+
+// RegisterKeyed добавляет алгоритм со встроенным режимом MAC: без ключа он вызывается с пустым ключом.
+func (r *AlgorithmRegistry) RegisterKeyed(name string, newKeyed func(key []byte) hash.Hash) {
+	name = normalizeAlgorithmName(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.algorithms[name] = Algorithm{
+		Name:     name,
+		New:      func() hash.Hash { return newKeyed(nil) },
+		NewKeyed: newKeyed,
+	}
+}
+
+// Lookup возвращает алгоритм по имени. Пустое имя означает DefaultAlgorithm.
+func (r *AlgorithmRegistry) Lookup(name string) (Algorithm, error) {
+	name = normalizeAlgorithmName(name)
+	if name == "" {
+		name = DefaultAlgorithm
+	}
+
+	r.mu.RLock()
+	algorithm, ok := r.algorithms[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Algorithm{}, fmt.Errorf("%w %q, supported: %s", ErrUnknownAlgorithm, name, strings.Join(r.Names(), ", "))
+	}
+	return algorithm, nil
+}
+
+// isDigestSize сообщает, дает ли какой-нибудь из алгоритмов реестра хеш длиной size байт.
+func (r *AlgorithmRegistry) isDigestSize(size int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, algorithm := range r.algorithms {
+		if algorithm.New().Size() == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Names возвращает отсортированный список зарегистрированных алгоритмов.
+func (r *AlgorithmRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.algorithms))
+	for name := range r.algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalizeAlgorithmName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -11,7 +11,7 @@ type Server struct {
 	HashingService *HashingService
 }
 
-func (s *Server) CheckHash(ctx context.Context, in *pb.HashRequest) (*pb.HashResponse, error) {
+func (s *Server) CheckHash(ctx context.Context, in *pb.HashRequest) (*pb.CheckHashResponse, error) {
 	return s.HashingService.CheckHash(ctx, in)
 }
 
@@ -23,6 +23,46 @@ func (s *Server) CreateHash(ctx context.Context, in *pb.HashRequest) (*pb.HashRe
 	return s.HashingService.CreateHash(ctx, in)
 }
 
+func (s *Server) CreateHashStream(stream pb.Hashing_CreateHashStreamServer) error {
+	return s.HashingService.CreateHashStream(stream)
+}
+
+func (s *Server) GetHashMetadata(ctx context.Context, in *pb.HashLookupRequest) (*pb.HashMetadata, error) {
+	return s.HashingService.GetHashMetadata(ctx, in)
+}
+
+func (s *Server) TouchHash(ctx context.Context, in *pb.TouchHashRequest) (*pb.HashMetadata, error) {
+	return s.HashingService.TouchHash(ctx, in)
+}
+
+func (s *Server) DeleteHash(ctx context.Context, in *pb.DeleteHashRequest) (*pb.DeleteHashResponse, error) {
+	return s.HashingService.DeleteHash(ctx, in)
+}
+
+func (s *Server) ListHashes(ctx context.Context, in *pb.ListHashesRequest) (*pb.ListHashesResponse, error) {
+	return s.HashingService.ListHashes(ctx, in)
+}
+
+func (s *Server) ReleaseHash(ctx context.Context, in *pb.ReleaseHashRequest) (*pb.ReleaseHashResponse, error) {
+	return s.HashingService.ReleaseHash(ctx, in)
+}
+
+func (s *Server) HashPassword(ctx context.Context, in *pb.HashPasswordRequest) (*pb.HashPasswordResponse, error) {
+	return s.HashingService.HashPassword(ctx, in)
+}
+
+func (s *Server) VerifyPassword(ctx context.Context, in *pb.VerifyPasswordRequest) (*pb.VerifyPasswordResponse, error) {
+	return s.HashingService.VerifyPassword(ctx, in)
+}
+
+func (s *Server) VerifyHash(ctx context.Context, in *pb.VerifyHashRequest) (*pb.VerifyHashResponse, error) {
+	return s.HashingService.VerifyHash(ctx, in)
+}
+
+func (s *Server) FindSimilar(ctx context.Context, in *pb.FindSimilarRequest) (*pb.FindSimilarResponse, error) {
+	return s.HashingService.FindSimilar(ctx, in)
+}
+
 // Вынесено в main.go
 //
 // func main() {
@@ -60,3 +100,41 @@ func (s *Server) CreateHash(ctx context.Context, in *pb.HashRequest) (*pb.HashRe
 метод в HashingService. Это позволяет вам разделить логику вашего приложения (в HashingService) и логику
 вашего gRPC сервера (в grpc-server.go), что делает ваш код более чистым и легким для понимания.
 */
+
+// AdminServer - реализация сервиса HashingAdmin для управления пространствами имен и ключами.
+type AdminServer struct {
+	pb.HashingAdminServer
+	HashingService *HashingService
+}
+
+func (s *AdminServer) CreateNamespace(ctx context.Context, in *pb.CreateNamespaceRequest) (*pb.Namespace, error) {
+	return s.HashingService.CreateNamespace(ctx, in)
+}
+
+func (s *AdminServer) GetNamespace(ctx context.Context, in *pb.GetNamespaceRequest) (*pb.Namespace, error) {
+	return s.HashingService.GetNamespace(ctx, in)
+}
+
+func (s *AdminServer) ListNamespaces(ctx context.Context, in *pb.ListNamespacesRequest) (*pb.ListNamespacesResponse, error) {
+	return s.HashingService.ListNamespaces(ctx, in)
+}
+
+func (s *AdminServer) DeleteNamespace(ctx context.Context, in *pb.DeleteNamespaceRequest) (*pb.DeleteNamespaceResponse, error) {
+	return s.HashingService.DeleteNamespace(ctx, in)
+}
+
+func (s *AdminServer) CreateKey(ctx context.Context, in *pb.CreateKeyRequest) (*pb.Key, error) {
+	return s.HashingService.CreateKey(ctx, in)
+}
+
+func (s *AdminServer) RotateKey(ctx context.Context, in *pb.RotateKeyRequest) (*pb.Key, error) {
+	return s.HashingService.RotateKey(ctx, in)
+}
+
+func (s *AdminServer) GetKey(ctx context.Context, in *pb.GetKeyRequest) (*pb.Key, error) {
+	return s.HashingService.GetKey(ctx, in)
+}
+
+func (s *AdminServer) ListKeys(ctx context.Context, in *pb.ListKeysRequest) (*pb.ListKeysResponse, error) {
+	return s.HashingService.ListKeys(ctx, in)
+}
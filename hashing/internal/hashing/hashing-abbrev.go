@@ -0,0 +1,74 @@
+package hashing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Сокращенные хеши, как в git. Вместо полного хеша в GetHash (и в остальных методах, которые ищут хеш)
+можно передать его начало длиной от MinAbbreviationLength символов. Если с этого начала начинается ровно
+один сохраненный хеш, используется он. Если таких хешей несколько, возвращается InvalidArgument с причиной
+ReasonAmbiguousPrefix и списком кандидатов. Поиск идет по индексу хранилища (HashStore.FindByPrefix),
+а не перебором всех ключей.
+*/
+
+// MinAbbreviationLength - минимальная длина сокращенного хеша.
+const MinAbbreviationLength = 6
+
+// maxAmbiguousCandidates - сколько кандидатов перечисляется в ошибке о неоднозначном сокращении.
+const maxAmbiguousCandidates = 10
+
+// ReasonAmbiguousPrefix - причина в ErrorInfo для неоднозначного сокращения хеша.
+const ReasonAmbiguousPrefix = "AMBIGUOUS_PREFIX"
+
+// isAbbreviation сообщает, может ли строка быть сокращением хеша.
+func isAbbreviation(hash string) bool {
+	return len(hash) >= MinAbbreviationLength && isHex(strings.ToLower(hash))
+}
+
+// resolveHash находит полный хеш по сокращению. Ошибки возвращаются уже в виде gRPC-статусов.
+func (s *HashingService) resolveHash(ctx context.Context, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+
+	// Берем на одного кандидата больше, чем перечисляем, чтобы знать, что список неполный
+	candidates, err := s.records(ctx).FindByPrefix(ctx, prefix, maxAmbiguousCandidates+1)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to resolve hash prefix: %v", err)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", status.Errorf(codes.NotFound, "hash not found")
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", ambiguousPrefixError(prefix, candidates)
+	}
+}
+
+// ambiguousPrefixError возвращает InvalidArgument со списком кандидатов в сообщении и в ErrorInfo.
+func ambiguousPrefixError(prefix string, candidates []string) error {
+	listed := candidates
+	more := ""
+	if len(candidates) > maxAmbiguousCandidates {
+		listed = candidates[:maxAmbiguousCandidates]
+		more = " and more"
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("ambiguous hash prefix %q, candidates: %s%s", prefix, strings.Join(listed, ", "), more))
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   ReasonAmbiguousPrefix,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"candidates": strings.Join(listed, ",")},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,115 @@
+package hashing
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Аутентификация gateway. Gateway проверяет токены клиентов сам, но порт Hashing Service доступен и в обход
+gateway, поэтому gateway подтверждает свои вызовы служебным токеном в gRPC-метаданных AuthorizationMetadataKey
+("Bearer <токен>"). Методы сервиса HashingAdmin, а также DeleteHash и ListHashes выполняются только с этим
+токеном. Остальные методы Hashing вызываются и без него, но пространство имен из метаданных учитывается
+только у вызовов с токеном: gateway определяет его по учетной записи клиента, а остальные вызовы
+выполняются в пространстве по умолчанию.
+*/
+
+// AuthorizationMetadataKey - ключ gRPC-метаданных со служебным токеном.
+const AuthorizationMetadataKey = "authorization"
+
+type serviceCallerContextKey struct{}
+
+// serviceCaller сообщает, что вызов подтвержден служебным токеном gateway.
+func serviceCaller(ctx context.Context) bool {
+	trusted, _ := ctx.Value(serviceCallerContextKey{}).(bool)
+	return trusted
+}
+
+// adminMethod сообщает, требует ли метод fullMethod (в виде "/proto.Hashing/DeleteHash") служебного токена.
+func adminMethod(fullMethod string) bool {
+	switch fullMethod {
+	case "/" + pb.Hashing_ServiceDesc.ServiceName + "/DeleteHash",
+		"/" + pb.Hashing_ServiceDesc.ServiceName + "/ListHashes":
+		return true
+	}
+	return strings.HasPrefix(fullMethod, "/"+pb.HashingAdmin_ServiceDesc.ServiceName+"/")
+}
+
+/*
+serviceAuthenticator проверяет служебный токен вызова. Токены сравниваются по SHA-256 за постоянное время,
+чтобы время ответа не раскрывало токен. Пустой token означает, что служебного токена нет.
+*/
+type serviceAuthenticator struct {
+	token  string
+	digest [sha256.Size]byte
+}
+
+func newServiceAuthenticator(token string) *serviceAuthenticator {
+	return &serviceAuthenticator{token: token, digest: sha256.Sum256([]byte(token))}
+}
+
+/*
+authenticate возвращает контекст вызова метода fullMethod, отмеченный как вызов gateway, если в нем верный
+служебный токен. Административный метод без токена возвращает Unauthenticated, с другим токеном или без
+настроенного токена - PermissionDenied.
+*/
+func (a *serviceAuthenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationMetadataKey)
+	if a.token != "" && len(values) > 0 {
+		given, ok := strings.CutPrefix(values[0], "Bearer ")
+		givenDigest := sha256.Sum256([]byte(given))
+		if ok && subtle.ConstantTimeCompare(a.digest[:], givenDigest[:]) == 1 {
+			return context.WithValue(ctx, serviceCallerContextKey{}, true), nil
+		}
+	}
+
+	if !adminMethod(fullMethod) {
+		return ctx, nil
+	}
+	switch {
+	case a.token == "":
+		return nil, status.Errorf(codes.PermissionDenied, "%s is disabled: admin token is not configured", fullMethod)
+	case len(values) == 0:
+		return nil, status.Errorf(codes.Unauthenticated, "%s requires an admin token", fullMethod)
+	default:
+		return nil, status.Errorf(codes.PermissionDenied, "invalid admin token")
+	}
+}
+
+/*
+AdminUnaryInterceptor и AdminStreamInterceptor проверяют служебный токен token (см. authenticate). Они должны
+стоять перед NamespaceUnaryInterceptor и NamespaceStreamInterceptor, которые учитывают пространство имен
+только у вызовов с токеном. Пустой token выключает административные методы и пространства имен.
+*/
+
+func AdminUnaryInterceptor(token string) grpc.UnaryServerInterceptor {
+	auth := newServiceAuthenticator(token)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := auth.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AdminStreamInterceptor(token string) grpc.StreamServerInterceptor {
+	auth := newServiceAuthenticator(token)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := auth.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &namespacedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
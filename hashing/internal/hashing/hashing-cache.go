@@ -0,0 +1,229 @@
+package hashing
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+)
+
+/*
+Кеш GetHash. Данные, сохраненные под хешем, не меняются, поэтому повторно читать их из хранилища при
+каждом GetHash незачем. PayloadCache - LRU-кеш в памяти процесса, ограниченный и числом записей, и их
+суммарным размером в байтах; при превышении любого из ограничений вытесняются давно не читавшиеся записи.
+
+Ключ кеша - ключ записи в хранилище (вместе с пространством имен, см. storage.Key). Запись удаляется из
+кеша при удалении хеша, изменении срока жизни и повторном сохранении, а запись с истекшим сроком жизни
+из кеша не возвращается. Как и фильтр Блума, кеш не знает об изменениях, сделанных другими экземплярами
+Hashing Service.
+*/
+
+type PayloadCache struct {
+	// Нулевое ограничение означает его отсутствие
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order - записи от недавно прочитанных к давно не читавшимся
+	order *list.List
+	bytes int64
+	// generation увеличивается при каждом Remove (см. Add)
+	generation uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheEntry struct {
+	key    string
+	record *storage.Record
+	size   int64
+}
+
+func NewPayloadCache(maxEntries int, maxBytes int64) *PayloadCache {
+	return &PayloadCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get возвращает запись из кеша. Возвращенную запись нельзя изменять.
+func (c *PayloadCache) Get(key string, now time.Time) (*storage.Record, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if entry.record.Expired(now) {
+		c.remove(element)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.record, true
+}
+
+// Generation возвращает текущее поколение кеша; его нужно получить до чтения записи из хранилища.
+func (c *PayloadCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+/*
+Add кеширует запись record, прочитанную из хранилища в поколении generation. Если с тех пор из кеша
+что-то удалялось, запись могла устареть (например, хеш удалили, пока она читалась), поэтому она не
+кешируется. Запись, которая одна превышает ограничение по размеру, тоже не кешируется.
+*/
+func (c *PayloadCache) Add(key string, record *storage.Record, generation uint64) {
+	entry := &cacheEntry{
+		key: key,
+		// Статистика чтений в кеше не нужна и устаревает, поэтому храним только неизменяемые поля
+		record: &storage.Record{
+			Payload:       record.Payload,
+			ContentType:   record.ContentType,
+			Algorithm:     record.Algorithm,
+			MACKey:        record.MACKey,
+			MACKeyVersion: record.MACKeyVersion,
+			Normalization: record.Normalization,
+			CreatedAt:     record.CreatedAt,
+			Size:          record.Size,
+			ExpiresAt:     record.ExpiresAt,
+		},
+		size: int64(len(key) + len(record.Payload) + len(record.ContentType) + len(record.Algorithm) + len(record.MACKey) + len(record.Normalization)),
+	}
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += entry.size
+
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Remove удаляет запись из кеша, если она там есть.
+func (c *PayloadCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// remove удаляет элемент из кеша. Вызывается под блокировкой.
+func (c *PayloadCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// CacheStats - метрики кеша: заполнение, попадания и промахи.
+type CacheStats struct {
+	Entries    int     `json:"entries"`
+	Bytes      int64   `json:"bytes"`
+	MaxEntries int     `json:"max_entries"`
+	MaxBytes   int64   `json:"max_bytes"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	Evictions  uint64  `json:"evictions"`
+	HitRate    float64 `json:"hit_rate"`
+}
+
+func (c *PayloadCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Entries:    c.order.Len(),
+		Bytes:      c.bytes,
+		MaxEntries: c.maxEntries,
+		MaxBytes:   c.maxBytes,
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+	}
+	if reads := stats.Hits + stats.Misses; reads > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(reads)
+	}
+	return stats
+}
+
+/*
+Методы HashingService для работы с кешем. Если кеш не включен (см. WithPayloadCache), они ничего не делают.
+*/
+
+// CacheStats возвращает метрики кеша; false, если кеш не включен.
+func (s *HashingService) CacheStats() (CacheStats, bool) {
+	if s.cache == nil {
+		return CacheStats{}, false
+	}
+	return s.cache.Stats(), true
+}
+
+// cacheGet ищет в кеше запись хеша из пространства имен запроса.
+func (s *HashingService) cacheGet(ctx context.Context, hash string) (*storage.Record, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+	return s.cache.Get(storage.Key(NamespaceFromContext(ctx), hash), time.Now())
+}
+
+// cacheGeneration возвращает поколение кеша для последующего cacheAdd.
+func (s *HashingService) cacheGeneration() uint64 {
+	if s.cache == nil {
+		return 0
+	}
+	return s.cache.Generation()
+}
+
+func (s *HashingService) cacheAdd(ctx context.Context, hash string, record *storage.Record, generation uint64) {
+	if s.cache != nil {
+		s.cache.Add(storage.Key(NamespaceFromContext(ctx), hash), record, generation)
+	}
+}
+
+// cacheRemove удаляет из кеша хеш, запись которого изменилась или удалена.
+func (s *HashingService) cacheRemove(ctx context.Context, hash string) {
+	if s.cache != nil {
+		s.cache.Remove(storage.Key(NamespaceFromContext(ctx), hash))
+	}
+}
+
+// recordAccess учитывает чтение хеша в статистике записи. Ошибка не должна мешать чтению, поэтому
+// только логируется.
+func (s *HashingService) recordAccess(ctx context.Context, hash string) {
+	if err := s.records(ctx).RecordAccess(ctx, hash, time.Now()); err != nil {
+		log.Printf("failed to record access to hash %s: %v", hash, err)
+	}
+}
@@ -0,0 +1,95 @@
+package hashing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gowebpki/jcs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Канонический JSON (RFC 8785, JCS). Одинаковые по смыслу документы JSON с разным порядком ключей, пробелами
+или записью чисел и строк дают разные хеши. В режиме canonical_json данные разбираются как JSON и хешируются
+(и сохраняются) в канонической форме: ключи отсортированы, пробелов нет, числа и строки записаны единообразно.
+Данные, которые не являются I-JSON (синтаксические ошибки, повторяющиеся ключи, числа вне диапазона double),
+отклоняются с InvalidArgument и ErrorInfo с причиной ReasonInvalidJSON.
+*/
+
+// ReasonInvalidJSON - причина в ErrorInfo для данных, которые не удалось привести к каноническому JSON.
+const ReasonInvalidJSON = "INVALID_JSON"
+
+/*
+hashedPayload возвращает данные, от которых считается хеш: сами данные после нормализации текста
+(см. hashing-normalize.go) и, в режиме canonical_json, их канонический JSON.
+*/
+func hashedPayload(data []byte, normalization textNormalization, canonical bool) ([]byte, error) {
+	data, err := normalization.apply(data)
+	if err != nil || !canonical {
+		return data, err
+	}
+	return canonicalJSON(data)
+}
+
+// canonicalJSON приводит документ JSON к канонической форме RFC 8785. Ошибка возвращается уже в виде gRPC-статуса.
+func canonicalJSON(data []byte) ([]byte, error) {
+	if !utf8.Valid(data) {
+		return nil, invalidJSONError("payload is not valid UTF-8", -1)
+	}
+
+	// Синтаксис проверяем стандартным разбором: в отличие от jcs он сообщает позицию ошибки
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		var syntaxErr *json.SyntaxError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, invalidJSONError(syntaxErr.Error(), syntaxErr.Offset)
+		case errors.Is(err, io.EOF):
+			return nil, invalidJSONError("payload is empty", 0)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, invalidJSONError("unexpected end of JSON input", int64(len(data)))
+		default:
+			return nil, invalidJSONError(err.Error(), -1)
+		}
+	}
+	offset := decoder.InputOffset()
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, invalidJSONError("unexpected data after the JSON value", offset)
+	}
+
+	canonical, err := jcs.Transform(data)
+	if err != nil {
+		return nil, invalidJSONError(err.Error(), -1)
+	}
+	return canonical, nil
+}
+
+// invalidJSONError возвращает InvalidArgument с ErrorInfo; позиция ошибки в байтах указывается, если она известна.
+func invalidJSONError(reason string, offset int64) error {
+	message := "invalid JSON payload: " + reason
+	metadata := map[string]string{"error": reason}
+	if offset >= 0 {
+		message = fmt.Sprintf("invalid JSON payload at offset %d: %s", offset, reason)
+		metadata["offset"] = strconv.FormatInt(offset, 10)
+	}
+
+	st := status.New(codes.InvalidArgument, message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   ReasonInvalidJSON,
+		Domain:   errorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,120 @@
+package hashing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Атомарное создание хешей. CreateHash и CreateHashStream не перезаписывают существующую запись:
+хранилище атомарно сохраняет ее, только если хеша еще нет (storage.HashStore.Create). Повторное создание
+тех же данных возвращает created = false и меняет только срок жизни, продлевая его до нового. Если под тем же хешем уже сохранены другие
+данные (или они получены другим алгоритмом), это коллизия: запись не трогается, а клиент получает
+AlreadyExists с причиной ReasonHashCollision.
+*/
+
+// ReasonHashCollision - причина в ErrorInfo для ошибки AlreadyExists при коллизии хешей.
+const ReasonHashCollision = "HASH_COLLISION"
+
+/*
+createRecord сохраняет новую запись и возвращает true или проверяет, что существующая запись содержит
+те же данные, и возвращает false. Срок жизни существующей записи продлевается до срока новой, но не
+сокращается. Если указан владелец, он получает ссылку на запись - и на новую, и на существующую. Ошибки
+возвращаются уже в виде gRPC-статусов.
+*/
+func (s *HashingService) createRecord(ctx context.Context, hash string, record *storage.Record, owner string) (bool, error) {
+	if owner != "" {
+		record.Owners = map[string]int64{owner: 1}
+		record.References = 1
+	}
+
+	records := s.records(ctx)
+	for attempt := 0; ; attempt++ {
+		existing, created, err := records.Create(ctx, hash, record)
+		if err != nil {
+			return false, status.Errorf(codes.Internal, "failed to save hash: %v", err)
+		}
+		if created {
+			s.filterAdd(ctx, hash)
+			s.cacheRemove(ctx, hash)
+			return true, nil
+		}
+
+		if !samePayload(existing, record) {
+			return false, hashCollisionError(hash, existing, record)
+		}
+
+		err = s.extendExpiration(ctx, hash, existing, record.ExpiresAt)
+		if err == nil && owner != "" {
+			err = s.addReference(ctx, hash, owner)
+		}
+		// Запись могла истечь или ее мог удалить сборщик мусора после Create - тогда создаем ее заново
+		if errors.Is(err, storage.ErrNotFound) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return false, status.Errorf(codes.Internal, "failed to update existing hash: %v", err)
+		}
+		return false, nil
+	}
+}
+
+/*
+samePayload сравнивает данные новой и существующей записи. Данные сверх лимита хранения при потоковом
+создании не сохраняются, и тогда сравнить можно только размер. Шаги нормализации не сравниваются: хеш
+считается от уже нормализованных данных, и если они совпали, это те же данные, полученные другими шагами.
+В записи остаются шаги первого создания.
+*/
+func samePayload(existing, record *storage.Record) bool {
+	if existing.Algorithm != record.Algorithm || existing.MACKey != record.MACKey || existing.Size != record.Size {
+		return false
+	}
+	if existing.PayloadStored() && record.PayloadStored() {
+		return bytes.Equal(existing.Payload, record.Payload)
+	}
+	return true
+}
+
+/*
+extendExpiration продлевает срок жизни существующей записи до expiresAt, если он наступает раньше. Нулевое
+время - бессрочное хранение, поэтому оно продлевает любой срок.
+*/
+func (s *HashingService) extendExpiration(ctx context.Context, hash string, existing *storage.Record, expiresAt time.Time) error {
+	if existing.ExpiresAt.IsZero() || !expiresAt.IsZero() && !expiresAt.After(existing.ExpiresAt) {
+		return nil
+	}
+	if err := s.records(ctx).SetExpiration(ctx, hash, expiresAt); err != nil {
+		return err
+	}
+	s.cacheRemove(ctx, hash)
+	return nil
+}
+
+// hashCollisionError возвращает AlreadyExists с ErrorInfo, по которому клиент узнает о коллизии.
+func hashCollisionError(hash string, existing, record *storage.Record) error {
+	st := status.Newf(codes.AlreadyExists, "hash collision: hash %s already stores a different payload", hash)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonHashCollision,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"hash":               hash,
+			"existing_algorithm": existing.Algorithm,
+			"existing_size":      strconv.FormatInt(existing.Size, 10),
+			"algorithm":          record.Algorithm,
+			"size":               strconv.FormatInt(record.Size, 10),
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,94 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+Удаление хешей. DeleteHash удаляет запись вместе с исходными данными. Если запрошено надгробие (tombstone),
+вместо записи остается пустая запись с временем удаления: CheckHash сообщает deleted = true, а GetHash,
+GetHashMetadata и TouchHash возвращают NotFound с причиной ReasonHashDeleted в деталях ошибки, поэтому
+клиент может отличить удаленный хеш от никогда не существовавшего. Надгробие хранится в течение срока
+WithTombstoneRetention, после чего удаляется как запись с истекшим сроком жизни, и хеш снова выглядит
+никогда не существовавшим.
+*/
+
+// DefaultTombstoneRetention - сколько хранится надгробие удаленного хеша.
+const DefaultTombstoneRetention = 30 * 24 * time.Hour
+
+// ReasonHashDeleted - причина в ErrorInfo для ошибки NotFound по удаленному хешу.
+const ReasonHashDeleted = "HASH_DELETED"
+
+// errorDomain - домен в ErrorInfo для ошибок Hashing Service.
+const errorDomain = "hashing"
+
+func (s *HashingService) DeleteHash(ctx context.Context, req *pb.DeleteHashRequest) (*pb.DeleteHashResponse, error) {
+	hash, record, err := s.lookupRecord(ctx, req.GetHash(), req.GetAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	// Надгробие можно убрать удалением без tombstone, но повторно поставить его нельзя
+	if record.Deleted() && req.GetTombstone() {
+		return nil, hashDeletedError(record.DeletedAt)
+	}
+
+	now := time.Now()
+	if req.GetTombstone() {
+		// Надгробие заменяет запись целиком, поэтому исходные данные не сохраняются. Ключ хеширования остается,
+		// чтобы CheckHash с тем же ключом видел, что хеш удален
+		tombstone := &storage.Record{
+			Algorithm:     record.Algorithm,
+			MACKey:        record.MACKey,
+			MACKeyVersion: record.MACKeyVersion,
+			CreatedAt:     record.CreatedAt,
+			DeletedAt:     now,
+		}
+		if s.tombstoneRetention > 0 {
+			tombstone.ExpiresAt = now.Add(s.tombstoneRetention)
+		}
+		err = s.records(ctx).Save(ctx, hash, tombstone)
+	} else {
+		err = s.records(ctx).Delete(ctx, hash)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "hash not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to delete hash: %v", err)
+	}
+	s.cacheRemove(ctx, hash)
+	// Надгробие остается в хранилище, поэтому из фильтра убирается только удаленная запись
+	if !req.GetTombstone() {
+		s.filterRemove(ctx, hash)
+	}
+
+	return &pb.DeleteHashResponse{
+		Hash:      hash,
+		Tombstone: req.GetTombstone(),
+		DeletedAt: timestamppb.New(now),
+	}, nil
+}
+
+// hashDeletedError возвращает NotFound с ErrorInfo, по которому клиент узнает, что хеш был удален.
+func hashDeletedError(deletedAt time.Time) error {
+	st := status.New(codes.NotFound, "hash deleted")
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   ReasonHashDeleted,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"deleted_at": deletedAt.UTC().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
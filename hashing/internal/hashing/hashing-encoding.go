@@ -0,0 +1,132 @@
+package hashing
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mr-tron/base58"
+	"github.com/multiformats/go-multibase"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Кодировки хеша в ответах. В хранилище хеш всегда хранится в каноническом виде - строкой шестнадцатеричных
+символов в нижнем регистре, а в ответе его можно получить в другой кодировке: base64url для URL, base32
+для файловых систем без учета регистра, base58 и multibase (base32 с префиксом "b", как в CIDv1 IPFS).
+Методы, которые ищут хеш, принимают его в любой из этих кодировок (и в любой кодировке multibase)
+и приводят к каноническому виду (см. lookupRecord).
+*/
+
+// Поддерживаемые кодировки хеша. Пустая кодировка означает EncodingHex.
+const (
+	EncodingHex       = "hex"
+	EncodingBase64URL = "base64url"
+	EncodingBase32    = "base32"
+	EncodingBase58    = "base58"
+	EncodingMultibase = "multibase"
+)
+
+// base32NoPadding - base32 по RFC 4648 без выравнивания символами "=".
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// hashEncoders кодируют хеш в каждой из поддерживаемых кодировок.
+var hashEncoders = map[string]func([]byte) string{
+	EncodingHex:       hex.EncodeToString,
+	EncodingBase64URL: base64.RawURLEncoding.EncodeToString,
+	EncodingBase32: func(digest []byte) string {
+		return strings.ToLower(base32NoPadding.EncodeToString(digest))
+	},
+	EncodingBase58: base58.Encode,
+	EncodingMultibase: func(digest []byte) string {
+		encoded, _ := multibase.Encode(multibase.Base32, digest)
+		return encoded
+	},
+}
+
+/*
+hashDecoders декодируют хеш в кодировках, отличных от hex, в порядке проверки. Строка может оказаться
+допустимой сразу в нескольких кодировках (например, base58 - подмножество алфавита base64url), поэтому
+используются все подходящие варианты, а не первый.
+*/
+var hashDecoders = []func(string) ([]byte, error){
+	func(value string) ([]byte, error) {
+		_, digest, err := multibase.Decode(value)
+		return digest, err
+	},
+	func(value string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	},
+	func(value string) ([]byte, error) {
+		return base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(value, "=")))
+	},
+	base58.Decode,
+}
+
+// validateEncoding проверяет, что кодировка поддерживается. Ошибка возвращается уже в виде gRPC-статуса.
+func validateEncoding(encoding string) error {
+	if encoding == "" {
+		return nil
+	}
+	if _, ok := hashEncoders[strings.ToLower(encoding)]; !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown hash encoding %q, supported: hex, base64url, base32, base58, multibase", encoding)
+	}
+	return nil
+}
+
+// encodeHash переводит канонический хеш в кодировку encoding, проверенную validateEncoding.
+func encodeHash(encoding, hash string) string {
+	encoder, ok := hashEncoders[strings.ToLower(encoding)]
+	if !ok || encoder == nil {
+		return hash
+	}
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		return hash
+	}
+	return encoder(digest)
+}
+
+/*
+hashCandidates возвращает канонические хеши, которые может означать строка value в кодировках, отличных
+от hex. Учитываются только варианты, длина которых совпадает с длиной хеша одного из алгоритмов реестра,
+поэтому шестнадцатеричные хеши и их сокращения почти никогда не дают лишних вариантов.
+*/
+func (s *HashingService) hashCandidates(value string) []string {
+	var candidates []string
+	for _, decode := range hashDecoders {
+		digest, err := decode(value)
+		if err != nil || !s.algorithms.isDigestSize(len(digest)) {
+			continue
+		}
+		candidate := hex.EncodeToString(digest)
+		if candidate != value && !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+/*
+decodeDigest возвращает все варианты, которые может означать хеш value: шестнадцатеричный любой длины
+и варианты в остальных кодировках длиной size байт. Ошибка означает, что value не подходит ни под одну кодировку.
+*/
+func decodeDigest(value string, size int) ([][]byte, error) {
+	var digests [][]byte
+	if digest, err := hex.DecodeString(value); err == nil && len(digest) > 0 {
+		digests = append(digests, digest)
+	}
+	for _, decode := range hashDecoders {
+		if digest, err := decode(value); err == nil && len(digest) == size {
+			digests = append(digests, digest)
+		}
+	}
+	if len(digests) == 0 {
+		return nil, fmt.Errorf("expected hash %q is not a hex, base64url, base32, base58 or multibase string", value)
+	}
+	return digests, nil
+}
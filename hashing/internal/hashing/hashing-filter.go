@@ -0,0 +1,335 @@
+package hashing
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"final-project-kodzimo-hashing/internal/storage"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+/*
+Фильтр Блума перед CheckHash. Большая часть запросов CheckHash приходит для данных, которые никогда
+не сохранялись, и каждый такой запрос стоит обращения к хранилищу. Фильтр хранит в памяти ключи всех
+записей (вместе с пространством имен, см. storage.Key) и отвечает "точно нет" без обращения к хранилищу;
+ответ "возможно есть" проверяется по хранилищу как раньше.
+
+Фильтр масштабируемый: когда очередная ступень заполняется, добавляется новая, вдвое больше и с вдвое
+меньшей вероятностью ложного срабатывания, поэтому общая вероятность не превышает заданную при любом
+числе записей. Из фильтра Блума нельзя удалять, поэтому удаленные ключи только учитываются, и когда их
+становится слишком много, фильтр перестраивается по хранилищу в фоне.
+
+Фильтр знает только о записях, созданных этим процессом, поэтому его можно включать, только если
+в хранилище пишет один экземпляр Hashing Service.
+*/
+
+const (
+	// DefaultFilterCapacity - число ключей, на которое рассчитана первая ступень фильтра.
+	DefaultFilterCapacity = 1 << 16
+	// DefaultFilterFalsePositiveRate - допустимая вероятность ложного срабатывания всего фильтра.
+	DefaultFilterFalsePositiveRate = 0.01
+
+	// Каждая следующая ступень в filterGrowth раз больше предыдущей, а ее вероятность ложного
+	// срабатывания в filterTightening раз меньше.
+	filterGrowth     = 2
+	filterTightening = 0.5
+	// Фильтр перестраивается, когда удаленные ключи составляют такую долю от добавленных,
+	// но не раньше, чем их наберется filterRebuildMinRemoved.
+	filterRebuildRatio      = 0.25
+	filterRebuildMinRemoved = 1024
+)
+
+// scalableBloom - масштабируемый фильтр Блума из ступеней растущего размера.
+type scalableBloom struct {
+	stages []bloomStage
+	// items - число ключей во всех ступенях. Ключ, на который фильтр уже срабатывает, не добавляется
+	// повторно, поэтому из-за ложных срабатываний счетчик может быть немного меньше фактического
+	items uint
+	rate  float64
+}
+
+// bloomStage - ступень фильтра, рассчитанная на capacity ключей, в которую добавлено count ключей.
+type bloomStage struct {
+	filter   *bloom.BloomFilter
+	capacity uint
+	count    uint
+}
+
+func newScalableBloom(capacity uint, falsePositiveRate float64) *scalableBloom {
+	// Сумма вероятностей ступеней rate * (1 + r + r^2 + ...) = rate / (1 - r) не превышает заданную
+	b := &scalableBloom{rate: falsePositiveRate * (1 - filterTightening)}
+	b.addStage(capacity)
+	return b
+}
+
+func (b *scalableBloom) addStage(capacity uint) {
+	rate := b.rate * math.Pow(filterTightening, float64(len(b.stages)))
+	b.stages = append(b.stages, bloomStage{filter: bloom.NewWithEstimates(capacity, rate), capacity: capacity})
+}
+
+func (b *scalableBloom) add(key []byte) {
+	if b.test(key) {
+		return
+	}
+
+	last := &b.stages[len(b.stages)-1]
+	if last.count >= last.capacity {
+		b.addStage(last.capacity * filterGrowth)
+		last = &b.stages[len(b.stages)-1]
+	}
+	last.filter.Add(key)
+	last.count++
+	b.items++
+}
+
+func (b *scalableBloom) test(key []byte) bool {
+	for _, stage := range b.stages {
+		if stage.filter.Test(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeBytes возвращает размер битовых массивов всех ступеней.
+func (b *scalableBloom) sizeBytes() uint {
+	var size uint
+	for _, stage := range b.stages {
+		size += stage.filter.Cap() / 8
+	}
+	return size
+}
+
+// estimatedFalsePositiveRate считает теоретическую вероятность ложного срабатывания по заполнению
+// ступеней: для ступени из m бит с k хеш-функциями и n ключами она равна (1 - e^(-kn/m))^k.
+func (b *scalableBloom) estimatedFalsePositiveRate() float64 {
+	miss := 1.0
+	for _, stage := range b.stages {
+		k := float64(stage.filter.K())
+		m := float64(stage.filter.Cap())
+		miss *= 1 - math.Pow(1-math.Exp(-k*float64(stage.count)/m), k)
+	}
+	return 1 - miss
+}
+
+/*
+HashFilter - фильтр ключей записей, который поддерживает HashingService. Пока фильтр не построен
+по хранилищу (см. RebuildFilter), MayContain всегда отвечает "возможно есть", поэтому запросы идут
+в хранилище.
+*/
+type HashFilter struct {
+	capacity          uint
+	falsePositiveRate float64
+
+	mu      sync.RWMutex
+	current *scalableBloom
+	// next - фильтр, который строится во время перестройки; новые ключи добавляются в оба
+	next  *scalableBloom
+	ready bool
+	// removed - число удаленных ключей, которые остались в current
+	removed uint
+
+	lookups        atomic.Uint64
+	negatives      atomic.Uint64
+	falsePositives atomic.Uint64
+	rebuilds       atomic.Uint64
+}
+
+func NewHashFilter(capacity uint, falsePositiveRate float64) *HashFilter {
+	return &HashFilter{
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+		current:           newScalableBloom(capacity, falsePositiveRate),
+	}
+}
+
+func (f *HashFilter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.current.add([]byte(key))
+	if f.next != nil {
+		f.next.add([]byte(key))
+	}
+}
+
+// Remove учитывает удаленный ключ и сообщает, пора ли перестроить фильтр.
+func (f *HashFilter) Remove(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removed++
+	return f.ready && f.next == nil && f.removed >= filterRebuildMinRemoved &&
+		float64(f.removed) > filterRebuildRatio*float64(f.current.items)
+}
+
+// MayContain возвращает false, только если ключа точно нет в хранилище.
+func (f *HashFilter) MayContain(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.ready {
+		return true
+	}
+	f.lookups.Add(1)
+	if !f.current.test([]byte(key)) {
+		f.negatives.Add(1)
+		return false
+	}
+	return true
+}
+
+// RecordFalsePositive учитывает ответ "возможно есть", который хранилище не подтвердило.
+func (f *HashFilter) RecordFalsePositive() {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.ready {
+		f.falsePositives.Add(1)
+	}
+}
+
+/*
+Rebuild строит новый фильтр по ключам, которые передает forEachKey, и заменяет им текущий. Ключи,
+добавленные во время перестройки, попадают в оба фильтра, поэтому ничего не теряется. Одновременно
+выполняется только одна перестройка.
+*/
+func (f *HashFilter) Rebuild(forEachKey func(add func(key string)) error) error {
+	f.mu.Lock()
+	if f.next != nil {
+		f.mu.Unlock()
+		return nil
+	}
+	next := newScalableBloom(f.capacity, f.falsePositiveRate)
+	f.next = next
+	f.mu.Unlock()
+
+	err := forEachKey(func(key string) {
+		f.mu.Lock()
+		next.add([]byte(key))
+		f.mu.Unlock()
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next = nil
+	if err != nil {
+		return err
+	}
+	f.current = next
+	f.removed = 0
+	f.ready = true
+	f.rebuilds.Add(1)
+	return nil
+}
+
+// FilterStats - метрики фильтра: размер и наблюдаемая доля ложных срабатываний.
+type FilterStats struct {
+	Ready          bool   `json:"ready"`
+	Items          uint   `json:"items"`
+	Removed        uint   `json:"removed"`
+	Stages         int    `json:"stages"`
+	SizeBytes      uint   `json:"size_bytes"`
+	Rebuilds       uint64 `json:"rebuilds"`
+	Lookups        uint64 `json:"lookups"`
+	Negatives      uint64 `json:"negatives"`
+	FalsePositives uint64 `json:"false_positives"`
+	// FalsePositiveRate - доля ложных срабатываний среди ключей, которых нет в хранилище
+	FalsePositiveRate          float64 `json:"false_positive_rate"`
+	EstimatedFalsePositiveRate float64 `json:"estimated_false_positive_rate"`
+}
+
+func (f *HashFilter) Stats() FilterStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	stats := FilterStats{
+		Ready:                      f.ready,
+		Items:                      f.current.items,
+		Removed:                    f.removed,
+		Stages:                     len(f.current.stages),
+		SizeBytes:                  f.current.sizeBytes(),
+		Rebuilds:                   f.rebuilds.Load(),
+		Lookups:                    f.lookups.Load(),
+		Negatives:                  f.negatives.Load(),
+		FalsePositives:             f.falsePositives.Load(),
+		EstimatedFalsePositiveRate: f.current.estimatedFalsePositiveRate(),
+	}
+	if absent := stats.Negatives + stats.FalsePositives; absent > 0 {
+		stats.FalsePositiveRate = float64(stats.FalsePositives) / float64(absent)
+	}
+	return stats
+}
+
+/*
+Методы HashingService для работы с фильтром. Если фильтр не включен (см. WithHashFilter), они ничего
+не делают.
+*/
+
+// RebuildFilter строит фильтр по всем записям хранилища, включая надгробия и записи всех пространств имен.
+func (s *HashingService) RebuildFilter(ctx context.Context) error {
+	if s.filter == nil {
+		return nil
+	}
+
+	return s.filter.Rebuild(func(add func(key string)) error {
+		query := storage.ListQuery{Limit: MaxListLimit}
+		for {
+			page, err := s.store.List(ctx, query)
+			if err != nil {
+				return err
+			}
+			for _, entry := range page.Entries {
+				add(entry.Hash)
+			}
+			if page.NextCursor == "" {
+				return nil
+			}
+			query.Cursor = page.NextCursor
+		}
+	})
+}
+
+// FilterStats возвращает метрики фильтра; false, если фильтр не включен.
+func (s *HashingService) FilterStats() (FilterStats, bool) {
+	if s.filter == nil {
+		return FilterStats{}, false
+	}
+	return s.filter.Stats(), true
+}
+
+// filterAdd добавляет в фильтр сохраненный хеш из пространства имен запроса.
+func (s *HashingService) filterAdd(ctx context.Context, hash string) {
+	if s.filter != nil {
+		s.filter.Add(storage.Key(NamespaceFromContext(ctx), hash))
+	}
+}
+
+// filterRemove учитывает удаленный хеш и при необходимости запускает перестройку фильтра в фоне.
+func (s *HashingService) filterRemove(ctx context.Context, hash string) {
+	if s.filter == nil || !s.filter.Remove(storage.Key(NamespaceFromContext(ctx), hash)) {
+		return
+	}
+
+	go func() {
+		if err := s.RebuildFilter(context.Background()); err != nil {
+			log.Printf("failed to rebuild hash filter: %v", err)
+		}
+	}()
+}
+
+// filterMayContain возвращает false, только если хеша точно нет в пространстве имен запроса.
+func (s *HashingService) filterMayContain(ctx context.Context, hash string) bool {
+	return s.filter == nil || s.filter.MayContain(storage.Key(NamespaceFromContext(ctx), hash))
+}
+
+// filterMiss учитывает хеш, которого не оказалось в хранилище, хотя фильтр его не исключил.
+func (s *HashingService) filterMiss() {
+	if s.filter != nil {
+		s.filter.RecordFalsePositive()
+	}
+}
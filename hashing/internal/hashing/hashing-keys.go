@@ -0,0 +1,199 @@
+package hashing
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+Хеширование с ключом. Хеш данных с низкой энтропией (email, номер телефона) легко подобрать перебором,
+поэтому для них хеш можно считать с секретным ключом: HMAC выбранным алгоритмом или BLAKE2b в режиме
+MAC. Ключи создает администратор через сервис HashingAdmin, секреты генерирует сервис и никогда их не
+возвращает. При смене ключа (RotateKey) появляется новая версия: новые хеши считаются ею, а старые
+версии остаются, чтобы хеши, посчитанные ими, можно было проверить, указав key_version.
+*/
+
+// macKeySecretSize - размер секрета версии ключа в байтах.
+const macKeySecretSize = 32
+
+// rotateKeyAttempts - сколько раз RotateKey повторяет запись, если ключ одновременно изменили.
+const rotateKeyAttempts = 3
+
+// keyedHasher - алгоритм хеширования вместе с ключом, если хеш считается с ключом.
+type keyedHasher struct {
+	Algorithm
+	key        string
+	keyVersion int64
+	newHash    func() hash.Hash
+}
+
+// New создает хеш с ключом или без него.
+func (h keyedHasher) New() hash.Hash {
+	return h.newHash()
+}
+
+// Sum вычисляет хеш от data и возвращает его в виде строки шестнадцатеричных символов.
+func (h keyedHasher) Sum(data []byte) string {
+	digest := h.New()
+	digest.Write(data)
+	return fmt.Sprintf("%x", digest.Sum(nil))
+}
+
+// matches сообщает, посчитан ли хеш записи этим алгоритмом с тем же ключом (или без ключа).
+func (h keyedHasher) matches(record *storage.Record) bool {
+	return record.Algorithm == h.Name && record.MACKey == h.key
+}
+
+/*
+hasher возвращает алгоритм из запроса и, если указан ключ, его версию keyVersion (0 - текущую).
+Ошибки возвращаются уже в виде gRPC-статусов.
+*/
+func (s *HashingService) hasher(ctx context.Context, algorithmName, keyName string, keyVersion int64) (keyedHasher, error) {
+	algorithm, err := s.algorithms.Lookup(algorithmName)
+	if err != nil {
+		return keyedHasher{}, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if keyName == "" {
+		if keyVersion != 0 {
+			return keyedHasher{}, status.Errorf(codes.InvalidArgument, "key_version requires a key")
+		}
+		return keyedHasher{Algorithm: algorithm, newHash: algorithm.New}, nil
+	}
+	if keyVersion < 0 {
+		return keyedHasher{}, status.Errorf(codes.InvalidArgument, "key_version must not be negative")
+	}
+
+	key, err := s.store.GetMACKey(ctx, keyName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return keyedHasher{}, status.Errorf(codes.NotFound, "key %q not found", keyName)
+		}
+		return keyedHasher{}, status.Errorf(codes.Internal, "failed to get key: %v", err)
+	}
+	version, ok := key.Version(keyVersion)
+	if !ok {
+		return keyedHasher{}, status.Errorf(codes.NotFound, "key %q has no version %d", keyName, keyVersion)
+	}
+
+	return keyedHasher{
+		Algorithm:  algorithm,
+		key:        key.Name,
+		keyVersion: version.Version,
+		newHash:    algorithm.Keyed(version.Secret),
+	}, nil
+}
+
+// newKeyVersion создает версию ключа со случайным секретом.
+func newKeyVersion(version int64, now time.Time) (storage.MACKeyVersion, error) {
+	secret := make([]byte, macKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return storage.MACKeyVersion{}, status.Errorf(codes.Internal, "failed to generate key: %v", err)
+	}
+	return storage.MACKeyVersion{Version: version, Secret: secret, CreatedAt: now}, nil
+}
+
+/*
+Методы администрирования ключей (сервис HashingAdmin).
+*/
+
+func (s *HashingService) CreateKey(ctx context.Context, req *pb.CreateKeyRequest) (*pb.Key, error) {
+	if !namespaceNamePattern.MatchString(req.GetName()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid key name %q: use lowercase letters, digits and dashes", req.GetName())
+	}
+
+	now := time.Now()
+	version, err := newKeyVersion(1, now)
+	if err != nil {
+		return nil, err
+	}
+	key := &storage.MACKey{Name: req.GetName(), CreatedAt: now, Versions: []storage.MACKeyVersion{version}}
+	if err := s.store.SaveMACKey(ctx, key, 0); err != nil {
+		if errors.Is(err, storage.ErrKeyExists) {
+			return nil, status.Errorf(codes.AlreadyExists, "key %q already exists", req.GetName())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to create key: %v", err)
+	}
+
+	return keyMessage(key), nil
+}
+
+// RotateKey добавляет ключу новую версию, которой будут считаться новые хеши.
+func (s *HashingService) RotateKey(ctx context.Context, req *pb.RotateKeyRequest) (*pb.Key, error) {
+	for attempt := 0; attempt < rotateKeyAttempts; attempt++ {
+		key, err := s.store.GetMACKey(ctx, req.GetName())
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, status.Errorf(codes.NotFound, "key %q not found", req.GetName())
+			}
+			return nil, status.Errorf(codes.Internal, "failed to get key: %v", err)
+		}
+
+		previous := key.CurrentVersion()
+		version, err := newKeyVersion(previous+1, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		key.Versions = append(key.Versions, version)
+
+		err = s.store.SaveMACKey(ctx, key, previous)
+		if errors.Is(err, storage.ErrKeyVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to rotate key: %v", err)
+		}
+		return keyMessage(key), nil
+	}
+	return nil, status.Errorf(codes.Aborted, "key %q was changed concurrently, retry", req.GetName())
+}
+
+func (s *HashingService) GetKey(ctx context.Context, req *pb.GetKeyRequest) (*pb.Key, error) {
+	key, err := s.store.GetMACKey(ctx, req.GetName())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "key %q not found", req.GetName())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get key: %v", err)
+	}
+	return keyMessage(key), nil
+}
+
+func (s *HashingService) ListKeys(ctx context.Context, req *pb.ListKeysRequest) (*pb.ListKeysResponse, error) {
+	keys, err := s.store.ListMACKeys(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list keys: %v", err)
+	}
+
+	resp := &pb.ListKeysResponse{}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, keyMessage(key))
+	}
+	return resp, nil
+}
+
+// keyMessage переводит ключ в сообщение gRPC без секретов.
+func keyMessage(key *storage.MACKey) *pb.Key {
+	message := &pb.Key{
+		Name:           key.Name,
+		CreatedAt:      timestamppb.New(key.CreatedAt),
+		CurrentVersion: key.CurrentVersion(),
+	}
+	for _, version := range key.Versions {
+		message.Versions = append(message.Versions, &pb.KeyVersion{
+			Version:   version.Version,
+			CreatedAt: timestamppb.New(version.CreatedAt),
+		})
+	}
+	return message
+}
@@ -0,0 +1,123 @@
+package hashing
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Метод ListHashes возвращает страницу сохраненных хешей с метаданными. Курсор непрозрачен для клиента:
+внутри это курсор хранилища (курсор SCAN в Redis, последний ключ в памяти и bbolt), закодированный
+в base64url. Удаленные хеши (надгробия) в список не попадают.
+*/
+
+// Размер страницы ListHashes по умолчанию и максимальный размер
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+func (s *HashingService) ListHashes(ctx context.Context, req *pb.ListHashesRequest) (*pb.ListHashesResponse, error) {
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = DefaultListLimit
+	case limit > MaxListLimit:
+		limit = MaxListLimit
+	}
+
+	// Ключи хранилища - хеши в нижнем регистре, поэтому префикс проверяем здесь, до обращения к хранилищу
+	prefix := strings.ToLower(req.GetPrefix())
+	if !isHex(prefix) {
+		return nil, status.Errorf(codes.InvalidArgument, "prefix must be hexadecimal")
+	}
+
+	cursor, err := base64.RawURLEncoding.DecodeString(req.GetCursor())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid cursor")
+	}
+
+	filter, err := s.listFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.records(ctx).List(ctx, storage.ListQuery{
+		Cursor: string(cursor),
+		Limit:  limit,
+		Prefix: prefix,
+		Filter: filter,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid cursor")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to list hashes: %v", err)
+	}
+
+	resp := &pb.ListHashesResponse{
+		Hashes:     make([]*pb.HashMetadata, 0, len(page.Entries)),
+		NextCursor: base64.RawURLEncoding.EncodeToString([]byte(page.NextCursor)),
+	}
+	for _, entry := range page.Entries {
+		resp.Hashes = append(resp.Hashes, hashMetadata(entry.Hash, entry.Record))
+	}
+	return resp, nil
+}
+
+// listFilter собирает фильтр записей по алгоритму и времени создания из запроса.
+func (s *HashingService) listFilter(req *pb.ListHashesRequest) (func(string, *storage.Record) bool, error) {
+	var algorithm Algorithm
+	if req.GetAlgorithm() != "" {
+		var err error
+		algorithm, err = s.algorithms.Lookup(req.GetAlgorithm())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	var createdAfter, createdBefore time.Time
+	if req.GetCreatedAfter() != nil {
+		createdAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		createdBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	return func(hash string, record *storage.Record) bool {
+		if record.Deleted() {
+			return false
+		}
+		if algorithm.Name != "" && record.Algorithm != algorithm.Name {
+			return false
+		}
+		if !createdAfter.IsZero() && record.CreatedAt.Before(createdAfter) {
+			return false
+		}
+		if !createdBefore.IsZero() && !record.CreatedAt.Before(createdBefore) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// isHex сообщает, состоит ли строка только из шестнадцатеричных цифр в нижнем регистре.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package hashing
+
+import (
+	"context"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+Метод GetHashMetadata возвращает сведения о сохраненном хеше: алгоритм, тип и размер исходных данных,
+время создания, статистику чтений и оставшийся срок жизни. Сами данные не возвращаются, и обращение к метаданным не считается
+чтением.
+*/
+
+func (s *HashingService) GetHashMetadata(ctx context.Context, req *pb.HashLookupRequest) (*pb.HashMetadata, error) {
+	if err := validateEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+	hash, record, err := s.getRecord(ctx, req.GetHash(), req.GetAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := formatHash(req.GetEncoding(), req.GetMultihash(), record.Algorithm, record.MACKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return hashMetadata(formatted, record), nil
+}
+
+// hashMetadata собирает ответ с метаданными записи, включая оставшийся срок жизни.
+func hashMetadata(hash string, record *storage.Record) *pb.HashMetadata {
+	meta := &pb.HashMetadata{
+		Hash:           hash,
+		Algorithm:      record.Algorithm,
+		ContentType:    record.ContentType,
+		Size:           record.Size,
+		CreatedAt:      optionalTimestamp(record.CreatedAt),
+		LastAccessedAt: optionalTimestamp(record.LastAccessAt),
+		ReadCount:      record.ReadCount,
+		ExpiresAt:      optionalTimestamp(record.ExpiresAt),
+		References:     record.References,
+		ReleasedAt:     optionalTimestamp(record.ReleasedAt),
+		Key:            record.MACKey,
+		KeyVersion:     record.MACKeyVersion,
+		Normalization:  parseNormalizationSpec(record.Normalization).message(),
+		Simhash:        formatSimHash(record.SimHash),
+	}
+	if !record.ExpiresAt.IsZero() {
+		meta.Ttl = durationpb.New(max(time.Until(record.ExpiresAt), 0))
+	}
+	return meta
+}
+
+// optionalTimestamp переводит время в Timestamp; нулевое время означает, что значения нет.
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
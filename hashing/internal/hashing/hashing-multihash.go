@@ -0,0 +1,115 @@
+package hashing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Multihash - самоописывающий хеш: код алгоритма из таблицы multicodec, длина хеша (оба - unsigned varint)
+и сам хеш. По запросу хеш возвращается в этом формате в выбранной кодировке (см. hashing-encoding.go),
+а методы, которые ищут хеш, распознают multihash сами и ищут запись по хешу внутри него, проверяя
+алгоритм. В хранилище multihash не попадает. Для хешей с ключом кодов multicodec нет, поэтому
+multihash для них не поддерживается.
+*/
+
+// multihashCodes - коды алгоритмов реестра по умолчанию в таблице multicodec.
+var multihashCodes = map[string]uint64{
+	"sha1":        0x11,
+	"sha256":      0x12,
+	"sha512":      0x13,
+	"sha3-256":    0x16,
+	"blake2b-256": 0xb220,
+	"blake2b-512": 0xb240,
+}
+
+// encodeMultihash собирает multihash из кода алгоритма и хеша.
+func encodeMultihash(code uint64, digest []byte) []byte {
+	multihash := binary.AppendUvarint(nil, code)
+	multihash = binary.AppendUvarint(multihash, uint64(len(digest)))
+	return append(multihash, digest...)
+}
+
+// multihashCode возвращает код алгоритма для multihash. Ошибка возвращается уже в виде gRPC-статуса.
+func multihashCode(algorithmName, key string) (uint64, error) {
+	if key != "" {
+		return 0, status.Errorf(codes.InvalidArgument, "multihash is not supported for keyed hashes")
+	}
+	code, ok := multihashCodes[algorithmName]
+	if !ok {
+		return 0, status.Errorf(codes.InvalidArgument, "multihash is not supported for algorithm %s", algorithmName)
+	}
+	return code, nil
+}
+
+// multihashHex переводит канонический хеш в multihash (в hex, чтобы дальше его можно было передать encodeHash).
+func multihashHex(algorithmName, key, hash string) (string, error) {
+	code, err := multihashCode(algorithmName, key)
+	if err != nil {
+		return "", err
+	}
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "invalid stored hash %q: %v", hash, err)
+	}
+	return hex.EncodeToString(encodeMultihash(code, digest)), nil
+}
+
+// formatHash возвращает канонический хеш в виде, запрошенном клиентом: multihash или просто хеш в кодировке encoding.
+func formatHash(encoding string, multihash bool, algorithmName, key, hash string) (string, error) {
+	if multihash {
+		var err error
+		if hash, err = multihashHex(algorithmName, key, hash); err != nil {
+			return "", err
+		}
+	}
+	return encodeHash(encoding, hash), nil
+}
+
+/*
+parseMultihash разбирает multihash и возвращает имя алгоритма и хеш. Алгоритм должен быть зарегистрирован,
+а длина хеша - совпадать с длиной его хеша: сокращенные multihash не поддерживаются.
+*/
+func (s *HashingService) parseMultihash(data []byte) (string, []byte, bool) {
+	code, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", nil, false
+	}
+	size, m := binary.Uvarint(data[n:])
+	if m <= 0 || uint64(len(data)-n-m) != size {
+		return "", nil, false
+	}
+	digest := data[n+m:]
+
+	for name, algorithmCode := range multihashCodes {
+		if algorithmCode != code {
+			continue
+		}
+		algorithm, err := s.algorithms.Lookup(name)
+		// Сравнение с заново собранным multihash отсекает varint с лишними байтами
+		if err != nil || algorithm.New().Size() != len(digest) || !bytes.Equal(encodeMultihash(code, digest), data) {
+			return "", nil, false
+		}
+		return algorithm.Name, digest, true
+	}
+	return "", nil, false
+}
+
+// decodeMultihash ищет среди вариантов декодирования value (hex и остальные кодировки) multihash.
+func (s *HashingService) decodeMultihash(value string) (string, []byte, bool) {
+	decoders := append([]func(string) ([]byte, error){hex.DecodeString}, hashDecoders...)
+	for _, decode := range decoders {
+		data, err := decode(value)
+		if err != nil {
+			continue
+		}
+		if algorithm, digest, ok := s.parseMultihash(data); ok {
+			return algorithm, digest, true
+		}
+	}
+	return "", nil, false
+}
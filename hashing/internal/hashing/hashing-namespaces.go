@@ -0,0 +1,297 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Пространства имен (namespaces). Каждый запрос к Hashing Service выполняется в пространстве имен из
+gRPC-метаданных NamespaceMetadataKey; без него используется storage.DefaultNamespace. Метаданные
+учитываются только у вызовов gateway со служебным токеном (см. hashing-auth.go), иначе любой клиент
+порта Hashing Service мог бы читать и писать хеши чужого пространства. Ключи, списки,
+квоты и статистика считаются в пределах пространства: один и тот же хеш в двух пространствах - это
+две независимые записи. Пространства, кроме пространства по умолчанию, создает администратор через
+сервис HashingAdmin.
+*/
+
+// NamespaceMetadataKey - ключ gRPC-метаданных с именем пространства имен.
+const NamespaceMetadataKey = "x-hash-namespace"
+
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type namespaceContextKey struct{}
+
+// WithNamespace возвращает контекст запроса в пространстве имен namespace.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+// NamespaceFromContext возвращает пространство имен запроса (storage.DefaultNamespace, если оно не задано).
+func NamespaceFromContext(ctx context.Context) string {
+	if namespace, ok := ctx.Value(namespaceContextKey{}).(string); ok && namespace != "" {
+		return namespace
+	}
+	return storage.DefaultNamespace
+}
+
+/*
+namespaceFromMetadata читает и проверяет имя пространства из входящих gRPC-метаданных. Вызовы без служебного
+токена выполняются в пространстве по умолчанию, даже если пространство в метаданных указано.
+*/
+func namespaceFromMetadata(ctx context.Context) (string, error) {
+	if !serviceCaller(ctx) {
+		return storage.DefaultNamespace, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(NamespaceMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return storage.DefaultNamespace, nil
+	}
+	if !namespaceNamePattern.MatchString(values[0]) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid namespace %q", values[0])
+	}
+	return values[0], nil
+}
+
+/*
+NamespaceUnaryInterceptor и NamespaceStreamInterceptor переносят пространство имен из gRPC-метаданных
+в контекст запроса. Некорректное имя отклоняется с InvalidArgument до вызова обработчика. Перехватчики
+должны стоять после AdminUnaryInterceptor и AdminStreamInterceptor.
+*/
+
+func NamespaceUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	namespace, err := namespaceFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(WithNamespace(ctx, namespace), req)
+}
+
+func NamespaceStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	namespace, err := namespaceFromMetadata(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &namespacedServerStream{ServerStream: ss, ctx: WithNamespace(ss.Context(), namespace)})
+}
+
+type namespacedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *namespacedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// records возвращает хранилище записей пространства имен запроса.
+func (s *HashingService) records(ctx context.Context) storage.HashStore {
+	return storage.InNamespace(s.store, NamespaceFromContext(ctx))
+}
+
+/*
+namespace возвращает описание пространства имен запроса с его квотами. Записывать хеши можно только
+в существующее пространство, поэтому отсутствующее пространство - ошибка FailedPrecondition.
+*/
+func (s *HashingService) namespace(ctx context.Context) (*storage.Namespace, error) {
+	name := NamespaceFromContext(ctx)
+	if name == storage.DefaultNamespace {
+		return &storage.Namespace{Name: name}, nil
+	}
+
+	namespace, err := s.store.GetNamespace(ctx, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.FailedPrecondition, "namespace %q does not exist", name)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get namespace: %v", err)
+	}
+	return namespace, nil
+}
+
+// checkPayloadQuota проверяет размер данных по квоте пространства.
+func checkPayloadQuota(namespace *storage.Namespace, size int64) error {
+	if namespace.MaxPayloadSize > 0 && size > namespace.MaxPayloadSize {
+		return status.Errorf(codes.ResourceExhausted, "payload of %d bytes exceeds the limit of namespace %q (%d bytes)",
+			size, namespace.Name, namespace.MaxPayloadSize)
+	}
+	return nil
+}
+
+/*
+checkHashesQuota проверяет, можно ли сохранить hash, не превысив квоту пространства на число хешей.
+Перезапись существующего хеша число хешей не меняет, а надгробия и хеши с истекшим сроком жизни
+в квоту не входят. Проверка не атомарна с созданием: одновременные запросы могут превысить квоту
+на число таких запросов, поэтому квота ограничивает число хешей приблизительно.
+*/
+func (s *HashingService) checkHashesQuota(ctx context.Context, namespace *storage.Namespace, hash string) error {
+	if namespace.MaxHashes <= 0 {
+		return nil
+	}
+
+	records := s.records(ctx)
+	// Повторное создание восстанавливает хеш из надгробия, и такой хеш снова входит в квоту
+	if existing, err := records.Get(ctx, hash); err == nil && !existing.Deleted() {
+		return nil
+	}
+
+	count, err := records.Count(ctx, "")
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to count hashes: %v", err)
+	}
+	if count >= namespace.MaxHashes {
+		return status.Errorf(codes.ResourceExhausted, "namespace %q has reached its limit of %d hashes", namespace.Name, namespace.MaxHashes)
+	}
+	return nil
+}
+
+/*
+Методы администрирования пространств имен (сервис HashingAdmin).
+*/
+
+func (s *HashingService) CreateNamespace(ctx context.Context, req *pb.CreateNamespaceRequest) (*pb.Namespace, error) {
+	if !namespaceNamePattern.MatchString(req.GetName()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid namespace %q: use lowercase letters, digits and dashes", req.GetName())
+	}
+	if req.GetName() == storage.DefaultNamespace {
+		return nil, status.Errorf(codes.AlreadyExists, "namespace %q already exists", req.GetName())
+	}
+	if req.GetMaxHashes() < 0 || req.GetMaxPayloadSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "quotas must not be negative")
+	}
+
+	namespace := &storage.Namespace{
+		Name:           req.GetName(),
+		CreatedAt:      time.Now(),
+		MaxHashes:      req.GetMaxHashes(),
+		MaxPayloadSize: req.GetMaxPayloadSize(),
+	}
+	if err := s.store.CreateNamespace(ctx, namespace); err != nil {
+		if errors.Is(err, storage.ErrNamespaceExists) {
+			return nil, status.Errorf(codes.AlreadyExists, "namespace %q already exists", req.GetName())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to create namespace: %v", err)
+	}
+
+	return namespaceMessage(namespace), nil
+}
+
+// GetNamespace возвращает пространство имен со статистикой, которая считается обходом его записей.
+func (s *HashingService) GetNamespace(ctx context.Context, req *pb.GetNamespaceRequest) (*pb.Namespace, error) {
+	namespace, err := s.namespace(WithNamespace(ctx, req.GetName()))
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return nil, status.Errorf(codes.NotFound, "namespace %q not found", req.GetName())
+		}
+		return nil, err
+	}
+
+	stats := &pb.NamespaceStats{}
+	err = s.forEachRecord(WithNamespace(ctx, namespace.Name), func(entry storage.ListEntry) error {
+		if entry.Record.Deleted() {
+			return nil
+		}
+		stats.Hashes++
+		stats.TotalSize += entry.Record.Size
+		stats.ReadCount += entry.Record.ReadCount
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to collect namespace statistics: %v", err)
+	}
+
+	resp := namespaceMessage(namespace)
+	resp.Stats = stats
+	return resp, nil
+}
+
+func (s *HashingService) ListNamespaces(ctx context.Context, req *pb.ListNamespacesRequest) (*pb.ListNamespacesResponse, error) {
+	namespaces, err := s.store.ListNamespaces(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list namespaces: %v", err)
+	}
+
+	// Пространство по умолчанию существует всегда и идет первым
+	resp := &pb.ListNamespacesResponse{
+		Namespaces: []*pb.Namespace{namespaceMessage(&storage.Namespace{Name: storage.DefaultNamespace})},
+	}
+	for _, namespace := range namespaces {
+		resp.Namespaces = append(resp.Namespaces, namespaceMessage(namespace))
+	}
+	return resp, nil
+}
+
+// DeleteNamespace удаляет пространство имен вместе со всеми его записями.
+func (s *HashingService) DeleteNamespace(ctx context.Context, req *pb.DeleteNamespaceRequest) (*pb.DeleteNamespaceResponse, error) {
+	if req.GetName() == storage.DefaultNamespace {
+		return nil, status.Errorf(codes.FailedPrecondition, "the default namespace cannot be deleted")
+	}
+
+	// Сначала удаляем описание, чтобы в пространство больше нельзя было записывать
+	if err := s.store.DeleteNamespace(ctx, req.GetName()); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "namespace %q not found", req.GetName())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to delete namespace: %v", err)
+	}
+
+	nsCtx := WithNamespace(ctx, req.GetName())
+	records := s.records(nsCtx)
+	var deleted int64
+	err := s.forEachRecord(nsCtx, func(entry storage.ListEntry) error {
+		err := records.Delete(ctx, entry.Hash)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		s.filterRemove(nsCtx, entry.Hash)
+		s.cacheRemove(nsCtx, entry.Hash)
+		deleted++
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete namespace hashes: %v", err)
+	}
+
+	return &pb.DeleteNamespaceResponse{Name: req.GetName(), DeletedHashes: deleted}, nil
+}
+
+// forEachRecord обходит все записи пространства имен запроса страницами.
+func (s *HashingService) forEachRecord(ctx context.Context, fn func(entry storage.ListEntry) error) error {
+	records := s.records(ctx)
+	query := storage.ListQuery{Limit: MaxListLimit}
+	for {
+		page, err := records.List(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func namespaceMessage(namespace *storage.Namespace) *pb.Namespace {
+	return &pb.Namespace{
+		Name:           namespace.Name,
+		CreatedAt:      optionalTimestamp(namespace.CreatedAt),
+		MaxHashes:      namespace.MaxHashes,
+		MaxPayloadSize: namespace.MaxPayloadSize,
+	}
+}
@@ -0,0 +1,192 @@
+package hashing
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Нормализация текста перед хешированием. Данные, которые отличаются только нормализацией Unicode, переводами
+строк, пробелами по краям или регистром букв, после нормализации дают один хеш. Шаги применяются всегда
+в одном порядке: CRLF -> LF, приведение регистра (case folding), форма Unicode (NFC или NFKC) и обрезка
+пробелов. Форма применяется после приведения регистра, потому что оно может нарушить нормализацию.
+Сохраняются уже нормализованные данные, а шаги записываются в запись хеша (storage.Record.Normalization),
+чтобы по метаданным было видно, с какой нормализацией проверять данные через CheckHash. Если те же
+нормализованные данные создаются повторно с другими шагами, в записи остаются шаги первого создания.
+*/
+
+// Шаги нормализации в записи хеша.
+const (
+	NormalizationCRLF     = "crlf"
+	NormalizationCaseFold = "casefold"
+	NormalizationNFC      = "nfc"
+	NormalizationNFKC     = "nfkc"
+	NormalizationTrim     = "trim"
+)
+
+// textNormalization - проверенные шаги нормализации. Нулевое значение - данные хешируются как есть.
+type textNormalization struct {
+	crlf     bool
+	caseFold bool
+	// form - NormalizationNFC, NormalizationNFKC или пустая строка
+	form string
+	trim bool
+}
+
+// parseNormalization проверяет параметры нормализации из запроса. Ошибка возвращается уже в виде gRPC-статуса.
+func parseNormalization(n *pb.TextNormalization) (textNormalization, error) {
+	normalization := textNormalization{
+		crlf:     n.GetCrlf(),
+		caseFold: n.GetCaseFold(),
+		form:     strings.ToLower(n.GetForm()),
+		trim:     n.GetTrim(),
+	}
+	switch normalization.form {
+	case "", NormalizationNFC, NormalizationNFKC:
+		return normalization, nil
+	default:
+		return textNormalization{}, status.Errorf(codes.InvalidArgument, "unknown Unicode normalization form %q, supported: nfc, nfkc", n.GetForm())
+	}
+}
+
+// parseNormalizationSpec разбирает шаги, записанные в запись хеша через запятую.
+func parseNormalizationSpec(spec string) textNormalization {
+	var normalization textNormalization
+	for _, step := range strings.Split(spec, ",") {
+		switch step {
+		case NormalizationCRLF:
+			normalization.crlf = true
+		case NormalizationCaseFold:
+			normalization.caseFold = true
+		case NormalizationNFC, NormalizationNFKC:
+			normalization.form = step
+		case NormalizationTrim:
+			normalization.trim = true
+		}
+	}
+	return normalization
+}
+
+// String возвращает шаги через запятую в порядке применения - в таком виде они хранятся в записи хеша.
+func (n textNormalization) String() string {
+	var steps []string
+	if n.crlf {
+		steps = append(steps, NormalizationCRLF)
+	}
+	if n.caseFold {
+		steps = append(steps, NormalizationCaseFold)
+	}
+	if n.form != "" {
+		steps = append(steps, n.form)
+	}
+	if n.trim {
+		steps = append(steps, NormalizationTrim)
+	}
+	return strings.Join(steps, ",")
+}
+
+// message возвращает шаги для ответа; nil, если нормализации нет.
+func (n textNormalization) message() *pb.TextNormalization {
+	if n == (textNormalization{}) {
+		return nil
+	}
+	return &pb.TextNormalization{Form: n.form, Crlf: n.crlf, Trim: n.trim, CaseFold: n.caseFold}
+}
+
+// apply нормализует данные. Нормализовать можно только текст в UTF-8.
+func (n textNormalization) apply(data []byte) ([]byte, error) {
+	if n == (textNormalization{}) {
+		return data, nil
+	}
+	if !utf8.Valid(data) {
+		return nil, status.Errorf(codes.InvalidArgument, "text normalization requires a UTF-8 payload")
+	}
+
+	if n.crlf {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	if n.caseFold {
+		data = cases.Fold().Bytes(data)
+	}
+	switch n.form {
+	case NormalizationNFC:
+		data = norm.NFC.Bytes(data)
+	case NormalizationNFKC:
+		data = norm.NFKC.Bytes(data)
+	}
+	if n.trim {
+		data = bytes.TrimSpace(data)
+	}
+	return data, nil
+}
+
+// normalizationCandidates - все сочетания шагов нормализации, кроме пустого, в порядке числа шагов.
+var normalizationCandidates = func() []textNormalization {
+	var candidates []textNormalization
+	for _, crlf := range []bool{false, true} {
+		for _, caseFold := range []bool{false, true} {
+			for _, form := range []string{"", NormalizationNFC, NormalizationNFKC} {
+				for _, trim := range []bool{false, true} {
+					candidates = append(candidates, textNormalization{crlf: crlf, caseFold: caseFold, form: form, trim: trim})
+				}
+			}
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b textNormalization) int {
+		return cmp.Compare(len(a.String()), len(b.String()))
+	})
+	return candidates[1:]
+}()
+
+/*
+recordedNormalization ищет запись, созданную из данных data с нормализацией, для CheckHash без шагов нормализации.
+Шаги хранятся только в самих записях, поэтому перебираются сочетания шагов, которые меняют данные, причем
+одинаковые результаты проверяются один раз. Запись подходит, только если ее собственные шаги
+(storage.Record.Normalization) дают из data те же данные. Возвращаются хеш, запись и ее шаги; если подходящей
+живой записи нет, запись - nil. Бинарные данные не нормализуются, и для них поиск не выполняется.
+*/
+func (s *HashingService) recordedNormalization(ctx context.Context, algorithm keyedHasher, data []byte, canonical bool) (string, *storage.Record, textNormalization, error) {
+	if !utf8.Valid(data) {
+		return "", nil, textNormalization{}, nil
+	}
+
+	seen := map[string]bool{string(data): true}
+	for _, candidate := range normalizationCandidates {
+		normalized, _ := candidate.apply(data)
+		if seen[string(normalized)] {
+			continue
+		}
+		seen[string(normalized)] = true
+
+		payload, err := hashedPayload(normalized, textNormalization{}, canonical)
+		if err != nil {
+			continue
+		}
+		hashString := algorithm.Sum(payload)
+		record, err := s.checkedRecord(ctx, algorithm, hashString)
+		if err != nil {
+			return "", nil, textNormalization{}, err
+		}
+		if record == nil || record.Deleted() {
+			continue
+		}
+
+		recorded := parseNormalizationSpec(record.Normalization)
+		if recordedData, _ := recorded.apply(data); recorded != (textNormalization{}) && bytes.Equal(recordedData, normalized) {
+			return hashString, record, recorded, nil
+		}
+	}
+	return "", nil, textNormalization{}, nil
+}
@@ -0,0 +1,498 @@
+package hashing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Хеширование паролей. Быстрые хеши (SHA-256 и другие) для паролей не подходят, поэтому HashPassword
+считает медленный хеш с солью - argon2id, bcrypt или scrypt - и возвращает его строкой в формате PHC
+(для bcrypt - в его стандартном формате $2a$). В строке записаны алгоритм, параметры и соль, поэтому
+VerifyPassword проверяет пароль по одной этой строке. Ни пароли, ни их хеши сервис не сохраняет:
+хеш хранит вызывающая сторона.
+
+Параметры по умолчанию задает PasswordPolicy. Если пароль верный, но его хеш посчитан другим алгоритмом
+или с параметрами слабее политики, VerifyPassword возвращает needs_rehash - тогда пароль стоит
+захешировать заново.
+*/
+
+// Алгоритмы хеширования паролей.
+const (
+	PasswordArgon2id = "argon2id"
+	PasswordBcrypt   = "bcrypt"
+	PasswordScrypt   = "scrypt"
+)
+
+// Размер соли и хеша для argon2id и scrypt в байтах.
+const (
+	passwordSaltSize = 16
+	passwordKeySize  = 32
+)
+
+// maxPasswordLength ограничивает длину пароля; bcrypt учитывает только первые 72 байта и длиннее не принимает.
+const (
+	maxPasswordLength       = 1024
+	maxBcryptPasswordLength = 72
+)
+
+/*
+Абсолютные верхние границы параметров, в том числе для политики из настроек. Параметры из запросов
+HashPassword и VerifyPassword дополнительно ограничены политикой (см. PasswordPolicy.checkCost).
+*/
+const (
+	maxArgon2Memory      = 1 << 20 // КиБ, то есть 1 ГиБ
+	maxArgon2Iterations  = 64
+	maxArgon2Parallelism = 64
+	maxBcryptCost        = 18
+	maxScryptLogN        = 20
+	maxScryptBlockSize   = 64
+	maxScryptParallelism = 64
+	maxScryptMemory      = 1 << 30 // байт: scrypt занимает 128 * r * N байт
+)
+
+/*
+Параметры из запросов могут быть дороже политики не больше чем в maxPasswordCostFactor раз, иначе
+несколько запросов с подложенными в VerifyPassword хешами заняли бы всю память и процессор сервиса.
+У bcrypt и scrypt (ln) параметр - логарифм стоимости, и для них это прибавка maxPasswordLogCostStep.
+*/
+const (
+	maxPasswordCostFactor  = 2
+	maxPasswordLogCostStep = 1
+)
+
+// DefaultPasswordConcurrency - сколько хешей паролей вычисляется одновременно (см. WithPasswordConcurrency).
+const DefaultPasswordConcurrency = 4
+
+// PasswordParams - параметры стоимости хеширования паролей. Каждый алгоритм использует только свои поля.
+type PasswordParams struct {
+	// argon2id: память в КиБ, число проходов и степень параллелизма
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint32
+	// bcrypt: log2 числа раундов
+	Cost uint32
+	// scrypt: log2 параметра N, размер блока r и параллелизм p
+	LogN              uint32
+	BlockSize         uint32
+	ScryptParallelism uint32
+}
+
+// PasswordPolicy - алгоритм и параметры, с которыми HashPassword хеширует пароли по умолчанию.
+type PasswordPolicy struct {
+	Algorithm string
+	Params    PasswordParams
+}
+
+// DefaultPasswordPolicy - argon2id с параметрами, рекомендованными OWASP, и рекомендации OWASP для bcrypt и scrypt.
+var DefaultPasswordPolicy = PasswordPolicy{
+	Algorithm: PasswordArgon2id,
+	Params: PasswordParams{
+		Memory:            19 * 1024,
+		Iterations:        2,
+		Parallelism:       1,
+		Cost:              10,
+		LogN:              17,
+		BlockSize:         8,
+		ScryptParallelism: 1,
+	},
+}
+
+/*
+ParsePasswordPolicy возвращает политику с алгоритмом algorithm и параметрами params в нотации PHC:
+"m=19456,t=2,p=1" для argon2id, "cost=10" для bcrypt и "ln=17,r=8,p=1" для scrypt. Не указанные
+параметры берутся из DefaultPasswordPolicy.
+*/
+func ParsePasswordPolicy(algorithm, params string) (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy
+	if algorithm != "" {
+		policy.Algorithm = strings.ToLower(algorithm)
+	}
+
+	var err error
+	if policy.Params, err = parsePasswordParams(policy.Algorithm, params, policy.Params); err != nil {
+		return PasswordPolicy{}, err
+	}
+	if err := policy.Params.validate(policy.Algorithm); err != nil {
+		return PasswordPolicy{}, err
+	}
+	return policy, nil
+}
+
+// passwordParamNames - имена параметров алгоритмов в нотации PHC.
+var passwordParamNames = map[string]map[string]func(*PasswordParams) *uint32{
+	PasswordArgon2id: {
+		"m": func(p *PasswordParams) *uint32 { return &p.Memory },
+		"t": func(p *PasswordParams) *uint32 { return &p.Iterations },
+		"p": func(p *PasswordParams) *uint32 { return &p.Parallelism },
+	},
+	PasswordBcrypt: {
+		"cost": func(p *PasswordParams) *uint32 { return &p.Cost },
+	},
+	PasswordScrypt: {
+		"ln": func(p *PasswordParams) *uint32 { return &p.LogN },
+		"r":  func(p *PasswordParams) *uint32 { return &p.BlockSize },
+		"p":  func(p *PasswordParams) *uint32 { return &p.ScryptParallelism },
+	},
+}
+
+// parsePasswordParams разбирает параметры вида "m=19456,t=2,p=1" поверх base.
+func parsePasswordParams(algorithm, value string, base PasswordParams) (PasswordParams, error) {
+	names, ok := passwordParamNames[algorithm]
+	if !ok {
+		return PasswordParams{}, fmt.Errorf("unknown password algorithm %q, supported: argon2id, bcrypt, scrypt", algorithm)
+	}
+
+	params := base
+	if value == "" {
+		return params, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		name, number, _ := strings.Cut(pair, "=")
+		field, ok := names[name]
+		if !ok {
+			return PasswordParams{}, fmt.Errorf("unknown %s parameter %q", algorithm, name)
+		}
+		parsed, err := strconv.ParseUint(number, 10, 32)
+		if err != nil {
+			return PasswordParams{}, fmt.Errorf("invalid %s parameter %q: %w", algorithm, name, err)
+		}
+		*field(&params) = uint32(parsed)
+	}
+	return params, nil
+}
+
+// merge возвращает параметры p, в которых нулевые значения заменены значениями из override, если они не нулевые.
+func (p PasswordParams) merge(override *pb.PasswordParams) PasswordParams {
+	set := func(field *uint32, value uint32) {
+		if value != 0 {
+			*field = value
+		}
+	}
+	set(&p.Memory, override.GetMemory())
+	set(&p.Iterations, override.GetIterations())
+	set(&p.Parallelism, override.GetParallelism())
+	set(&p.Cost, override.GetCost())
+	set(&p.LogN, override.GetLogN())
+	set(&p.BlockSize, override.GetBlockSize())
+	set(&p.ScryptParallelism, override.GetScryptParallelism())
+	return p
+}
+
+// validate проверяет, что параметры алгоритма лежат в допустимых границах.
+func (p PasswordParams) validate(algorithm string) error {
+	switch algorithm {
+	case PasswordArgon2id:
+		if p.Parallelism < 1 || p.Parallelism > maxArgon2Parallelism {
+			return fmt.Errorf("argon2id parallelism must be between 1 and %d", maxArgon2Parallelism)
+		}
+		if p.Iterations < 1 || p.Iterations > maxArgon2Iterations {
+			return fmt.Errorf("argon2id iterations must be between 1 and %d", maxArgon2Iterations)
+		}
+		if p.Memory < 8*p.Parallelism || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB", 8*p.Parallelism, maxArgon2Memory)
+		}
+	case PasswordBcrypt:
+		if p.Cost < uint32(bcrypt.MinCost) || p.Cost > maxBcryptCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, maxBcryptCost)
+		}
+	case PasswordScrypt:
+		if p.LogN < 1 || p.LogN > maxScryptLogN {
+			return fmt.Errorf("scrypt ln must be between 1 and %d", maxScryptLogN)
+		}
+		if p.BlockSize < 1 || p.BlockSize > maxScryptBlockSize {
+			return fmt.Errorf("scrypt r must be between 1 and %d", maxScryptBlockSize)
+		}
+		if p.ScryptParallelism < 1 || p.ScryptParallelism > maxScryptParallelism {
+			return fmt.Errorf("scrypt p must be between 1 and %d", maxScryptParallelism)
+		}
+		if 128*uint64(p.BlockSize)<<p.LogN > maxScryptMemory {
+			return fmt.Errorf("scrypt parameters need more than %d bytes of memory", maxScryptMemory)
+		}
+	default:
+		return fmt.Errorf("unknown password algorithm %q, supported: argon2id, bcrypt, scrypt", algorithm)
+	}
+	return nil
+}
+
+// checkCost проверяет, что параметры из запроса дороже параметров политики не больше чем в maxPasswordCostFactor раз.
+func (p PasswordPolicy) checkCost(algorithm string, params PasswordParams) error {
+	limit := p.Params
+	switch algorithm {
+	case PasswordArgon2id:
+		if params.Memory > maxPasswordCostFactor*limit.Memory || params.Iterations > maxPasswordCostFactor*limit.Iterations ||
+			params.Parallelism > maxPasswordCostFactor*limit.Parallelism {
+			return fmt.Errorf("argon2id parameters must not exceed m=%d,t=%d,p=%d", maxPasswordCostFactor*limit.Memory,
+				maxPasswordCostFactor*limit.Iterations, maxPasswordCostFactor*limit.Parallelism)
+		}
+	case PasswordBcrypt:
+		if params.Cost > limit.Cost+maxPasswordLogCostStep {
+			return fmt.Errorf("bcrypt cost must not exceed %d", limit.Cost+maxPasswordLogCostStep)
+		}
+	case PasswordScrypt:
+		if params.LogN > limit.LogN+maxPasswordLogCostStep || params.BlockSize > maxPasswordCostFactor*limit.BlockSize ||
+			params.ScryptParallelism > maxPasswordCostFactor*limit.ScryptParallelism ||
+			uint64(params.BlockSize)<<params.LogN > maxPasswordCostFactor*uint64(limit.BlockSize)<<limit.LogN {
+			return fmt.Errorf("scrypt parameters must not exceed ln=%d,r=%d,p=%d and %d bytes of memory",
+				limit.LogN+maxPasswordLogCostStep, maxPasswordCostFactor*limit.BlockSize,
+				maxPasswordCostFactor*limit.ScryptParallelism, maxPasswordCostFactor*128*uint64(limit.BlockSize)<<limit.LogN)
+		}
+	}
+	return nil
+}
+
+// passwordHash - разобранная строка хеша пароля.
+type passwordHash struct {
+	algorithm string
+	params    PasswordParams
+	// version - версия argon2
+	version int
+	salt    []byte
+	hash    []byte
+	// encoded - исходная строка; bcrypt проверяет пароль по ней сам
+	encoded string
+}
+
+// encode записывает хеш строкой в формате PHC.
+func (h *passwordHash) encode() string {
+	if h.algorithm == PasswordBcrypt {
+		return h.encoded
+	}
+
+	salt := base64.RawStdEncoding.EncodeToString(h.salt)
+	hash := base64.RawStdEncoding.EncodeToString(h.hash)
+	if h.algorithm == PasswordArgon2id {
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			h.version, h.params.Memory, h.params.Iterations, h.params.Parallelism, salt, hash)
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+		h.params.LogN, h.params.BlockSize, h.params.ScryptParallelism, salt, hash)
+}
+
+// derive вычисляет хеш пароля с параметрами и солью h длиной size байт.
+func (h *passwordHash) derive(password []byte, size int) ([]byte, error) {
+	switch h.algorithm {
+	case PasswordArgon2id:
+		return argon2.IDKey(password, h.salt, h.params.Iterations, h.params.Memory, uint8(h.params.Parallelism), uint32(size)), nil
+	case PasswordScrypt:
+		return scrypt.Key(password, h.salt, 1<<h.params.LogN, int(h.params.BlockSize), int(h.params.ScryptParallelism), size)
+	}
+	return nil, fmt.Errorf("unknown password algorithm %q", h.algorithm)
+}
+
+// hashPassword хеширует пароль алгоритмом algorithm со случайной солью.
+func hashPassword(algorithm string, params PasswordParams, password []byte) (*passwordHash, error) {
+	h := &passwordHash{algorithm: algorithm, params: params, version: argon2.Version}
+	if algorithm == PasswordBcrypt {
+		encoded, err := bcrypt.GenerateFromPassword(password, int(params.Cost))
+		if err != nil {
+			return nil, err
+		}
+		h.encoded = string(encoded)
+		return h, nil
+	}
+
+	h.salt = make([]byte, passwordSaltSize)
+	if _, err := rand.Read(h.salt); err != nil {
+		return nil, err
+	}
+	var err error
+	h.hash, err = h.derive(password, passwordKeySize)
+	return h, err
+}
+
+// parsePasswordHash разбирает строку хеша пароля и проверяет границы параметров.
+func parsePasswordHash(encoded string) (*passwordHash, error) {
+	if strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$") {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return nil, err
+		}
+		h := &passwordHash{algorithm: PasswordBcrypt, params: PasswordParams{Cost: uint32(cost)}, encoded: encoded}
+		return h, h.params.validate(PasswordBcrypt)
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 2 || parts[0] != "" {
+		return nil, errors.New("hash is not in PHC format")
+	}
+	h := &passwordHash{algorithm: parts[1]}
+	switch {
+	case h.algorithm == PasswordArgon2id && len(parts) == 6:
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &h.version); err != nil {
+			return nil, fmt.Errorf("invalid argon2id version %q", parts[2])
+		}
+		if h.version != argon2.Version {
+			return nil, fmt.Errorf("unsupported argon2id version %d", h.version)
+		}
+		parts = append(parts[:2], parts[3:]...)
+	case h.algorithm == PasswordScrypt && len(parts) == 5:
+	default:
+		return nil, errors.New("hash is not an argon2id, bcrypt or scrypt hash in PHC format")
+	}
+
+	var err error
+	if h.params, err = parsePasswordParams(h.algorithm, parts[2], PasswordParams{}); err != nil {
+		return nil, err
+	}
+	if err := h.params.validate(h.algorithm); err != nil {
+		return nil, err
+	}
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	if h.hash, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid hash: %w", err)
+	}
+	if len(h.hash) < 16 || len(h.hash) > 64 {
+		return nil, fmt.Errorf("hash must be between 16 and 64 bytes long")
+	}
+	return h, nil
+}
+
+// verify сравнивает хеш пароля с h за время, не зависящее от того, где они различаются.
+func (h *passwordHash) verify(password []byte) (bool, error) {
+	if h.algorithm == PasswordBcrypt {
+		// Пароль длиннее 72 байт нельзя было захешировать через HashPassword
+		if len(password) > maxBcryptPasswordLength {
+			return false, nil
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(h.encoded), password)
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	derived, err := h.derive(password, len(h.hash))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(derived, h.hash) == 1, nil
+}
+
+// needsRehash сообщает, посчитан ли хеш другим алгоритмом или с параметрами слабее политики.
+func (p PasswordPolicy) needsRehash(h *passwordHash) bool {
+	if h.algorithm != p.Algorithm {
+		return true
+	}
+	switch h.algorithm {
+	case PasswordArgon2id:
+		return h.params.Memory < p.Params.Memory || h.params.Iterations < p.Params.Iterations ||
+			h.params.Parallelism < p.Params.Parallelism || len(h.hash) < passwordKeySize
+	case PasswordBcrypt:
+		return h.params.Cost < p.Params.Cost
+	case PasswordScrypt:
+		return h.params.LogN < p.Params.LogN || h.params.BlockSize < p.Params.BlockSize ||
+			h.params.ScryptParallelism < p.Params.ScryptParallelism || len(h.hash) < passwordKeySize
+	}
+	return false
+}
+
+// validatePassword проверяет длину пароля; пустые пароли не принимаются.
+func validatePassword(algorithm, password string) error {
+	if password == "" {
+		return status.Errorf(codes.InvalidArgument, "password is required")
+	}
+	if len(password) > maxPasswordLength {
+		return status.Errorf(codes.InvalidArgument, "password must be at most %d bytes long", maxPasswordLength)
+	}
+	if algorithm == PasswordBcrypt && len(password) > maxBcryptPasswordLength {
+		return status.Errorf(codes.InvalidArgument, "bcrypt password must be at most %d bytes long", maxBcryptPasswordLength)
+	}
+	return nil
+}
+
+/*
+acquirePasswordSlot ждет, пока одновременно вычисляемых хешей паролей станет меньше WithPasswordConcurrency,
+и возвращает функцию, освобождающую место. Медленные хеши занимают память и процессор, поэтому без этого
+ограничения параллельные запросы могли бы исчерпать память сервиса.
+*/
+func (s *HashingService) acquirePasswordSlot(ctx context.Context) (func(), error) {
+	select {
+	case s.passwordSlots <- struct{}{}:
+		return func() { <-s.passwordSlots }, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+/*
+HashPassword хеширует пароль алгоритмом из запроса (по умолчанию - алгоритмом политики) с параметрами
+из запроса; не указанные параметры берутся из политики. Параметры дороже политики больше чем
+в maxPasswordCostFactor раз возвращают InvalidArgument.
+*/
+func (s *HashingService) HashPassword(ctx context.Context, req *pb.HashPasswordRequest) (*pb.HashPasswordResponse, error) {
+	algorithm := strings.ToLower(req.GetAlgorithm())
+	if algorithm == "" {
+		algorithm = s.passwordPolicy.Algorithm
+	}
+	params := s.passwordPolicy.Params.merge(req.GetParams())
+	if err := params.validate(algorithm); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := s.passwordPolicy.checkCost(algorithm, params); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := validatePassword(algorithm, req.GetPassword()); err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquirePasswordSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	h, err := hashPassword(algorithm, params, []byte(req.GetPassword()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to hash password: %v", err)
+	}
+	return &pb.HashPasswordResponse{Hash: h.encode(), Algorithm: algorithm}, nil
+}
+
+/*
+VerifyPassword проверяет пароль по строке хеша. Неверный пароль - не ошибка: в ответе valid = false.
+Некорректная строка хеша или параметры за допустимыми границами, в том числе дороже политики больше
+чем в maxPasswordCostFactor раз, возвращают InvalidArgument до вычисления хеша.
+*/
+func (s *HashingService) VerifyPassword(ctx context.Context, req *pb.VerifyPasswordRequest) (*pb.VerifyPasswordResponse, error) {
+	h, err := parsePasswordHash(req.GetHash())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid password hash: %v", err)
+	}
+	if err := s.passwordPolicy.checkCost(h.algorithm, h.params); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid password hash: %v", err)
+	}
+	if req.GetPassword() == "" || len(req.GetPassword()) > maxPasswordLength {
+		return &pb.VerifyPasswordResponse{Algorithm: h.algorithm}, nil
+	}
+
+	release, err := s.acquirePasswordSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	valid, err := h.verify([]byte(req.GetPassword()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to verify password: %v", err)
+	}
+	return &pb.VerifyPasswordResponse{
+		Valid:       valid,
+		NeedsRehash: valid && s.passwordPolicy.needsRehash(h),
+		Algorithm:   h.algorithm,
+	}, nil
+}
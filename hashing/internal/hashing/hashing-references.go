@@ -0,0 +1,122 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+Счетчики ссылок. Одни и те же данные могут сохранять несколько систем, и ни одна из них не может удалить
+хеш, не зная, нужен ли он остальным. Поэтому CreateHash и CreateHashStream с владельцем (owner) берут
+ссылку на хеш, а ReleaseHash ее освобождает. Данные по-прежнему хранятся один раз - считаются только
+ссылки. Когда последняя ссылка освобождена и прошел льготный период (WithGCGracePeriod), запись удаляет
+сборщик мусора (CollectGarbage). Хеши, созданные без владельца, сборщик мусора не трогает.
+*/
+
+const (
+	// DefaultGCGracePeriod - сколько хранится хеш после освобождения последней ссылки.
+	DefaultGCGracePeriod = 24 * time.Hour
+	// MaxOwnerLength - максимальная длина идентификатора владельца.
+	MaxOwnerLength = 256
+)
+
+func validateOwner(owner string) error {
+	if len(owner) > MaxOwnerLength {
+		return status.Errorf(codes.InvalidArgument, "owner must not be longer than %d bytes", MaxOwnerLength)
+	}
+	return nil
+}
+
+/*
+Метод ReleaseHash освобождает одну ссылку владельца на хеш. Если владелец не брал ссылку, возвращается
+FailedPrecondition. Когда ссылок не остается, в ответе возвращается время освобождения: хеш будет удален
+сборщиком мусора после льготного периода, если за это время на него снова не возьмут ссылку.
+*/
+
+func (s *HashingService) ReleaseHash(ctx context.Context, req *pb.ReleaseHashRequest) (*pb.ReleaseHashResponse, error) {
+	if req.GetOwner() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "owner is required")
+	}
+
+	hash, _, err := s.getRecord(ctx, req.GetHash(), req.GetAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	references, err := s.records(ctx).ReleaseReference(ctx, hash, req.GetOwner(), now)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Errorf(codes.NotFound, "hash not found")
+		case errors.Is(err, storage.ErrNoReference):
+			return nil, status.Errorf(codes.FailedPrecondition, "owner %q holds no reference to hash %s", req.GetOwner(), hash)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to release hash: %v", err)
+	}
+
+	resp := &pb.ReleaseHashResponse{Hash: hash, References: references}
+	if references <= 0 {
+		resp.ReleasedAt = timestamppb.New(now)
+	}
+	return resp, nil
+}
+
+// addReference берет ссылку владельца на существующую запись.
+func (s *HashingService) addReference(ctx context.Context, hash, owner string) error {
+	_, err := s.records(ctx).AddReference(ctx, hash, owner)
+	return err
+}
+
+/*
+CollectGarbage удаляет записи всех пространств имен, последняя ссылка на которые освобождена раньше,
+чем льготный период назад, и возвращает число удаленных записей. Запись удаляется атомарно с проверкой,
+поэтому ссылка, взятая во время сборки, ее сохраняет.
+*/
+func (s *HashingService) CollectGarbage(ctx context.Context, now time.Time) (int, error) {
+	releasedBefore := now.Add(-s.gcGracePeriod)
+	query := storage.ListQuery{
+		Limit: MaxListLimit,
+		Filter: func(hash string, record *storage.Record) bool {
+			return record.Unreferenced(releasedBefore)
+		},
+	}
+
+	collected := 0
+	for {
+		page, err := s.store.List(ctx, query)
+		if err != nil {
+			return collected, err
+		}
+		for _, entry := range page.Entries {
+			deleted, err := s.store.DeleteUnreferenced(ctx, entry.Hash, releasedBefore)
+			if err != nil {
+				return collected, err
+			}
+			if !deleted {
+				continue
+			}
+			collected++
+
+			// Ключи List - ключи хранилища вместе с пространством имен, как в фильтре и кеше
+			if s.filter != nil {
+				s.filter.Remove(entry.Hash)
+			}
+			if s.cache != nil {
+				s.cache.Remove(entry.Hash)
+			}
+		}
+		if page.NextCursor == "" {
+			return collected, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,71 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+/*
+Срок жизни записей. TTL можно передать в CreateHash, CreateHashStream и TouchHash; если он не указан,
+используется политика хранения сервиса (WithDefaultTTL). Нулевая политика означает бессрочное хранение.
+Записи с истекшим сроком во всех хранилищах ведут себя как отсутствующие и дают NotFound.
+*/
+
+// expiresAt вычисляет время истечения срока жизни записи. Нулевое время означает бессрочную запись.
+func (s *HashingService) expiresAt(ttl *durationpb.Duration, now time.Time) (time.Time, error) {
+	lifetime := s.defaultTTL
+	if ttl != nil {
+		if err := ttl.CheckValid(); err != nil {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "invalid ttl: %v", err)
+		}
+		if ttl.AsDuration() < 0 {
+			return time.Time{}, status.Errorf(codes.InvalidArgument, "ttl must not be negative")
+		}
+		if ttl.AsDuration() > 0 {
+			lifetime = ttl.AsDuration()
+		}
+	}
+
+	if lifetime == 0 {
+		return time.Time{}, nil
+	}
+	return now.Add(lifetime), nil
+}
+
+/*
+Метод TouchHash продлевает (или сокращает) срок жизни существующего хеша: новый срок отсчитывается от
+текущего момента. Возвращает обновленные метаданные.
+*/
+
+func (s *HashingService) TouchHash(ctx context.Context, req *pb.TouchHashRequest) (*pb.HashMetadata, error) {
+	hash, record, err := s.getRecord(ctx, req.GetHash(), req.GetAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := s.expiresAt(req.GetTtl(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.records(ctx).SetExpiration(ctx, hash, expiresAt)
+	if err != nil {
+		// Запись могла истечь между чтением и обновлением
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "hash not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to touch hash: %v", err)
+	}
+	s.cacheRemove(ctx, hash)
+
+	record.ExpiresAt = expiresAt
+	return hashMetadata(hash, record), nil
+}
@@ -2,53 +2,137 @@ package hashing
 
 import (
 	"context"
-	"crypto/sha256"
-	"fmt"
+	"encoding/hex"
+	"errors"
+	"time"
 
+	"final-project-kodzimo-hashing/internal/storage"
 	pb "final-project-kodzimo-shared/proto"
 
-	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 /*
-В этом примере HashingService содержит клиента Redis, который используется для взаимодействия с базой данных.
-Метод CreateHash вычисляет SHA-256 хеш от входных данных и сохраняет его в базе данных Redis.
+В этом примере HashingService содержит хранилище storage.Store, которое используется для взаимодействия
+с базой данных. Записи каждого запроса берутся из его пространства имен (см. records). Метод CreateHash вычисляет хеш от входных данных выбранным алгоритмом (по умолчанию SHA-256)
+и сохраняет его в хранилище вместе с именем алгоритма.
 
-Функция ConnectToRedis устанавливает соединение с сервером Redis и возвращает клиента Redis, который затем
-передается в HashingService.
+Функция storage.ConnectToStore создает хранилище (Redis, в памяти или файл bbolt), выбранное конфигурацией,
+которое затем передается в HashingService.
 */
 
 type HashingService struct {
-	redisClient *redis.Client
+	store      storage.Store
+	algorithms *AlgorithmRegistry
+
+	maxStoredPayloadSize int64
+	defaultTTL           time.Duration
+	// filter - фильтр Блума для быстрых отрицательных ответов CheckHash (nil, если выключен)
+	filter *HashFilter
+	// cache - LRU-кеш данных для GetHash (nil, если выключен)
+	cache *PayloadCache
+	// gcGracePeriod - сколько хранится хеш после освобождения последней ссылки
+	gcGracePeriod time.Duration
+	// tombstoneRetention - сколько хранится надгробие удаленного хеша (0 - бессрочно)
+	tombstoneRetention time.Duration
+	// passwordPolicy - алгоритм и параметры хеширования паролей по умолчанию
+	passwordPolicy PasswordPolicy
+	// passwordSlots ограничивает число одновременно вычисляемых хешей паролей
+	passwordConcurrency int
+	passwordSlots       chan struct{}
 }
 
-func NewHashingService(redisClient *redis.Client) *HashingService {
-	return &HashingService{redisClient: redisClient}
+func NewHashingService(store storage.Store, opts ...Option) *HashingService {
+	s := &HashingService{
+		store:                store,
+		algorithms:           NewAlgorithmRegistry(),
+		maxStoredPayloadSize: DefaultMaxStoredPayloadSize,
+		gcGracePeriod:        DefaultGCGracePeriod,
+		tombstoneRetention:   DefaultTombstoneRetention,
+		passwordPolicy:       DefaultPasswordPolicy,
+		passwordConcurrency:  DefaultPasswordConcurrency,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.passwordSlots = make(chan struct{}, max(s.passwordConcurrency, 1))
+	return s
+}
+
+// Algorithms возвращает реестр алгоритмов, в котором можно зарегистрировать дополнительные алгоритмы.
+func (s *HashingService) Algorithms() *AlgorithmRegistry {
+	return s.algorithms
 }
 
 /*
-Метод CheckHash. Этот метод будет принимать входные данные, проверять, существует ли уже хеш для этих данных
-в базе данных, и возвращать результат.
+Метод CheckHash. Этот метод принимает входные данные, вычисляет их хеш выбранным алгоритмом и проверяет,
+есть ли уже такой хеш в хранилище. Отсутствие хеша - не ошибка: в ответе просто exists = false.
+Если указан ключ, хеш считается с ключом (по умолчанию его текущей версией, см. hashing-keys.go).
+Если нормализация в запросе не указана и данных как есть в хранилище нет, ищется запись, созданная
+с нормализацией (см. recordedNormalization): тогда в ответе ее хеш и ее шаги нормализации.
 */
 
-func (s *HashingService) CheckHash(ctx context.Context, req *pb.HashRequest) (*pb.HashResponse, error) {
-	// Получаем данные из запроса
-	payload := req.GetPayload()
+func (s *HashingService) CheckHash(ctx context.Context, req *pb.HashRequest) (*pb.CheckHashResponse, error) {
+	if err := validateEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+	algorithm, err := s.hasher(ctx, req.GetAlgorithm(), req.GetKey(), req.GetKeyVersion())
+	if err != nil {
+		return nil, err
+	}
+	normalization, err := parseNormalization(req.GetNormalization())
+	if err != nil {
+		return nil, err
+	}
 
-	// Ищем хеш в базе данных
-	hash, err := s.redisClient.Get(ctx, payload).Result()
+	// Вычисляем хеш так же, как при создании, и ищем его в хранилище
+	data := payloadBytes(req)
+	payload, err := hashedPayload(data, normalization, req.GetCanonicalJson())
 	if err != nil {
-		// Если произошла ошибка при поиске хеша, возвращаем ошибку
-		if err == redis.Nil {
-			return nil, status.Errorf(codes.NotFound, "hash not found")
+		return nil, err
+	}
+	hashString := algorithm.Sum(payload)
+	record, err := s.checkedRecord(ctx, algorithm, hashString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Данные могли сохраниться нормализованными, а клиент проверяет их как есть
+	if req.GetNormalization() == nil && (record == nil || record.Deleted()) {
+		normalizedHash, normalizedRecord, recorded, err := s.recordedNormalization(ctx, algorithm, data, req.GetCanonicalJson())
+		if err != nil {
+			return nil, err
+		}
+		if normalizedRecord != nil {
+			hashString, record, normalization = normalizedHash, normalizedRecord, recorded
 		}
-		return nil, status.Errorf(codes.Internal, "failed to get hash: %v", err)
 	}
 
-	// Если хеш найден, возвращаем его
-	return &pb.HashResponse{Hash: hash}, nil
+	formatted, err := formatHash(req.GetEncoding(), req.GetMultihash(), algorithm.Name, algorithm.key, hashString)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.CheckHashResponse{
+		Hash:          formatted,
+		Algorithm:     algorithm.Name,
+		Key:           algorithm.key,
+		KeyVersion:    algorithm.keyVersion,
+		Normalization: normalization.message(),
+	}
+	if record == nil {
+		return resp, nil
+	}
+	// Удаленный хеш не существует, но в ответе отмечается, что он был удален
+	if record.Deleted() {
+		resp.Deleted = true
+		resp.DeletedAt = optionalTimestamp(record.DeletedAt)
+		return resp, nil
+	}
+
+	resp.Exists = true
+	resp.CreatedAt = optionalTimestamp(record.CreatedAt)
+	return resp, nil
 }
 
 /*
@@ -77,36 +161,262 @@ res, err := hashingService.GetHash(ctx, req)
 используется для управления временем выполнения и отменой запроса.
 */
 
+// payloadBytes возвращает данные запроса: бинарное поле data, а если оно пустое - текстовое поле payload.
+/*
+checkedRecord ищет запись хеша hashString, посчитанного алгоритмом algorithm. Запись того же хеша, полученного
+другим алгоритмом или с другим ключом, не считается совпадением: тогда, как и без записи, возвращается nil.
+Надгробия возвращаются.
+*/
+func (s *HashingService) checkedRecord(ctx context.Context, algorithm keyedHasher, hashString string) (*storage.Record, error) {
+	// Хеш, которого точно нет по фильтру, не ищем в хранилище
+	if !s.filterMayContain(ctx, hashString) {
+		return nil, nil
+	}
+
+	record, err := s.records(ctx).Get(ctx, hashString)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.filterMiss()
+			return nil, nil
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get hash: %v", err)
+	}
+	if !algorithm.matches(record) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func payloadBytes(req *pb.HashRequest) []byte {
+	if len(req.GetData()) > 0 {
+		return req.GetData()
+	}
+	return []byte(req.GetPayload())
+}
+
+/*
+GetHash ищет запись по хешу, переданному в payload, и возвращает исходные данные вместе с их типом.
+Каждое успешное чтение учитывается в статистике записи (время последнего чтения и счетчик чтений).
+*/
+
 func (s *HashingService) GetHash(ctx context.Context, req *pb.HashRequest) (*pb.HashResponse, error) {
-	// Получаем данные из запроса
-	payload := req.GetPayload()
+	if err := validateEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+
+	// Данные хеша не меняются, поэтому сначала ищем их в кеше. Статистику чтения обновляем и при чтении
+	// из кеша: это небольшое атомарное обновление без чтения данных, и отдельная горутина на каждое
+	// чтение при нагрузке копила бы обращения к хранилищу без ограничения.
+	if record, ok := s.cacheGet(ctx, req.GetPayload()); ok && s.algorithmMatches(record, req.GetAlgorithm()) {
+		formatted, err := formatHash(req.GetEncoding(), req.GetMultihash(), record.Algorithm, record.MACKey, req.GetPayload())
+		if err != nil {
+			return nil, err
+		}
+		s.recordAccess(ctx, req.GetPayload())
+		return hashResponse(formatted, record), nil
+	}
+
+	generation := s.cacheGeneration()
+	hash, record, err := s.getRecord(ctx, req.GetPayload(), req.GetAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	// Данные, созданные потоком и превысившие лимит, не сохранялись - вернуть их нельзя
+	if !record.PayloadStored() {
+		return nil, status.Errorf(codes.FailedPrecondition, "payload of %d bytes was too large to be stored", record.Size)
+	}
+	formatted, err := formatHash(req.GetEncoding(), req.GetMultihash(), record.Algorithm, record.MACKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAccess(ctx, hash)
+	s.cacheAdd(ctx, hash, record, generation)
+
+	return hashResponse(formatted, record), nil
+}
+
+// hashResponse возвращает полный хеш, исходные данные и их тип.
+func hashResponse(hash string, record *storage.Record) *pb.HashResponse {
+	return &pb.HashResponse{
+		Hash:          hash,
+		Algorithm:     record.Algorithm,
+		Payload:       record.Payload,
+		ContentType:   record.ContentType,
+		Key:           record.MACKey,
+		KeyVersion:    record.MACKeyVersion,
+		Normalization: parseNormalizationSpec(record.Normalization).message(),
+	}
+}
+
+// algorithmMatches проверяет, что запись создана алгоритмом из запроса (если он указан).
+func (s *HashingService) algorithmMatches(record *storage.Record, algorithmName string) bool {
+	if algorithmName == "" {
+		return true
+	}
+	algorithm, err := s.algorithms.Lookup(algorithmName)
+	return err == nil && algorithm.Name == record.Algorithm
+}
+
+/*
+getRecord ищет запись по хешу или его сокращению (см. resolveHash) и возвращает полный хеш вместе с записью.
+Если указан алгоритм, запись должна быть создана именно им, иначе считаем, что хеш не найден. Удаленный хеш
+(надгробие) возвращает NotFound с причиной ReasonHashDeleted. Ошибки возвращаются уже в виде gRPC-статусов.
+*/
+
+func (s *HashingService) getRecord(ctx context.Context, hash string, algorithmName string) (string, *storage.Record, error) {
+	hash, record, err := s.lookupRecord(ctx, hash, algorithmName)
+	if err != nil {
+		return "", nil, err
+	}
+	if record.Deleted() {
+		return "", nil, hashDeletedError(record.DeletedAt)
+	}
+
+	return hash, record, nil
+}
 
-	// Ищем хеш в базе данных
-	hash, err := s.redisClient.Get(ctx, payload).Result()
+// lookupRecord работает как getRecord, но возвращает и надгробия удаленных хешей.
+func (s *HashingService) lookupRecord(ctx context.Context, hash string, algorithmName string) (string, *storage.Record, error) {
+	var algorithm Algorithm
+	if algorithmName != "" {
+		var err error
+		algorithm, err = s.algorithms.Lookup(algorithmName)
+		if err != nil {
+			return "", nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	// Ищем запись в хранилище: сначала по полному хешу, затем как сокращение
+	records := s.records(ctx)
+	record, err := records.Get(ctx, hash)
+	// Multihash ищем по хешу внутри него: запись должна быть создана тем же алгоритмом и без ключа
+	if errors.Is(err, storage.ErrNotFound) {
+		if multihashAlgorithm, digest, ok := s.decodeMultihash(hash); ok {
+			hash = hex.EncodeToString(digest)
+			record, err = records.Get(ctx, hash)
+			if err == nil && (record.Algorithm != multihashAlgorithm || record.MACKey != "") {
+				return "", nil, status.Errorf(codes.NotFound, "hash not found for algorithm %s", multihashAlgorithm)
+			}
+		}
+	}
+	// Хеш в другой кодировке (base64url, base32, base58, multibase) ищем по каноническому виду
+	if errors.Is(err, storage.ErrNotFound) {
+		for _, candidate := range s.hashCandidates(hash) {
+			candidateRecord, candidateErr := records.Get(ctx, candidate)
+			if !errors.Is(candidateErr, storage.ErrNotFound) {
+				hash, record, err = candidate, candidateRecord, candidateErr
+				break
+			}
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) && isAbbreviation(hash) {
+		var resolveErr error
+		hash, resolveErr = s.resolveHash(ctx, hash)
+		if resolveErr != nil {
+			return "", nil, resolveErr
+		}
+		record, err = records.Get(ctx, hash)
+	}
 	if err != nil {
 		// Если произошла ошибка при поиске хеша, возвращаем ошибку
-		if err == redis.Nil {
-			return nil, status.Errorf(codes.NotFound, "hash not found")
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", nil, status.Errorf(codes.NotFound, "hash not found")
 		}
-		return nil, status.Errorf(codes.Internal, "failed to get hash: %v", err)
+		return "", nil, status.Errorf(codes.Internal, "failed to get hash: %v", err)
+	}
+	if algorithm.Name != "" && record.Algorithm != algorithm.Name {
+		return "", nil, status.Errorf(codes.NotFound, "hash not found for algorithm %s", algorithm.Name)
 	}
 
-	// Если хеш найден, возвращаем его
-	return &pb.HashResponse{Hash: hash}, nil
+	return hash, record, nil
 }
 
 func (s *HashingService) CreateHash(ctx context.Context, req *pb.HashRequest) (*pb.HashResponse, error) {
-	// Здесь вычисляется хеш SHA-256 от payload, который был передан в запросе
-	hash := sha256.Sum256([]byte(req.Payload))
-	// Здесь хеш, который является байтовым массивом, преобразуется в строку шестнадцатеричных символов
-	hashString := fmt.Sprintf("%x", hash)
+	// Находим алгоритм, указанный в запросе (по умолчанию SHA-256), и ключ, если он указан
+	algorithm, err := s.hasher(ctx, req.GetAlgorithm(), req.GetKey(), req.GetKeyVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateOwner(req.GetOwner()); err != nil {
+		return nil, err
+	}
+	if err := validateEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+	normalization, err := parseNormalization(req.GetNormalization())
+	if err != nil {
+		return nil, err
+	}
+	// Multihash проверяем до сохранения, чтобы не сохранять хеш, который нельзя вернуть
+	if req.GetMultihash() {
+		if _, err := multihashCode(algorithm.Name, algorithm.key); err != nil {
+			return nil, err
+		}
+	}
+
+	// Срок жизни записи: из запроса или по политике хранения сервиса
+	now := time.Now()
+	expiresAt, err := s.expiresAt(req.GetTtl(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Здесь вычисляется хеш от payload (после нормализации текста и в режиме canonical_json - от канонического
+	// JSON, они же и сохраняются) и преобразуется в строку шестнадцатеричных символов
+	payload, err := hashedPayload(payloadBytes(req), normalization, req.GetCanonicalJson())
+	if err != nil {
+		return nil, err
+	}
+	hashString := algorithm.Sum(payload)
+
+	// Квоты пространства имен запроса
+	namespace, err := s.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayloadQuota(namespace, int64(len(payload))); err != nil {
+		return nil, err
+	}
+	if err := s.checkHashesQuota(ctx, namespace, hashString); err != nil {
+		return nil, err
+	}
+
+	// Здесь хеш hashString, соответствующий ему payload и имя алгоритма сохраняются в хранилище,
+	// если такого хеша еще нет
+	record := &storage.Record{
+		Payload:       payload,
+		ContentType:   req.GetContentType(),
+		Algorithm:     algorithm.Name,
+		MACKey:        algorithm.key,
+		MACKeyVersion: algorithm.keyVersion,
+		Normalization: normalization.String(),
+		CreatedAt:     now,
+		Size:          int64(len(payload)),
+		ExpiresAt:     expiresAt,
+	}
+	// Отпечаток SimHash для поиска похожих данных (см. hashing-similar.go)
+	if req.GetSimhash() {
+		record.SimHash = simHash(payload)
+	}
+	created, err := s.createRecord(ctx, hashString, record, req.GetOwner())
+	if err != nil {
+		return nil, err
+	}
 
-	// Здесь хеш hashString и соответствующий ему payload сохраняются в базе данных Redis
-	err := s.redisClient.Set(ctx, hashString, req.Payload, 0).Err()
+	formatted, err := formatHash(req.GetEncoding(), req.GetMultihash(), algorithm.Name, algorithm.key, hashString)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to save hash: %v", err)
+		return nil, err
 	}
 
-	// Если хеш успешно сохранен, функция возвращает ответ с хешем и nil в качестве ошибки
-	return &pb.HashResponse{Hash: hashString}, nil
+	// Если хеш успешно сохранен или уже был, функция возвращает ответ с хешем и nil в качестве ошибки
+	return &pb.HashResponse{
+		Hash:          formatted,
+		Algorithm:     algorithm.Name,
+		Created:       created,
+		Key:           algorithm.key,
+		KeyVersion:    algorithm.keyVersion,
+		Normalization: normalization.message(),
+	}, nil
 }
@@ -0,0 +1,155 @@
+package hashing
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Поиск похожих данных (near-duplicates) по отпечаткам SimHash. Точный хеш полностью меняется от правки
+одного символа, а отпечаток SimHash похожих данных отличается лишь в нескольких битах. CreateHash с simhash
+считает 64-битный отпечаток сохраняемых данных и добавляет его в индекс хранилища (storage/simhash.go),
+а FindSimilar возвращает сохраненные хеши, отпечатки которых отличаются от отпечатка данных запроса не больше
+чем на max_distance бит (расстояние Хэмминга).
+
+Признаки текста в UTF-8 - слова в нижнем регистре с учетом повторов: так правка нескольких слов меняет
+лишь малую часть признаков. Для бинарных данных и текста без слов признаками служат последовательности
+из simHashShingle байт.
+*/
+
+// Расстояние FindSimilar по умолчанию и число найденных хешей по умолчанию и максимальное
+const (
+	DefaultSimilarDistance = 3
+	DefaultSimilarLimit    = 10
+	MaxSimilarLimit        = 100
+)
+
+// simHashShingle - длина последовательности байт, которая служит признаком бинарных данных.
+const simHashShingle = 4
+
+// simHash возвращает отпечаток SimHash данных. У пустых данных признаков нет, и отпечаток нулевой.
+func simHash(data []byte) uint64 {
+	var features [][]byte
+	if utf8.Valid(data) {
+		for _, word := range strings.FieldsFunc(string(data), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}) {
+			features = append(features, []byte(strings.ToLower(word)))
+		}
+	}
+	if len(features) == 0 && len(data) > 0 {
+		// Данные короче simHashShingle целиком служат одним признаком
+		for start := 0; start == 0 || start+simHashShingle <= len(data); start++ {
+			features = append(features, data[start:min(start+simHashShingle, len(data))])
+		}
+	}
+	if len(features) == 0 {
+		return 0
+	}
+
+	// Каждый бит отпечатка - знак суммы соответствующих битов хешей признаков
+	var weights [64]int
+	for _, feature := range features {
+		h := fnv.New64a()
+		h.Write(feature)
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// formatSimHash возвращает отпечаток в hex; пустую строку, если отпечатка нет.
+func formatSimHash(fingerprint uint64) string {
+	if fingerprint == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x", fingerprint)
+}
+
+func (s *HashingService) FindSimilar(ctx context.Context, req *pb.FindSimilarRequest) (*pb.FindSimilarResponse, error) {
+	maxDistance := int(req.GetMaxDistance())
+	switch {
+	case maxDistance < 0 || maxDistance > storage.MaxSimilarDistance:
+		return nil, status.Errorf(codes.InvalidArgument, "max_distance must be between 0 and %d, 0 selects the default of %d",
+			storage.MaxSimilarDistance, DefaultSimilarDistance)
+	case maxDistance == 0:
+		maxDistance = DefaultSimilarDistance
+	}
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = DefaultSimilarLimit
+	case limit > MaxSimilarLimit:
+		limit = MaxSimilarLimit
+	}
+
+	if err := validateEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+	normalization, err := parseNormalization(req.GetNormalization())
+	if err != nil {
+		return nil, err
+	}
+
+	// Отпечаток считается от тех же данных, что сохраняет CreateHash с той же нормализацией и canonical_json
+	data := req.GetData()
+	if len(data) == 0 {
+		data = []byte(req.GetPayload())
+	}
+	payload, err := hashedPayload(data, normalization, req.GetCanonicalJson())
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "payload must not be empty")
+	}
+	fingerprint := simHash(payload)
+
+	entries, err := s.records(ctx).FindSimilar(ctx, "", fingerprint, maxDistance)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to find similar hashes: %v", err)
+	}
+	slices.SortFunc(entries, func(a, b storage.SimilarEntry) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), strings.Compare(a.Hash, b.Hash))
+	})
+
+	resp := &pb.FindSimilarResponse{Fingerprint: fmt.Sprintf("%016x", fingerprint)}
+	for _, entry := range entries[:min(limit, len(entries))] {
+		resp.Matches = append(resp.Matches, &pb.SimilarHash{
+			Hash:       encodeHash(req.GetEncoding(), entry.Hash),
+			Algorithm:  entry.Record.Algorithm,
+			Distance:   int32(entry.Distance),
+			Key:        entry.Record.MACKey,
+			KeyVersion: entry.Record.MACKeyVersion,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,149 @@
+package hashing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Метод CreateHashStream принимает данные частями (client-streaming) и считает хеш по мере получения,
+не собирая весь payload в памяти. Алгоритм, ключ, тип содержимого, TTL, владелец, кодировка и формат хеша
+в ответе берутся из первой части. Исходные данные сохраняются в хранилище, только если их размер не превышает
+maxStoredPayloadSize; для больших данных сохраняется запись о хеше с размером, но без самих данных.
+Канонический JSON (canonical_json), нормализация текста и отпечаток SimHash (simhash) считаются по всему документу,
+поэтому в этих режимах данные копятся в памяти и должны уложиться в maxStoredPayloadSize.
+*/
+
+func (s *HashingService) CreateHashStream(stream pb.Hashing_CreateHashStreamServer) error {
+	var (
+		algorithm keyedHasher
+		payload   []byte
+		size      int64
+	)
+
+	// Первая часть определяет алгоритм и ключ; пустой поток - это хеш пустых данных алгоритмом по умолчанию
+	chunk, recvErr := stream.Recv()
+	if recvErr != nil && !errors.Is(recvErr, io.EOF) {
+		return recvErr
+	}
+	encoding, multihash, canonical := chunk.GetEncoding(), chunk.GetMultihash(), chunk.GetCanonicalJson()
+	if err := validateEncoding(encoding); err != nil {
+		return err
+	}
+	algorithm, lookupErr := s.hasher(stream.Context(), chunk.GetAlgorithm(), chunk.GetKey(), chunk.GetKeyVersion())
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if multihash {
+		if _, err := multihashCode(algorithm.Name, algorithm.key); err != nil {
+			return err
+		}
+	}
+	normalization, err := parseNormalization(chunk.GetNormalization())
+	if err != nil {
+		return err
+	}
+	fingerprint := chunk.GetSimhash()
+	buffered := canonical || fingerprint || normalization != textNormalization{}
+	h := algorithm.New()
+	contentType := chunk.GetContentType()
+	ttl := chunk.GetTtl()
+	owner := chunk.GetOwner()
+	if err := validateOwner(owner); err != nil {
+		return err
+	}
+
+	namespace, err := s.namespace(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	for recvErr == nil {
+		data := chunk.GetData()
+		if !buffered {
+			h.Write(data)
+		}
+		size += int64(len(data))
+
+		// Квоту на размер проверяем сразу, не дожидаясь конца потока
+		if err := checkPayloadQuota(namespace, size); err != nil {
+			return err
+		}
+
+		// Копим данные для хранилища, пока они укладываются в лимит
+		if size <= s.maxStoredPayloadSize {
+			payload = append(payload, data...)
+		} else if buffered {
+			return status.Errorf(codes.InvalidArgument, "payload of more than %d bytes is too large for canonical JSON, text normalization or simhash", s.maxStoredPayloadSize)
+		} else {
+			payload = nil
+		}
+
+		chunk, recvErr = stream.Recv()
+	}
+	if !errors.Is(recvErr, io.EOF) {
+		return recvErr
+	}
+
+	// Канонический JSON, нормализация и отпечаток считаются по всему документу, поэтому в этих режимах хеш считается в конце
+	if buffered {
+		if payload, err = hashedPayload(payload, normalization, canonical); err != nil {
+			return err
+		}
+		h.Write(payload)
+		size = int64(len(payload))
+	}
+
+	hashString := fmt.Sprintf("%x", h.Sum(nil))
+	if err := s.checkHashesQuota(stream.Context(), namespace, hashString); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt, err := s.expiresAt(ttl, now)
+	if err != nil {
+		return err
+	}
+
+	record := &storage.Record{
+		Payload:       payload,
+		ContentType:   contentType,
+		Algorithm:     algorithm.Name,
+		MACKey:        algorithm.key,
+		MACKeyVersion: algorithm.keyVersion,
+		Normalization: normalization.String(),
+		CreatedAt:     now,
+		Size:          size,
+		ExpiresAt:     expiresAt,
+	}
+	// Отпечаток SimHash для поиска похожих данных (см. hashing-similar.go)
+	if fingerprint {
+		record.SimHash = simHash(payload)
+	}
+	created, err := s.createRecord(stream.Context(), hashString, record, owner)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := formatHash(encoding, multihash, algorithm.Name, algorithm.key, hashString)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(&pb.HashResponse{
+		Hash:          formatted,
+		Algorithm:     algorithm.Name,
+		Created:       created,
+		Key:           algorithm.key,
+		KeyVersion:    algorithm.keyVersion,
+		Normalization: normalization.message(),
+	})
+}
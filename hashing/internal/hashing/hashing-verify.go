@@ -0,0 +1,94 @@
+package hashing
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	pb "final-project-kodzimo-shared/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Метод VerifyHash пересчитывает хеш данных и сравнивает его с ожидаемым за постоянное время, чтобы по времени
+ответа нельзя было подобрать хеш побайтно. Хранилище не используется: клиенту не нужно сначала получать хеш,
+а потом сравнивать его самому. Ожидаемый хеш принимается в любой из кодировок hashing-encoding.go, в том числе
+в самоописывающем формате "sha256:<hex>" или в формате multihash (см. hashing-multihash.go) - тогда алгоритм
+берется из хеша и возвращается в ответе.
+*/
+
+// splitSelfDescribingHash делит хеш вида "sha256:<hex>" на имя алгоритма и сам хеш. Хеш без префикса
+// возвращается с пустым именем алгоритма.
+func splitSelfDescribingHash(value string) (string, string) {
+	algorithm, hash, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", value
+	}
+	return algorithm, hash
+}
+
+func (s *HashingService) VerifyHash(ctx context.Context, req *pb.VerifyHashRequest) (*pb.VerifyHashResponse, error) {
+	algorithmName, expected := splitSelfDescribingHash(req.GetExpectedHash())
+	if algorithmName == "" {
+		algorithmName = req.GetAlgorithm()
+	} else if req.GetAlgorithm() != "" && normalizeAlgorithmName(req.GetAlgorithm()) != normalizeAlgorithmName(algorithmName) {
+		return nil, status.Errorf(codes.InvalidArgument, "algorithm %q does not match the prefix of expected hash %q", req.GetAlgorithm(), algorithmName)
+	}
+
+	// Multihash сам описывает алгоритм: он должен совпадать с указанным в запросе или в префиксе
+	multihashAlgorithm, multihashDigest, isMultihash := s.decodeMultihash(expected)
+	if isMultihash {
+		if algorithmName != "" && normalizeAlgorithmName(algorithmName) != multihashAlgorithm {
+			return nil, status.Errorf(codes.InvalidArgument, "algorithm %q does not match the multihash algorithm %q", algorithmName, multihashAlgorithm)
+		}
+		if _, err := multihashCode(multihashAlgorithm, req.GetKey()); err != nil {
+			return nil, err
+		}
+		algorithmName = multihashAlgorithm
+	}
+
+	algorithm, err := s.hasher(ctx, algorithmName, req.GetKey(), req.GetKeyVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	data := req.GetData()
+	if len(data) == 0 {
+		data = []byte(req.GetPayload())
+	}
+	normalization, err := parseNormalization(req.GetNormalization())
+	if err != nil {
+		return nil, err
+	}
+	data, err = hashedPayload(data, normalization, req.GetCanonicalJson())
+	if err != nil {
+		return nil, err
+	}
+	digest := algorithm.New()
+	digest.Write(data)
+	sum := digest.Sum(nil)
+
+	// Ожидаемый хеш может подходить сразу под несколько кодировок, поэтому сравниваются все варианты
+	// и без досрочного выхода
+	expectedDigests := [][]byte{multihashDigest}
+	if !isMultihash {
+		expectedDigests, err = decodeDigest(expected, len(sum))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v, optionally prefixed with the algorithm: sha256:<hash>", err)
+		}
+	}
+	match := 0
+	for _, expectedDigest := range expectedDigests {
+		// Длина хеша не секрет, поэтому ConstantTimeCompare может сразу вернуть 0 при разной длине
+		match |= subtle.ConstantTimeCompare(sum, expectedDigest)
+	}
+
+	return &pb.VerifyHashResponse{
+		Match:      match == 1,
+		Algorithm:  algorithm.Name,
+		Key:        algorithm.key,
+		KeyVersion: algorithm.keyVersion,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что перехватчик пропускает административные методы только с верным служебным
+токеном, обычные методы - без токена, а без настроенного токена административные методы недоступны.
+*/
+func TestAdminUnaryInterceptor(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	withToken := func(value string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadataKey, value))
+	}
+	interceptor := AdminUnaryInterceptor("secret")
+
+	tests := []struct {
+		ctx        context.Context
+		method     string
+		wantCode   codes.Code
+		wantCalled bool
+	}{
+		{context.Background(), "/proto.Hashing/CreateHash", codes.OK, true},
+		{withToken("Bearer wrong"), "/proto.Hashing/GetHash", codes.OK, true},
+		{context.Background(), "/proto.Hashing/DeleteHash", codes.Unauthenticated, false},
+		{context.Background(), "/proto.Hashing/ListHashes", codes.Unauthenticated, false},
+		{context.Background(), "/proto.HashingAdmin/CreateKey", codes.Unauthenticated, false},
+		{withToken("Bearer wrong"), "/proto.HashingAdmin/CreateNamespace", codes.PermissionDenied, false},
+		{withToken("secret"), "/proto.HashingAdmin/CreateNamespace", codes.PermissionDenied, false},
+		{withToken("Bearer secret"), "/proto.HashingAdmin/CreateNamespace", codes.OK, true},
+		{withToken("Bearer secret"), "/proto.Hashing/DeleteHash", codes.OK, true},
+	}
+	for _, tt := range tests {
+		called = false
+		_, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		assert.Equal(t, tt.wantCode, status.Code(err), tt.method)
+		assert.Equal(t, tt.wantCalled, called, tt.method)
+	}
+
+	// Без настроенного токена административные методы выключены
+	_, err := AdminUnaryInterceptor("")(withToken("Bearer "), nil, &grpc.UnaryServerInfo{FullMethod: "/proto.Hashing/DeleteHash"}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = AdminUnaryInterceptor("")(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/proto.Hashing/CheckHash"}, handler)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+/*
+Этот тест проверяет, что повторные GetHash одного хеша читают данные из кеша, а не из хранилища,
+но учитываются в статистике чтений, а удаление хеша убирает его из кеша.
+*/
+func TestGetHashCache(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{Store: storage.NewMemoryStore()}
+	service := NewHashingService(store, WithPayloadCache(100, 1<<20))
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte("test"), ContentType: "text/plain"})
+	assert.NoError(t, err)
+
+	// Первое чтение - промах и обращение к хранилищу, следующие - из кеша
+	gets := store.gets.Load()
+	for i := 0; i < 5; i++ {
+		resp, err := service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("test"), resp.GetPayload())
+		assert.Equal(t, "text/plain", resp.GetContentType())
+		assert.Equal(t, created.GetHash(), resp.GetHash())
+	}
+	assert.Equal(t, gets+1, store.gets.Load())
+
+	// Чтения из кеша учитываются в статистике записи сразу
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), meta.GetReadCount())
+
+	stats, ok := service.CacheStats()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+
+	// Другой алгоритм в запросе не совпадает с кешированной записью
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash(), Algorithm: "sha512"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// После удаления хеш не возвращается из кеша
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stats, _ = service.CacheStats()
+	assert.Equal(t, 0, stats.Entries)
+}
+
+/*
+Этот тест проверяет, что кеш не превышает ограничения по числу записей и по размеру, вытесняя
+давно не читавшиеся записи, а запись с истекшим сроком жизни из кеша не возвращается.
+*/
+func TestPayloadCacheLimits(t *testing.T) {
+	now := time.Now()
+	record := func(payload string) *storage.Record {
+		return &storage.Record{Payload: []byte(payload), Algorithm: "sha256"}
+	}
+
+	// Ограничение по числу записей: "a" читалась недавно, поэтому вытесняется "b"
+	cache := NewPayloadCache(2, 0)
+	cache.Add("a", record("1"), cache.Generation())
+	cache.Add("b", record("2"), cache.Generation())
+	_, ok := cache.Get("a", now)
+	assert.True(t, ok)
+	cache.Add("c", record("3"), cache.Generation())
+
+	_, ok = cache.Get("b", now)
+	assert.False(t, ok)
+	_, ok = cache.Get("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+
+	// Ограничение по размеру: размер записи - ключ, данные и алгоритм
+	cache = NewPayloadCache(0, 30)
+	cache.Add("a", record("0123456789"), cache.Generation())
+	cache.Add("b", record("0123456789"), cache.Generation())
+	assert.Equal(t, 1, cache.Stats().Entries)
+	assert.LessOrEqual(t, cache.Stats().Bytes, int64(30))
+	_, ok = cache.Get("b", now)
+	assert.True(t, ok)
+
+	// Запись больше ограничения не кешируется
+	cache.Add("big", record(string(make([]byte, 100))), cache.Generation())
+	_, ok = cache.Get("big", now)
+	assert.False(t, ok)
+
+	// Запись, прочитанная до удаления, не попадает в кеш
+	generation := cache.Generation()
+	cache.Remove("stale")
+	cache.Add("stale", record("1"), generation)
+	_, ok = cache.Get("stale", now)
+	assert.False(t, ok)
+
+	// Истекшая запись
+	expiring := record("1")
+	expiring.ExpiresAt = now.Add(time.Minute)
+	cache.Add("expiring", expiring, cache.Generation())
+	_, ok = cache.Get("expiring", now.Add(2*time.Minute))
+	assert.False(t, ok)
+}
+
+/*
+Этот тест проверяет, что после TouchHash кеш не отдает устаревший срок жизни: запись, срок которой
+сокращен, перестает читаться после его истечения.
+*/
+func TestGetHashCacheTouch(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithPayloadCache(100, 0))
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.NoError(t, err)
+
+	_, err = service.TouchHash(ctx, &pb.TouchHashRequest{Hash: created.GetHash(), Ttl: durationpb.New(time.Millisecond)})
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,101 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Пример из раздела 3.2.2 RFC 8785 и его каноническая форма
+const (
+	testJSON          = `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001], "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/", "literals": [null, true, false]}`
+	testCanonicalJSON = `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+)
+
+/*
+Этот тест проверяет, что в режиме canonical_json одинаковые по смыслу документы получают один хеш,
+сохраняется каноническая форма, а CheckHash, VerifyHash и CreateHashStream считают хеш так же.
+*/
+func TestCanonicalJSON(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testJSON, CanonicalJson: true})
+	assert.NoError(t, err)
+	plain, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testCanonicalJSON})
+	assert.NoError(t, err)
+	assert.Equal(t, plain.GetHash(), created.GetHash())
+	assert.False(t, plain.GetCreated())
+
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, testCanonicalJSON, string(got.GetPayload()))
+
+	reordered := `{ "b": [1.0, 2], "a": {"y": "A", "x": null} }`
+	first, err := service.CheckHash(ctx, &pb.HashRequest{Payload: reordered, CanonicalJson: true})
+	assert.NoError(t, err)
+	second, err := service.CheckHash(ctx, &pb.HashRequest{Data: []byte(`{"a":{"x":null,"y":"A"},"b":[1,2]}`), CanonicalJson: true})
+	assert.NoError(t, err)
+	assert.Equal(t, first.GetHash(), second.GetHash())
+
+	verified, err := service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: reordered, ExpectedHash: first.GetHash(), CanonicalJson: true})
+	assert.NoError(t, err)
+	assert.True(t, verified.GetMatch())
+
+	stream := &chunkStream{chunk: []byte(testJSON), count: 1, canonicalJSON: true}
+	assert.NoError(t, service.CreateHashStream(stream))
+	assert.Equal(t, created.GetHash(), stream.resp.GetHash())
+}
+
+/*
+Этот тест проверяет, что некорректный JSON отклоняется с InvalidArgument и ErrorInfo с причиной
+INVALID_JSON, а позиция синтаксической ошибки передается в деталях.
+*/
+func TestCanonicalJSONErrors(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store, WithMaxStoredPayloadSize(8))
+
+	tests := []struct {
+		name    string
+		payload string
+		offset  string
+	}{
+		{"syntax", `{"a": 1,}`, "9"},
+		{"empty", ``, "0"},
+		{"truncated", `{"a": [1, 2`, "11"},
+		{"trailing data", `{"a": 1} {}`, "8"},
+		{"duplicate key", `{"a": 1, "a": 2}`, ""},
+		{"number out of range", `[1e400]`, ""},
+		{"invalid utf-8", "\"\xff\"", ""},
+	}
+	for _, tt := range tests {
+		_, err := service.CreateHash(ctx, &pb.HashRequest{Payload: tt.payload, CanonicalJson: true})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), tt.name)
+
+		var info *errdetails.ErrorInfo
+		for _, detail := range status.Convert(err).Details() {
+			info, _ = detail.(*errdetails.ErrorInfo)
+		}
+		if assert.NotNil(t, info, tt.name) {
+			assert.Equal(t, ReasonInvalidJSON, info.GetReason(), tt.name)
+			assert.NotEmpty(t, info.GetMetadata()["error"], tt.name)
+			assert.Equal(t, tt.offset, info.GetMetadata()["offset"], tt.name)
+		}
+	}
+
+	// В потоке канонический JSON строится в памяти, поэтому данные сверх лимита хранения отклоняются
+	err := service.CreateHashStream(&chunkStream{chunk: []byte(`[1,2,3]`), count: 2, canonicalJSON: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	page, err := store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
@@ -0,0 +1,105 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Хеш SHA-256 строки "test" в каждой из кодировок
+var testSHA256Encoded = map[string]string{
+	EncodingHex:       testSHA256,
+	EncodingBase64URL: "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg",
+	EncodingBase32:    "t6dnbamijr6wlgrp5kqmkwwqcwr36ty3fmfyelgrlvwblmhqbiea",
+	EncodingBase58:    "Bjj4AWTNrjQVHqgWbP2XaxXz4DYH1WZMyERHxsad7b2w",
+	EncodingMultibase: "bt6dnbamijr6wlgrp5kqmkwwqcwr36ty3fmfyelgrlvwblmhqbiea",
+}
+
+/*
+Этот тест проверяет, что CreateHash, CheckHash, GetHash и GetHashMetadata возвращают хеш в запрошенной
+кодировке, хранилище всегда содержит хеш в hex, а поиск принимает хеш в любой кодировке.
+*/
+func TestHashEncodings(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	for encoding, encoded := range testSHA256Encoded {
+		created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Encoding: encoding})
+		assert.NoError(t, err, encoding)
+		assert.Equal(t, encoded, created.GetHash(), encoding)
+
+		checked, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "test", Encoding: encoding})
+		assert.NoError(t, err, encoding)
+		assert.True(t, checked.GetExists(), encoding)
+		assert.Equal(t, encoded, checked.GetHash(), encoding)
+
+		got, err := service.GetHash(ctx, &pb.HashRequest{Payload: encoded})
+		assert.NoError(t, err, encoding)
+		assert.Equal(t, testSHA256, got.GetHash(), encoding)
+		assert.Equal(t, "test", string(got.GetPayload()), encoding)
+
+		meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: testSHA256, Encoding: encoding})
+		assert.NoError(t, err, encoding)
+		assert.Equal(t, encoded, meta.GetHash(), encoding)
+	}
+
+	// Кодировка не влияет на ключ в хранилище
+	page, err := store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, testSHA256, page.Entries[0].Hash)
+	}
+
+	// Поиск принимает и другие кодировки multibase, например base58btc с префиксом "z"
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: "z" + testSHA256Encoded[EncodingBase58], Encoding: EncodingBase64URL})
+	assert.NoError(t, err)
+	assert.Equal(t, testSHA256Encoded[EncodingBase64URL], got.GetHash())
+}
+
+/*
+Этот тест проверяет, что VerifyHash принимает ожидаемый хеш в любой кодировке, в том числе с префиксом алгоритма.
+*/
+func TestVerifyHashEncodings(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	for encoding, encoded := range testSHA256Encoded {
+		resp, err := service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "test", ExpectedHash: "sha256:" + encoded})
+		assert.NoError(t, err, encoding)
+		assert.True(t, resp.GetMatch(), encoding)
+
+		resp, err = service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "other", ExpectedHash: encoded})
+		assert.NoError(t, err, encoding)
+		assert.False(t, resp.GetMatch(), encoding)
+	}
+}
+
+/*
+Этот тест проверяет, что неизвестная кодировка возвращает InvalidArgument, а хеш, который не подходит
+ни под одну кодировку, не находится.
+*/
+func TestHashEncodingErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Encoding: "base16"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "test", Encoding: "base16"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: testSHA256, Encoding: "base16"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: "not a hash!"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: testSHA256Encoded[EncodingBase64URL][:20]})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
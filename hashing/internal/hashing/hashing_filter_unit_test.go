@@ -0,0 +1,128 @@
+package hashing
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore считает обращения Get к хранилищу.
+type countingStore struct {
+	storage.Store
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, hash string) (*storage.Record, error) {
+	s.gets.Add(1)
+	return s.Store.Get(ctx, hash)
+}
+
+/*
+Этот тест проверяет масштабируемый фильтр: после заполнения первой ступени добавляются новые,
+все добавленные ключи находятся, а доля ложных срабатываний остается в пределах заданной.
+*/
+func TestHashFilterScales(t *testing.T) {
+	const keys = 10000
+
+	filter := NewHashFilter(1000, 0.01)
+	assert.NoError(t, filter.Rebuild(func(add func(key string)) error { return nil }))
+
+	for i := 0; i < keys; i++ {
+		filter.Add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < keys; i++ {
+		assert.True(t, filter.MayContain(fmt.Sprintf("key-%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < keys; i++ {
+		if filter.MayContain(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/keys, 0.02)
+
+	stats := filter.Stats()
+	// Ключ, на который фильтр уже срабатывает, повторно не добавляется, поэтому счетчик приблизительный
+	assert.InDelta(t, keys, stats.Items, keys*0.02)
+	assert.Greater(t, stats.Stages, 1)
+	assert.Greater(t, stats.SizeBytes, uint(0))
+	assert.Less(t, stats.EstimatedFalsePositiveRate, 0.02)
+}
+
+/*
+Этот тест проверяет, что CheckHash с фильтром отвечает на запросы о неизвестных данных без обращения
+к хранилищу, находит записи, созданные до запуска (после RebuildFilter) и после него, учитывает
+пространство имен, а удаленные записи учитывает в метриках.
+*/
+func TestCheckHashFilter(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{Store: storage.NewMemoryStore()}
+
+	// Запись, сохраненная предыдущим запуском сервиса
+	_, err := NewHashingService(store).CreateHash(ctx, &pb.HashRequest{Payload: "before"})
+	assert.NoError(t, err)
+
+	service := NewHashingService(store, WithHashFilter(DefaultFilterCapacity, DefaultFilterFalsePositiveRate))
+
+	// Пока фильтр не построен, запросы идут в хранилище
+	gets := store.gets.Load()
+	resp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "unknown"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetExists())
+	assert.Equal(t, gets+1, store.gets.Load())
+
+	assert.NoError(t, service.RebuildFilter(ctx))
+
+	// Неизвестные данные - без обращения к хранилищу
+	gets = store.gets.Load()
+	for i := 0; i < 100; i++ {
+		resp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: fmt.Sprintf("unknown-%d", i)})
+		assert.NoError(t, err)
+		assert.False(t, resp.GetExists())
+	}
+	assert.Less(t, store.gets.Load()-gets, int64(5))
+
+	resp, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "before"})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetExists())
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "after"})
+	assert.NoError(t, err)
+	resp, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "after"})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetExists())
+
+	// Тот же хеш в другом пространстве имен не существует
+	_, err = service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "team-a"})
+	assert.NoError(t, err)
+	resp, err = service.CheckHash(WithNamespace(ctx, "team-a"), &pb.HashRequest{Payload: "after"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetExists())
+
+	// Удаленный хеш остается в фильтре, но хранилище его не находит
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	resp, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "after"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetExists())
+
+	stats, ok := service.FilterStats()
+	assert.True(t, ok)
+	assert.True(t, stats.Ready)
+	assert.Equal(t, uint(1), stats.Removed)
+	assert.GreaterOrEqual(t, stats.FalsePositives, uint64(1))
+	assert.Greater(t, stats.Negatives, uint64(90))
+
+	// После перестройки удаленные ключи из фильтра исчезают
+	assert.NoError(t, service.RebuildFilter(ctx))
+	stats, _ = service.FilterStats()
+	assert.Equal(t, uint(0), stats.Removed)
+	assert.Equal(t, uint(1), stats.Items)
+}
@@ -0,0 +1,140 @@
+package hashing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет хеширование с ключом: хеш считается HMAC текущей версией ключа, после смены ключа
+новые хеши считаются новой версией, а хеши старой версии по-прежнему проверяются с key_version.
+Хеш с ключом не совпадает с хешем тех же данных без ключа. Надгробие хеша сохраняет ключ, поэтому
+CheckHash с ключом сообщает, что хеш удален.
+*/
+func TestKeyedHash(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	key, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), key.GetCurrentVersion())
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "alice@example.com", Key: "emails"})
+	assert.NoError(t, err)
+	assert.Equal(t, "emails", created.GetKey())
+	assert.Equal(t, int64(1), created.GetKeyVersion())
+
+	stored, err := store.GetMACKey(ctx, "emails")
+	assert.NoError(t, err)
+	mac := hmac.New(sha256.New, stored.Versions[0].Secret)
+	mac.Write([]byte("alice@example.com"))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), created.GetHash())
+
+	// Без ключа тот же payload дает другой хеш, которого нет в хранилище
+	check, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "alice@example.com"})
+	assert.NoError(t, err)
+	assert.False(t, check.GetExists())
+
+	rotated, err := service.RotateKey(ctx, &pb.RotateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rotated.GetCurrentVersion())
+	assert.Len(t, rotated.GetVersions(), 2)
+
+	check, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "alice@example.com", Key: "emails"})
+	assert.NoError(t, err)
+	assert.False(t, check.GetExists())
+	assert.Equal(t, int64(2), check.GetKeyVersion())
+	assert.NotEqual(t, created.GetHash(), check.GetHash())
+
+	check, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "alice@example.com", Key: "emails", KeyVersion: 1})
+	assert.NoError(t, err)
+	assert.True(t, check.GetExists())
+	assert.Equal(t, created.GetHash(), check.GetHash())
+
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, "emails", got.GetKey())
+	assert.Equal(t, int64(1), got.GetKeyVersion())
+
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, "emails", meta.GetKey())
+	assert.Equal(t, int64(1), meta.GetKeyVersion())
+
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: created.GetHash(), Tombstone: true})
+	assert.NoError(t, err)
+	tombstone, err := store.Get(ctx, created.GetHash())
+	assert.NoError(t, err)
+	assert.Equal(t, "emails", tombstone.MACKey)
+	assert.Equal(t, int64(1), tombstone.MACKeyVersion)
+
+	check, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "alice@example.com", Key: "emails", KeyVersion: 1})
+	assert.NoError(t, err)
+	assert.False(t, check.GetExists())
+	assert.True(t, check.GetDeleted())
+}
+
+/*
+Этот тест проверяет, что BLAKE2b с ключом считается встроенным режимом MAC, а не через HMAC.
+*/
+func TestKeyedHashBlake2b(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	_, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "phones"})
+	assert.NoError(t, err)
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "+15550100", Algorithm: "blake2b-256", Key: "phones"})
+	assert.NoError(t, err)
+
+	stored, err := store.GetMACKey(ctx, "phones")
+	assert.NoError(t, err)
+	mac, err := blake2b.New256(stored.Versions[0].Secret)
+	assert.NoError(t, err)
+	mac.Write([]byte("+15550100"))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), created.GetHash())
+}
+
+/*
+Этот тест проверяет ошибки ключей: неизвестный ключ и версия, версия без ключа, повторное создание
+и недопустимое имя. Секреты ключей не возвращаются.
+*/
+func TestKeyErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	_, err = service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "Bad Name"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Key: "phones"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "test", Key: "emails", KeyVersion: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "test", KeyVersion: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.RotateKey(ctx, &pb.RotateKeyRequest{Name: "phones"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	keys, err := service.ListKeys(ctx, &pb.ListKeysRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, keys.GetKeys(), 1) {
+		assert.Equal(t, "emails", keys.GetKeys()[0].GetName())
+		assert.Len(t, keys.GetKeys()[0].GetVersions(), 1)
+	}
+}
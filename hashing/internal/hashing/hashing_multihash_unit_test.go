@@ -0,0 +1,140 @@
+package hashing
+
+import (
+	"context"
+	"crypto/md5"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Multihash SHA-256 строки "test": в hex, в base58 (как CIDv0 IPFS) и в multibase
+const (
+	testMultihash          = "1220" + testSHA256
+	testMultihashBase58    = "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w"
+	testMultihashMultibase = "bciqj7bwqqgeey7lftix6vigfllibli57j4nswc4cftiv23avwdyauca"
+)
+
+/*
+Этот тест проверяет, что хеш возвращается в формате multihash в выбранной кодировке, хранится
+без multihash, а поиск принимает multihash в любой кодировке.
+*/
+func TestMultihash(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Multihash: true})
+	assert.NoError(t, err)
+	assert.Equal(t, testMultihash, created.GetHash())
+
+	checked, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "test", Multihash: true, Encoding: EncodingBase58})
+	assert.NoError(t, err)
+	assert.True(t, checked.GetExists())
+	assert.Equal(t, testMultihashBase58, checked.GetHash())
+
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: testSHA256, Multihash: true, Encoding: EncodingMultibase})
+	assert.NoError(t, err)
+	assert.Equal(t, testMultihashMultibase, meta.GetHash())
+
+	for _, hash := range []string{testMultihash, testMultihashBase58, testMultihashMultibase} {
+		got, err := service.GetHash(ctx, &pb.HashRequest{Payload: hash})
+		assert.NoError(t, err, hash)
+		assert.Equal(t, testSHA256, got.GetHash(), hash)
+		assert.Equal(t, "test", string(got.GetPayload()), hash)
+	}
+
+	page, err := store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, testSHA256, page.Entries[0].Hash)
+	}
+}
+
+/*
+Этот тест проверяет, что поиск по multihash учитывает алгоритм: хеш, сохраненный другим алгоритмом
+или с ключом, по multihash с тем же значением хеша не находится.
+*/
+func TestMultihashAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	keyed, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Key: "emails"})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: "1220" + keyed.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Algorithm: "sha3-256"})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: "1220" + created.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: "1620" + created.GetHash(), Multihash: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "1620"+created.GetHash(), got.GetHash())
+
+	// BLAKE2b: код алгоритма занимает в varint несколько байт
+	created, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Algorithm: "blake2b-256", Multihash: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "a0e40220", created.GetHash()[:8])
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, "blake2b-256", meta.GetAlgorithm())
+}
+
+/*
+Этот тест проверяет, что VerifyHash берет алгоритм из multihash и отвергает multihash, который
+противоречит алгоритму запроса или используется с ключом.
+*/
+func TestVerifyHashMultihash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	resp, err := service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "test", ExpectedHash: testMultihashBase58, Algorithm: "sha256"})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetMatch())
+
+	resp, err = service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "test", ExpectedHash: "1114" + testSHA1})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetMatch())
+	assert.Equal(t, "sha1", resp.GetAlgorithm())
+
+	_, err = service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	requests := []*pb.VerifyHashRequest{
+		{Payload: "test", ExpectedHash: testMultihash, Algorithm: "sha512"},
+		{Payload: "test", ExpectedHash: "sha1:" + testMultihash},
+		{Payload: "test", ExpectedHash: testMultihash, Key: "emails"},
+	}
+	for _, req := range requests {
+		_, err := service.VerifyHash(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+}
+
+/*
+Этот тест проверяет, что multihash нельзя запросить для хеша с ключом и для алгоритма без кода multicodec.
+*/
+func TestMultihashErrors(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+	service.Algorithms().Register("md5", md5.New)
+
+	_, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Key: "emails", Multihash: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "test", Algorithm: "md5", Multihash: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	page, err := store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
@@ -0,0 +1,186 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что один и тот же payload в разных пространствах имен хранится независимо:
+хеш из одного пространства не виден в другом, а списки содержат только свои хеши.
+*/
+func TestNamespaceIsolation(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "team-a"})
+	assert.NoError(t, err)
+	teamA := WithNamespace(ctx, "team-a")
+
+	createResp, err := service.CreateHash(teamA, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+
+	_, err = service.GetHash(teamA, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	checkResp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
+
+	listResp, err := service.ListHashes(teamA, &pb.ListHashesRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, listResp.GetHashes(), 1)
+	listResp, err = service.ListHashes(ctx, &pb.ListHashesRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, listResp.GetHashes())
+
+	// Писать можно только в созданное пространство
+	_, err = service.CreateHash(WithNamespace(ctx, "team-b"), &pb.HashRequest{Payload: "secret"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+/*
+Этот тест проверяет квоты пространства имен на число хешей и размер данных, в том числе при потоковом
+создании. Перезапись существующего хеша квоту на число хешей не расходует, а надгробия в нее не входят.
+*/
+func TestNamespaceQuotas(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "small", MaxHashes: 2, MaxPayloadSize: 8})
+	assert.NoError(t, err)
+	small := WithNamespace(ctx, "small")
+
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "one"})
+	assert.NoError(t, err)
+	two, err := service.CreateHash(small, &pb.HashRequest{Payload: "two"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "two"})
+	assert.NoError(t, err)
+
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "three"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Надгробие удаленного хеша в квоту не входит
+	_, err = service.DeleteHash(small, &pb.DeleteHashRequest{Hash: two.GetHash(), Tombstone: true})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "three"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "four"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "two"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = service.CreateHash(small, &pb.HashRequest{Payload: "too long payload"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	stream := &chunkStream{chunk: []byte("test"), count: 3, ctx: small}
+	err = service.CreateHashStream(stream)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// В пространстве по умолчанию квот нет
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "three"})
+	assert.NoError(t, err)
+}
+
+/*
+Этот тест проверяет администрирование пространств имен: создание, статистику, список и удаление
+вместе со всеми хешами пространства.
+*/
+func TestNamespaceAdmin(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "team-a", MaxHashes: 100})
+	assert.NoError(t, err)
+	_, err = service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "team-a"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = service.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "Team A"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	teamA := WithNamespace(ctx, "team-a")
+	createResp, err := service.CreateHash(teamA, &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(teamA, &pb.HashRequest{Payload: "hello"})
+	assert.NoError(t, err)
+	_, err = service.GetHash(teamA, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.NoError(t, err)
+
+	namespace, err := service.GetNamespace(ctx, &pb.GetNamespaceRequest{Name: "team-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), namespace.GetMaxHashes())
+	assert.Equal(t, int64(2), namespace.GetStats().GetHashes())
+	assert.Equal(t, int64(9), namespace.GetStats().GetTotalSize())
+	assert.Equal(t, int64(1), namespace.GetStats().GetReadCount())
+
+	listResp, err := service.ListNamespaces(ctx, &pb.ListNamespacesRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, listResp.GetNamespaces(), 2) {
+		assert.Equal(t, storage.DefaultNamespace, listResp.GetNamespaces()[0].GetName())
+		assert.Equal(t, "team-a", listResp.GetNamespaces()[1].GetName())
+	}
+
+	deleteResp, err := service.DeleteNamespace(ctx, &pb.DeleteNamespaceRequest{Name: "team-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleteResp.GetDeletedHashes())
+
+	_, err = service.GetHash(teamA, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.GetNamespace(ctx, &pb.GetNamespaceRequest{Name: "team-a"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = service.DeleteNamespace(ctx, &pb.DeleteNamespaceRequest{Name: storage.DefaultNamespace})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что перехватчик переносит пространство имен из gRPC-метаданных вызова со служебным
+токеном в контекст, а некорректное имя отклоняет с InvalidArgument. Вызов без токена выполняется
+в пространстве по умолчанию, даже если пространство в метаданных указано.
+*/
+func TestNamespaceUnaryInterceptor(t *testing.T) {
+	var namespace string
+	handler := func(ctx context.Context, req any) (any, error) {
+		namespace = NamespaceFromContext(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{}
+
+	_, err := NamespaceUnaryInterceptor(context.Background(), nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.DefaultNamespace, namespace)
+
+	// Перехватчики в том же порядке, что и в cmd/hashing
+	interceptor := func(ctx context.Context) error {
+		_, err := AdminUnaryInterceptor("secret")(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return NamespaceUnaryInterceptor(ctx, req, info, handler)
+		})
+		return err
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(NamespaceMetadataKey, "team-a", AuthorizationMetadataKey, "Bearer secret"))
+	assert.NoError(t, interceptor(ctx))
+	assert.Equal(t, "team-a", namespace)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(NamespaceMetadataKey, "../other", AuthorizationMetadataKey, "Bearer secret"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(interceptor(ctx)))
+
+	// Без служебного токена или с чужим токеном пространство из метаданных не учитывается
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(NamespaceMetadataKey, "team-a"))
+	assert.NoError(t, interceptor(ctx))
+	assert.Equal(t, storage.DefaultNamespace, namespace)
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(NamespaceMetadataKey, "team-a", AuthorizationMetadataKey, "Bearer wrong"))
+	assert.NoError(t, interceptor(ctx))
+	assert.Equal(t, storage.DefaultNamespace, namespace)
+}
@@ -0,0 +1,189 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что данные, которые отличаются только нормализацией Unicode, переводами строк,
+пробелами по краям или регистром, после нормализации получают один хеш.
+*/
+func TestNormalization(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	tests := []struct {
+		name          string
+		normalization *pb.TextNormalization
+		payloads      []string
+	}{
+		{"nfc", &pb.TextNormalization{Form: "NFC"}, []string{"cafe\u0301", "café"}},
+		{"nfkc", &pb.TextNormalization{Form: "nfkc"}, []string{"ﬁle", "file"}},
+		{"crlf", &pb.TextNormalization{Crlf: true}, []string{"a\r\nb\r\n", "a\nb\n"}},
+		{"trim", &pb.TextNormalization{Trim: true}, []string{" \t text \n", "text"}},
+		{"case fold", &pb.TextNormalization{CaseFold: true}, []string{"STRASSE", "Straße", "strasse"}},
+		{"all", &pb.TextNormalization{Form: "nfc", Crlf: true, Trim: true, CaseFold: true}, []string{" CAFE\u0301\r\nBAR\r\n", "café\nbar"}},
+	}
+	for _, tt := range tests {
+		var hashes []string
+		for _, payload := range tt.payloads {
+			resp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: payload, Normalization: tt.normalization})
+			assert.NoError(t, err, tt.name)
+			hashes = append(hashes, resp.GetHash())
+		}
+		for _, hash := range hashes[1:] {
+			assert.Equal(t, hashes[0], hash, tt.name)
+		}
+
+		// Без нормализации хеши различаются
+		plain, err := service.CheckHash(ctx, &pb.HashRequest{Payload: tt.payloads[0]})
+		assert.NoError(t, err, tt.name)
+		assert.NotEqual(t, hashes[0], plain.GetHash(), tt.name)
+	}
+}
+
+/*
+Этот тест проверяет, что сохраняются нормализованные данные, шаги нормализации записываются в запись
+хеша и возвращаются GetHash и GetHashMetadata, а CheckHash с теми же шагами находит хеш.
+*/
+func TestNormalizationRecorded(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+	normalization := &pb.TextNormalization{Form: "nfc", Crlf: true, Trim: true}
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "cafe\u0301\r\n", Normalization: normalization})
+	assert.NoError(t, err)
+	assert.Equal(t, normalization.String(), created.GetNormalization().String())
+
+	record, err := store.Get(ctx, created.GetHash())
+	assert.NoError(t, err)
+	assert.Equal(t, "crlf,nfc,trim", record.Normalization)
+
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, "café", string(got.GetPayload()))
+	assert.Equal(t, normalization.String(), got.GetNormalization().String())
+
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, normalization.String(), meta.GetNormalization().String())
+
+	checked, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "  café\n", Normalization: meta.GetNormalization()})
+	assert.NoError(t, err)
+	assert.True(t, checked.GetExists())
+	// Без шагов в запросе применяются шаги записи (см. TestCheckHashRecordedNormalization)
+	checked, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "  café\n"})
+	assert.NoError(t, err)
+	assert.True(t, checked.GetExists())
+
+	verified, err := service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "café ", ExpectedHash: created.GetHash(), Normalization: normalization})
+	assert.NoError(t, err)
+	assert.True(t, verified.GetMatch())
+
+	stream := &chunkStream{chunk: []byte("cafe\u0301\r\n"), count: 1, normalization: normalization}
+	assert.NoError(t, service.CreateHashStream(stream))
+	assert.Equal(t, created.GetHash(), stream.resp.GetHash())
+	assert.False(t, stream.resp.GetCreated())
+
+	// Без нормализации шаги не записываются
+	plain, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "plain"})
+	assert.NoError(t, err)
+	assert.Nil(t, plain.GetNormalization())
+	meta, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: plain.GetHash()})
+	assert.NoError(t, err)
+	assert.Nil(t, meta.GetNormalization())
+}
+
+/*
+Этот тест проверяет, что повторное создание тех же нормализованных данных с другими шагами находит
+существующий хеш без ошибки коллизии, а в записи остаются шаги первого создания.
+*/
+func TestNormalizationFirstCreateWins(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+	normalization := &pb.TextNormalization{CaseFold: true}
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "Hello", Normalization: normalization})
+	assert.NoError(t, err)
+	assert.True(t, created.GetCreated())
+
+	again, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.GetHash(), again.GetHash())
+	assert.False(t, again.GetCreated())
+
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: created.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, normalization.String(), meta.GetNormalization().String())
+}
+
+/*
+Этот тест проверяет, что CheckHash без шагов нормализации находит хеш, созданный с нормализацией, и возвращает
+его хеш и шаги, но только если шаги записи дают из проверяемых данных те же данные. Уже нормализованные
+данные и данные с явно переданной пустой нормализацией проверяются как есть.
+*/
+func TestCheckHashRecordedNormalization(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithHashFilter(1000, 0.01))
+	normalization := &pb.TextNormalization{Form: "nfc", Trim: true}
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: " cafe\u0301 \n", Normalization: normalization})
+	assert.NoError(t, err)
+
+	for _, payload := range []string{" cafe\u0301 \n", "cafe\u0301", "\tcafé"} {
+		checked, err := service.CheckHash(ctx, &pb.HashRequest{Payload: payload})
+		assert.NoError(t, err, payload)
+		assert.True(t, checked.GetExists(), payload)
+		assert.Equal(t, created.GetHash(), checked.GetHash(), payload)
+		assert.Equal(t, normalization.String(), checked.GetNormalization().String(), payload)
+	}
+
+	checked, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "café"})
+	assert.NoError(t, err)
+	assert.True(t, checked.GetExists())
+	assert.Equal(t, created.GetHash(), checked.GetHash())
+	assert.Nil(t, checked.GetNormalization())
+
+	// Приведение регистра дало бы те же данные, но в записи его нет
+	checked, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "CAFÉ"})
+	assert.NoError(t, err)
+	assert.False(t, checked.GetExists())
+	assert.Nil(t, checked.GetNormalization())
+
+	checked, err = service.CheckHash(ctx, &pb.HashRequest{Payload: " cafe\u0301 \n", Normalization: &pb.TextNormalization{}})
+	assert.NoError(t, err)
+	assert.False(t, checked.GetExists())
+	assert.NotEqual(t, created.GetHash(), checked.GetHash())
+}
+
+/*
+Этот тест проверяет ошибки: неизвестную форму Unicode и данные не в UTF-8.
+*/
+func TestNormalizationErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	requests := []*pb.HashRequest{
+		{Payload: "text", Normalization: &pb.TextNormalization{Form: "nfd"}},
+		{Data: []byte{0xff, 0xfe}, Normalization: &pb.TextNormalization{Trim: true}},
+	}
+	for _, req := range requests {
+		_, err := service.CreateHash(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+		_, err = service.CheckHash(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	// Бинарные данные без нормализации по-прежнему принимаются
+	_, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte{0xff, 0xfe}})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,207 @@
+package hashing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testPasswordPolicy - дешевые параметры, чтобы тесты не тратили время и память на медленные хеши.
+var testPasswordPolicy = PasswordPolicy{
+	Algorithm: PasswordArgon2id,
+	Params: PasswordParams{
+		Memory:            64,
+		Iterations:        1,
+		Parallelism:       1,
+		Cost:              4,
+		LogN:              4,
+		BlockSize:         8,
+		ScryptParallelism: 1,
+	},
+}
+
+/*
+Этот тест проверяет, что HashPassword каждым алгоритмом возвращает строку PHC со случайной солью,
+которую VerifyPassword принимает с верным паролем и отвергает с неверным, и что в хранилище ничего
+не сохраняется.
+*/
+func TestHashPassword(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store, WithPasswordPolicy(testPasswordPolicy))
+
+	tests := []struct {
+		algorithm string
+		prefix    string
+	}{
+		{PasswordArgon2id, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{PasswordBcrypt, "$2a$04$"},
+		{PasswordScrypt, "$scrypt$ln=4,r=8,p=1$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			first, err := service.HashPassword(ctx, &pb.HashPasswordRequest{Password: "correct horse", Algorithm: tt.algorithm})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.algorithm, first.GetAlgorithm())
+			assert.True(t, strings.HasPrefix(first.GetHash(), tt.prefix), first.GetHash())
+
+			second, err := service.HashPassword(ctx, &pb.HashPasswordRequest{Password: "correct horse", Algorithm: tt.algorithm})
+			assert.NoError(t, err)
+			assert.NotEqual(t, first.GetHash(), second.GetHash())
+
+			verified, err := service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "correct horse", Hash: first.GetHash()})
+			assert.NoError(t, err)
+			assert.True(t, verified.GetValid())
+			assert.Equal(t, tt.algorithm, verified.GetAlgorithm())
+
+			verified, err = service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "wrong horse", Hash: first.GetHash()})
+			assert.NoError(t, err)
+			assert.False(t, verified.GetValid())
+			assert.False(t, verified.GetNeedsRehash())
+		})
+	}
+
+	page, err := store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
+
+/*
+Этот тест проверяет, что VerifyPassword отмечает needs_rehash для хешей другого алгоритма и хешей
+с параметрами слабее политики, но не для хешей с параметрами не слабее политики.
+*/
+func TestVerifyPasswordNeedsRehash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithPasswordPolicy(testPasswordPolicy))
+
+	tests := []struct {
+		name   string
+		req    *pb.HashPasswordRequest
+		rehash bool
+	}{
+		{"policy", &pb.HashPasswordRequest{Password: "secret"}, false},
+		{"stronger", &pb.HashPasswordRequest{Password: "secret", Params: &pb.PasswordParams{Memory: 128, Iterations: 2}}, false},
+		{"other algorithm", &pb.HashPasswordRequest{Password: "secret", Algorithm: PasswordScrypt}, true},
+	}
+	for _, tt := range tests {
+		hashed, err := service.HashPassword(ctx, tt.req)
+		assert.NoError(t, err, tt.name)
+
+		verified, err := service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "secret", Hash: hashed.GetHash()})
+		assert.NoError(t, err, tt.name)
+		assert.True(t, verified.GetValid(), tt.name)
+		assert.Equal(t, tt.rehash, verified.GetNeedsRehash(), tt.name)
+	}
+
+	// После усиления политики старые хеши нужно пересчитать
+	weak, err := service.HashPassword(ctx, &pb.HashPasswordRequest{Password: "secret"})
+	assert.NoError(t, err)
+	stronger := testPasswordPolicy
+	stronger.Params.Iterations = 2
+	service = NewHashingService(storage.NewMemoryStore(), WithPasswordPolicy(stronger))
+	verified, err := service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "secret", Hash: weak.GetHash()})
+	assert.NoError(t, err)
+	assert.True(t, verified.GetValid())
+	assert.True(t, verified.GetNeedsRehash())
+}
+
+/*
+Этот тест проверяет ошибки: неизвестный алгоритм, параметры за допустимыми границами, пустой и слишком
+длинный для bcrypt пароль, а также некорректные строки хеша, в том числе с огромными параметрами.
+*/
+func TestPasswordErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithPasswordPolicy(testPasswordPolicy))
+
+	requests := []*pb.HashPasswordRequest{
+		{Password: "secret", Algorithm: "md5"},
+		{Password: "secret", Params: &pb.PasswordParams{Memory: maxArgon2Memory + 1}},
+		{Password: "secret", Params: &pb.PasswordParams{Memory: 129}},
+		{Password: "secret", Params: &pb.PasswordParams{Iterations: 3}},
+		{Password: "secret", Algorithm: PasswordBcrypt, Params: &pb.PasswordParams{Cost: 6}},
+		{Password: "secret", Algorithm: PasswordScrypt, Params: &pb.PasswordParams{LogN: 6}},
+		{Password: "secret", Algorithm: PasswordBcrypt, Params: &pb.PasswordParams{Cost: 31}},
+		{Password: "secret", Algorithm: PasswordScrypt, Params: &pb.PasswordParams{LogN: 24}},
+		{Password: ""},
+		{Password: strings.Repeat("a", 73), Algorithm: PasswordBcrypt},
+	}
+	for _, req := range requests {
+		_, err := service.HashPassword(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	hashes := []string{
+		"",
+		"plain",
+		"$md5$abc",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$scrypt$ln=30,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$scrypt$ln=4,r=8,p=1$c2FsdA$!!!",
+		"$2a$31$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234",
+	}
+	for _, hash := range hashes {
+		_, err := service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "secret", Hash: hash})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), hash)
+	}
+}
+
+/*
+Этот тест проверяет, что хеш с параметрами намного дороже политики отклоняется до вычисления: даже когда
+все места для вычисления хешей паролей заняты, VerifyPassword сразу возвращает InvalidArgument, а не ждет
+свободного места.
+*/
+func TestVerifyPasswordCostLimit(t *testing.T) {
+	service := NewHashingService(storage.NewMemoryStore(), WithPasswordPolicy(testPasswordPolicy), WithPasswordConcurrency(1))
+	release, err := service.acquirePasswordSlot(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1048576,t=64,p=64$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$scrypt$ln=20,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$2a$18$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234",
+	} {
+		_, err := service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "secret", Hash: hash})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), hash)
+	}
+
+	// Хеш в пределах политики ждет свободного места
+	_, err = service.VerifyPassword(ctx, &pb.VerifyPasswordRequest{Password: "secret",
+		Hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+/*
+Этот тест проверяет разбор политики из нотации PHC.
+*/
+func TestParsePasswordPolicy(t *testing.T) {
+	policy, err := ParsePasswordPolicy("", "m=65536,t=3,p=2")
+	assert.NoError(t, err)
+	assert.Equal(t, PasswordArgon2id, policy.Algorithm)
+	assert.Equal(t, uint32(65536), policy.Params.Memory)
+	assert.Equal(t, uint32(3), policy.Params.Iterations)
+	assert.Equal(t, uint32(2), policy.Params.Parallelism)
+
+	policy, err = ParsePasswordPolicy("bcrypt", "cost=12")
+	assert.NoError(t, err)
+	assert.Equal(t, PasswordBcrypt, policy.Algorithm)
+	assert.Equal(t, uint32(12), policy.Params.Cost)
+
+	_, err = ParsePasswordPolicy("scrypt", "m=1")
+	assert.Error(t, err)
+	_, err = ParsePasswordPolicy("bcrypt", "cost=40")
+	assert.Error(t, err)
+	_, err = ParsePasswordPolicy("md5", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Этот тест проверяет, что CreateHash с владельцем берет ссылку и на новый, и на существующий хеш,
+ReleaseHash освобождает только взятые ссылки, а сборщик мусора удаляет хеш без ссылок только после
+льготного периода.
+*/
+func TestReleaseHash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithGCGracePeriod(time.Hour))
+
+	first, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte("test"), Owner: "billing"})
+	assert.NoError(t, err)
+	assert.True(t, first.Created)
+
+	second, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte("test"), Owner: "search"})
+	assert.NoError(t, err)
+	assert.False(t, second.Created)
+
+	// Создание без владельца ссылку не берет
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Data: []byte("test")})
+	assert.NoError(t, err)
+
+	metadata, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: first.Hash})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), metadata.References)
+	assert.Nil(t, metadata.ReleasedAt)
+
+	released, err := service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: first.Hash, Owner: "billing"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), released.References)
+	assert.Nil(t, released.ReleasedAt)
+
+	// Повторно освободить ту же ссылку нельзя, владелец обязателен
+	_, err = service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: first.Hash, Owner: "billing"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: first.Hash})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: "missing", Owner: "billing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	released, err = service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: first.Hash, Owner: "search"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), released.References)
+	assert.NotNil(t, released.ReleasedAt)
+
+	// В течение льготного периода хеш остается на месте
+	collected, err := service.CollectGarbage(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collected)
+
+	collected, err = service.CollectGarbage(ctx, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, collected)
+
+	_, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: first.Hash})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что новая ссылка, взятая в льготный период, спасает хеш от сборщика мусора,
+а хеши, созданные без владельца, сборщик мусора не удаляет.
+*/
+func TestCollectGarbageReferenced(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithGCGracePeriod(time.Hour))
+
+	referenced, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte("referenced"), Owner: "billing"})
+	assert.NoError(t, err)
+	_, err = service.ReleaseHash(ctx, &pb.ReleaseHashRequest{Hash: referenced.Hash, Owner: "billing"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Data: []byte("referenced"), Owner: "search"})
+	assert.NoError(t, err)
+
+	unowned, err := service.CreateHash(ctx, &pb.HashRequest{Data: []byte("unowned")})
+	assert.NoError(t, err)
+
+	collected, err := service.CollectGarbage(ctx, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collected)
+
+	metadata, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: referenced.Hash})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), metadata.References)
+	assert.Nil(t, metadata.ReleasedAt)
+
+	_, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: unowned.Hash})
+	assert.NoError(t, err)
+}
@@ -2,6 +2,7 @@ package hashing
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	"final-project-kodzimo-hashing/internal/storage"
@@ -12,16 +13,21 @@ import (
 
 /*
 Этот тест проверяет, что метод CreateHash не только создает хеш, но и сохраняет его в базе данных Redis.
+Для запуска нужен настоящий Redis, адрес которого задается переменными REDIS_HOST, REDIS_PORT и DB_NUM.
 */
 
 func TestCreateHashIntegration(t *testing.T) {
+	if os.Getenv("REDIS_HOST") == "" {
+		t.Skip("REDIS_HOST is not set, skipping Redis integration test")
+	}
+
 	// Подключаемся к реальной базе данных Redis
 	client, err := storage.ConnectToRedis()
 	if err != nil {
 		t.Fatalf("failed to connect to Redis: %v", err)
 	}
 
-	service := NewHashingService(client)
+	service := NewHashingService(storage.NewRedisStore(client))
 	req := &pb.HashRequest{Payload: "test"}
 
 	// Создаем хеш
@@ -32,7 +38,11 @@ func TestCreateHashIntegration(t *testing.T) {
 	assert.NotEmpty(t, resp.Hash)
 
 	// Проверяем, что хеш был сохранен в Redis
-	hash, err := client.Get(context.Background(), resp.Hash).Result()
+	payload, err := client.HGet(context.Background(), resp.Hash, "payload").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, req.Payload, payload)
+
+	algorithm, err := client.HGet(context.Background(), resp.Hash, "algorithm").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, req.Payload, hash)
+	assert.Equal(t, DefaultAlgorithm, algorithm)
 }
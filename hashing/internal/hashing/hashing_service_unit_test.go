@@ -2,68 +2,65 @@ package hashing
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"testing"
+	"time"
 
 	"final-project-kodzimo-hashing/internal/storage"
 	pb "final-project-kodzimo-shared/proto"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 /*
-Этот тест проверяет, что метод CheckHash не возвращает ошибку и возвращает непустой хеш.
+Этот тест проверяет, что метод CheckHash находит хеш по исходным данным после CreateHash и возвращает
+exists = false без ошибки для данных, которые еще не хешировались.
 */
 func TestCheckHash(t *testing.T) {
-	client, err := storage.ConnectToRedis()
-	if err != nil {
-		t.Fatalf("failed to connect to Redis: %v", err)
-	}
-
-	service := NewHashingService(client)
+	service := NewHashingService(storage.NewMemoryStore())
 	req := &pb.HashRequest{Payload: "test"}
 
+	// До создания хеша данные не найдены, но хеш все равно вычислен
+	checkResp, err := service.CheckHash(context.Background(), req)
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
+	assert.NotEmpty(t, checkResp.GetHash())
+	assert.Nil(t, checkResp.GetCreatedAt())
+
 	// Создаем хеш
 	createResp, err := service.CreateHash(context.Background(), req)
 	assert.NoError(t, err)
 
-	// Проверяем хеш
-	checkReq := &pb.HashRequest{Payload: createResp.GetHash()}
-	checkResp, err := service.CheckHash(context.Background(), checkReq)
-
+	// Проверяем хеш по тем же данным
+	checkResp, err = service.CheckHash(context.Background(), req)
 	assert.NoError(t, err)
-	if checkResp != nil {
-		assert.NotEmpty(t, checkResp.Hash)
-	}
-
-	/*
-		В этом примере мы добавили новый запрос missingReq, который ищет хеш, которого нет в базе данных. Мы ожидаем,
-		что CheckHash вернет ошибку с кодом codes.NotFound в этом случае. Это позволяет нам проверить, что ваш код
-		правильно обрабатывает ситуацию, когда хеш не найден.
-
-		Пожалуйста, учтите, что этот пример предполагает, что в вашей базе данных Redis нет значения с ключом “missing”.
-		Если это не так, вам нужно будет выбрать другой ключ для этого теста.
-	*/
+	assert.True(t, checkResp.GetExists())
+	assert.Equal(t, createResp.GetHash(), checkResp.GetHash())
+	assert.Equal(t, DefaultAlgorithm, checkResp.GetAlgorithm())
+	assert.NotNil(t, checkResp.GetCreatedAt())
 
-	// Добавляем проверку на случай, когда хеша нет в базе данных
-	missingReq := &pb.HashRequest{Payload: "missing"}
-	_, err = service.CheckHash(context.Background(), missingReq)
+	// Те же данные с другим алгоритмом еще не хешировались
+	checkResp, err = service.CheckHash(context.Background(), &pb.HashRequest{Payload: "test", Algorithm: "sha512"})
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
 
-	assert.Error(t, err)
-	assert.Equal(t, codes.NotFound, status.Code(err))
+	// Неизвестный алгоритм
+	_, err = service.CheckHash(context.Background(), &pb.HashRequest{Payload: "test", Algorithm: "md5"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
 }
 
 /*
-Этот тест проверяет, что метод GetHash не возвращает ошибку и возвращает тот же хеш, который был создан.
+Этот тест проверяет, что метод GetHash не возвращает ошибку и возвращает исходные данные для созданного хеша.
 */
 func TestGetHash(t *testing.T) {
-	client, err := storage.ConnectToRedis()
-	if err != nil {
-		t.Fatalf("failed to connect to Redis: %v", err)
-	}
-
-	service := NewHashingService(client)
+	service := NewHashingService(storage.NewMemoryStore())
 	req := &pb.HashRequest{Payload: "test"}
 
 	// Создаем хеш
@@ -76,14 +73,14 @@ func TestGetHash(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, getResp)
-	assert.Equal(t, req.GetPayload(), getResp.GetHash())
+	assert.Equal(t, createResp.GetHash(), getResp.GetHash())
+	assert.Equal(t, []byte(req.GetPayload()), getResp.GetPayload())
 
 	/*
 		В этом примере мы добавили новый запрос missingReq, который ищет хеш, которого нет в базе данных. Мы ожидаем,
 		что GetHash вернет ошибку с кодом codes.NotFound в этом случае. Это позволяет нам проверить, что ваш код правильно обрабатывает ситуацию, когда хеш не найден.
 
-		Пожалуйста, учтите, что этот пример предполагает, что в вашей базе данных Redis нет значения с ключом “missing”.
-		Если это не так, вам нужно будет выбрать другой ключ для этого теста.
+		Тесты используют хранилище в памяти, поэтому значения с ключом “missing” в нем заведомо нет.
 	*/
 
 	// Добавляем проверку на случай, когда хеша нет в базе данных
@@ -98,12 +95,7 @@ func TestGetHash(t *testing.T) {
 Этот тест проверяет, что метод CreateHash не возвращает ошибку и возвращает непустой хеш.
 */
 func TestCreateHash(t *testing.T) {
-	client, err := storage.ConnectToRedis()
-	if err != nil {
-		t.Fatalf("failed to connect to Redis: %v", err)
-	}
-
-	service := NewHashingService(client)
+	service := NewHashingService(storage.NewMemoryStore())
 	req := &pb.HashRequest{Payload: "test"}
 
 	resp, err := service.CreateHash(context.Background(), req)
@@ -113,6 +105,500 @@ func TestCreateHash(t *testing.T) {
 	assert.NotEmpty(t, resp.Hash)
 }
 
+/*
+Этот тест проверяет, что повторный CreateHash тех же данных не перезаписывает запись и сообщает
+created = false, а если под хешем сохранены другие данные, возвращает AlreadyExists с причиной
+ReasonHashCollision и оставляет запись как есть.
+*/
+func TestCreateHashExisting(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	first, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", ContentType: "text/plain"})
+	assert.NoError(t, err)
+	assert.True(t, first.GetCreated())
+
+	second, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", ContentType: "application/octet-stream"})
+	assert.NoError(t, err)
+	assert.False(t, second.GetCreated())
+	assert.Equal(t, first.GetHash(), second.GetHash())
+
+	// Существующая запись не перезаписана
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: first.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", got.GetContentType())
+
+	// Коллизия: под хешем данных "other" уже сохранены другие данные
+	collision := NewHashingService(storage.NewMemoryStore())
+	other, err := collision.CreateHash(ctx, &pb.HashRequest{Payload: "other"})
+	assert.NoError(t, err)
+	err = store.Save(ctx, other.GetHash(), &storage.Record{Payload: []byte("data"), Algorithm: "sha256", Size: 4})
+	assert.NoError(t, err)
+
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "other"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		assert.Equal(t, ReasonHashCollision, details[0].(*errdetails.ErrorInfo).GetReason())
+	}
+
+	record, err := store.Get(ctx, other.GetHash())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), record.Payload)
+
+	// Потоковое создание тех же данных тоже не создает запись заново
+	stream := &chunkStream{chunk: []byte("test"), count: 1}
+	assert.NoError(t, service.CreateHashStream(stream))
+	assert.False(t, stream.resp.GetCreated())
+}
+
+/*
+Этот тест проверяет, что CreateHash использует алгоритм из запроса, GetHash находит запись только для
+того же алгоритма, а неизвестный алгоритм отклоняется с кодом InvalidArgument.
+*/
+func TestCreateHashWithAlgorithm(t *testing.T) {
+	service := NewHashingService(storage.NewMemoryStore())
+	req := &pb.HashRequest{Payload: "test", Algorithm: "sha512"}
+
+	resp, err := service.CreateHash(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha512", resp.GetAlgorithm())
+	assert.Len(t, resp.GetHash(), 128)
+
+	getResp, err := service.GetHash(context.Background(), &pb.HashRequest{Payload: resp.GetHash(), Algorithm: "sha512"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(req.GetPayload()), getResp.GetPayload())
+	assert.Equal(t, "sha512", getResp.GetAlgorithm())
+
+	_, err = service.GetHash(context.Background(), &pb.HashRequest{Payload: resp.GetHash(), Algorithm: "sha256"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = service.CreateHash(context.Background(), &pb.HashRequest{Payload: "test", Algorithm: "md5"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что бинарные данные (невалидный UTF-8) хешируются и возвращаются GetHash байт в байт
+вместе с типом содержимого, а текстовое поле payload с теми же байтами дает тот же хеш.
+*/
+func TestBinaryPayload(t *testing.T) {
+	service := NewHashingService(storage.NewMemoryStore())
+	data := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe}
+
+	createResp, err := service.CreateHash(context.Background(), &pb.HashRequest{Data: data, ContentType: "image/png"})
+	assert.NoError(t, err)
+
+	getResp, err := service.GetHash(context.Background(), &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, data, getResp.GetPayload())
+	assert.Equal(t, "image/png", getResp.GetContentType())
+
+	checkResp, err := service.CheckHash(context.Background(), &pb.HashRequest{Data: data})
+	assert.NoError(t, err)
+	assert.True(t, checkResp.GetExists())
+
+	// Поле data имеет приоритет над payload
+	textResp, err := service.CreateHash(context.Background(), &pb.HashRequest{Payload: "ignored", Data: []byte("test")})
+	assert.NoError(t, err)
+	compatResp, err := service.CreateHash(context.Background(), &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, compatResp.GetHash(), textResp.GetHash())
+}
+
+/*
+Этот тест проверяет, что GetHashMetadata возвращает сведения о записи, а каждый GetHash увеличивает
+счетчик чтений и обновляет время последнего чтения. Сам вызов GetHashMetadata чтением не считается.
+*/
+func TestGetHashMetadata(t *testing.T) {
+	service := NewHashingService(storage.NewMemoryStore())
+
+	createResp, err := service.CreateHash(context.Background(), &pb.HashRequest{Data: []byte("test"), ContentType: "text/plain", Algorithm: "sha512"})
+	assert.NoError(t, err)
+
+	lookup := &pb.HashLookupRequest{Hash: createResp.GetHash()}
+	meta, err := service.GetHashMetadata(context.Background(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, createResp.GetHash(), meta.GetHash())
+	assert.Equal(t, "sha512", meta.GetAlgorithm())
+	assert.Equal(t, "text/plain", meta.GetContentType())
+	assert.Equal(t, int64(4), meta.GetSize())
+	assert.NotNil(t, meta.GetCreatedAt())
+	assert.Nil(t, meta.GetLastAccessedAt())
+	assert.Zero(t, meta.GetReadCount())
+
+	for i := 0; i < 2; i++ {
+		_, err = service.GetHash(context.Background(), &pb.HashRequest{Payload: createResp.GetHash()})
+		assert.NoError(t, err)
+	}
+
+	meta, err = service.GetHashMetadata(context.Background(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), meta.GetReadCount())
+	assert.NotNil(t, meta.GetLastAccessedAt())
+	assert.False(t, meta.GetLastAccessedAt().AsTime().Before(meta.GetCreatedAt().AsTime()))
+
+	_, err = service.GetHashMetadata(context.Background(), &pb.HashLookupRequest{Hash: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = service.GetHashMetadata(context.Background(), &pb.HashLookupRequest{Hash: createResp.GetHash(), Algorithm: "sha256"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+/*
+Этот тест проверяет TTL из запроса и политику хранения по умолчанию: срок жизни попадает в метаданные,
+а хеш с истекшим сроком возвращает NotFound.
+*/
+func TestHashTTL(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithDefaultTTL(time.Hour))
+
+	// Без TTL в запросе действует политика хранения сервиса
+	createResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "default"})
+	assert.NoError(t, err)
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: createResp.GetHash()})
+	assert.NoError(t, err)
+	assert.NotNil(t, meta.GetExpiresAt())
+	assert.InDelta(t, time.Hour.Seconds(), meta.GetTtl().AsDuration().Seconds(), 5)
+
+	// TTL из запроса важнее политики хранения
+	createResp, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "short", Ttl: durationpb.New(50 * time.Millisecond)})
+	assert.NoError(t, err)
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	checkResp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "short"})
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
+
+	// Отрицательный TTL отклоняется
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Ttl: durationpb.New(-time.Second)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что повторное создание существующего хеша продлевает его срок жизни до запрошенного,
+но не сокращает его, а без TTL и политики хранения делает хеш бессрочным.
+*/
+func TestCreateHashExistingTTL(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+	ttl := func(hash string) *durationpb.Duration {
+		meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: hash})
+		assert.NoError(t, err)
+		return meta.GetTtl()
+	}
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Ttl: durationpb.New(time.Hour)})
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour.Seconds(), ttl(created.GetHash()).AsDuration().Seconds(), 5)
+
+	again, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Ttl: durationpb.New(2 * time.Hour)})
+	assert.NoError(t, err)
+	assert.False(t, again.GetCreated())
+	assert.InDelta(t, (2 * time.Hour).Seconds(), ttl(created.GetHash()).AsDuration().Seconds(), 5)
+
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Ttl: durationpb.New(time.Minute)})
+	assert.NoError(t, err)
+	assert.InDelta(t, (2 * time.Hour).Seconds(), ttl(created.GetHash()).AsDuration().Seconds(), 5)
+
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	assert.Nil(t, ttl(created.GetHash()))
+}
+
+/*
+Этот тест проверяет, что TouchHash продлевает срок жизни хеша, а без политики хранения и TTL
+делает его бессрочным.
+*/
+func TestTouchHash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	createResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test", Ttl: durationpb.New(time.Minute)})
+	assert.NoError(t, err)
+
+	meta, err := service.TouchHash(ctx, &pb.TouchHashRequest{Hash: createResp.GetHash(), Ttl: durationpb.New(24 * time.Hour)})
+	assert.NoError(t, err)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), meta.GetTtl().AsDuration().Seconds(), 5)
+
+	meta, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: createResp.GetHash()})
+	assert.NoError(t, err)
+	assert.Greater(t, meta.GetTtl().AsDuration(), time.Hour)
+
+	// Без TTL и без политики хранения запись становится бессрочной
+	meta, err = service.TouchHash(ctx, &pb.TouchHashRequest{Hash: createResp.GetHash()})
+	assert.NoError(t, err)
+	assert.Nil(t, meta.GetExpiresAt())
+	assert.Nil(t, meta.GetTtl())
+
+	_, err = service.TouchHash(ctx, &pb.TouchHashRequest{Hash: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что DeleteHash без надгробия удаляет хеш так, будто его никогда не было.
+*/
+func TestDeleteHash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	createResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+
+	deleteResp, err := service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: createResp.GetHash()})
+	assert.NoError(t, err)
+	assert.False(t, deleteResp.GetTombstone())
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Empty(t, status.Convert(err).Details())
+
+	checkResp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
+	assert.False(t, checkResp.GetDeleted())
+
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что после удаления с надгробием данные недоступны, а хеш сообщается как удаленный:
+CheckHash возвращает deleted = true, GetHash - NotFound с причиной ReasonHashDeleted.
+*/
+func TestDeleteHashTombstone(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	createResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+
+	deleteResp, err := service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: createResp.GetHash(), Tombstone: true})
+	assert.NoError(t, err)
+	assert.True(t, deleteResp.GetTombstone())
+	assert.NotNil(t, deleteResp.GetDeletedAt())
+
+	// Исходные данные не остаются в хранилище, а надгробие хранится ограниченный срок
+	record, err := store.Get(ctx, createResp.GetHash())
+	assert.NoError(t, err)
+	assert.Empty(t, record.Payload)
+	assert.WithinDuration(t, deleteResp.GetDeletedAt().AsTime().Add(DefaultTombstoneRetention), record.ExpiresAt, time.Second)
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		assert.Equal(t, ReasonHashDeleted, details[0].(*errdetails.ErrorInfo).GetReason())
+	}
+
+	_, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: createResp.GetHash()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	checkResp, err := service.CheckHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+	assert.False(t, checkResp.GetExists())
+	assert.True(t, checkResp.GetDeleted())
+	assert.NotNil(t, checkResp.GetDeletedAt())
+
+	// Повторное создание восстанавливает хеш
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+	checkResp, err = service.CheckHash(ctx, &pb.HashRequest{Payload: "secret"})
+	assert.NoError(t, err)
+	assert.True(t, checkResp.GetExists())
+	assert.False(t, checkResp.GetDeleted())
+}
+
+/*
+Этот тест проверяет постраничный обход ListHashes с курсором и фильтры по префиксу, алгоритму
+и времени создания. Удаленные хеши в список не попадают.
+*/
+func TestListHashes(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	created := make(map[string]bool)
+	for _, payload := range []string{"one", "two", "three", "four", "five"} {
+		resp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: payload})
+		assert.NoError(t, err)
+		created[resp.GetHash()] = true
+	}
+	sha512Resp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "six", Algorithm: "sha512"})
+	assert.NoError(t, err)
+	deletedResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "seven"})
+	assert.NoError(t, err)
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: deletedResp.GetHash(), Tombstone: true})
+	assert.NoError(t, err)
+
+	// Обходим sha256-хеши страницами по 2
+	listed := make(map[string]bool)
+	req := &pb.ListHashesRequest{Limit: 2, Algorithm: "sha256"}
+	for pages := 1; ; pages++ {
+		resp, err := service.ListHashes(ctx, req)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(resp.GetHashes()), 2)
+		for _, meta := range resp.GetHashes() {
+			assert.False(t, listed[meta.GetHash()], "hash listed twice")
+			listed[meta.GetHash()] = true
+			assert.Equal(t, "sha256", meta.GetAlgorithm())
+		}
+		if resp.GetNextCursor() == "" {
+			assert.Equal(t, 3, pages)
+			break
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+	assert.Equal(t, created, listed)
+
+	// Фильтр по префиксу
+	prefix := sha512Resp.GetHash()[:4]
+	resp, err := service.ListHashes(ctx, &pb.ListHashesRequest{Prefix: prefix})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, resp.GetHashes()) {
+		for _, meta := range resp.GetHashes() {
+			assert.True(t, strings.HasPrefix(meta.GetHash(), prefix))
+		}
+	}
+
+	// Фильтр по времени создания
+	resp, err = service.ListHashes(ctx, &pb.ListHashesRequest{CreatedAfter: timestamppb.New(time.Now().Add(time.Hour))})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.GetHashes())
+	resp, err = service.ListHashes(ctx, &pb.ListHashesRequest{CreatedBefore: timestamppb.New(time.Now().Add(time.Hour))})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetHashes(), 6)
+
+	// Некорректные параметры
+	_, err = service.ListHashes(ctx, &pb.ListHashesRequest{Prefix: "xyz"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.ListHashes(ctx, &pb.ListHashesRequest{Cursor: "%%%"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.ListHashes(ctx, &pb.ListHashesRequest{Algorithm: "md5"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что GetHash принимает сокращенный хеш: однозначное сокращение возвращает полный хеш
+и данные, неоднозначное - InvalidArgument со списком кандидатов, а слишком короткое не ищется.
+*/
+func TestGetHashAbbreviated(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	createResp, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "test"})
+	assert.NoError(t, err)
+	hash := createResp.GetHash()
+
+	getResp, err := service.GetHash(ctx, &pb.HashRequest{Payload: hash[:MinAbbreviationLength]})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, getResp.GetHash())
+	assert.Equal(t, []byte("test"), getResp.GetPayload())
+
+	// Регистр сокращения не важен
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: strings.ToUpper(hash[:10])})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, meta.GetHash())
+	assert.Equal(t, int64(1), meta.GetReadCount())
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: hash[:MinAbbreviationLength-1]})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Два хеша с общим началом; надгробие кандидатом не считается
+	assert.NoError(t, store.Save(ctx, "abcdef01", &storage.Record{Algorithm: "sha256"}))
+	assert.NoError(t, store.Save(ctx, "abcdef02", &storage.Record{Algorithm: "sha256"}))
+	assert.NoError(t, store.Save(ctx, "abcdef03", &storage.Record{Algorithm: "sha256", DeletedAt: time.Now()}))
+
+	_, err = service.GetHash(ctx, &pb.HashRequest{Payload: "abcdef"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "abcdef01, abcdef02")
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		info := details[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, ReasonAmbiguousPrefix, info.GetReason())
+		assert.Equal(t, "abcdef01,abcdef02", info.GetMetadata()["candidates"])
+	}
+
+	meta, err = service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: "abcdef02"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef02", meta.GetHash())
+}
+
+/*
+Этот тест проверяет реестр алгоритмов на известных значениях хешей от строки "test".
+*/
+func TestAlgorithmRegistry(t *testing.T) {
+	registry := NewAlgorithmRegistry()
+
+	expected := map[string]string{
+		"sha1":     "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+		"sha256":   "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		"sha3-256": "36f028580bb02cc8272a9a020f4200e346e276ae664e45ee80745574e2f5ab80",
+	}
+	for name, hash := range expected {
+		algorithm, err := registry.Lookup(name)
+		assert.NoError(t, err)
+		assert.Equal(t, hash, algorithm.Sum([]byte("test")), name)
+	}
+
+	// Пустое имя означает алгоритм по умолчанию, регистр не важен
+	algorithm, err := registry.Lookup("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultAlgorithm, algorithm.Name)
+
+	algorithm, err = registry.Lookup("SHA512")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha512", algorithm.Name)
+
+	_, err = registry.Lookup("md5")
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+}
+
 /*
 Для запуска тестов вы можете использовать go test -run 'Имя_теста'.
 */
+
+/*
+Этот тест проверяет, что при шифровании и сжатии данных в хранилище хеш считается по исходным данным:
+CheckHash и повторный CreateHash находят хеш, а GetHash возвращает исходные данные.
+*/
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	keyring, err := storage.ParseKeyring("k1:"+strings.Repeat("A", 43)+"=", "")
+	assert.NoError(t, err)
+	compressed, err := storage.Compress(storage.Encrypt(store, keyring), storage.CodecZstd, 16)
+	assert.NoError(t, err)
+	service := NewHashingService(compressed)
+
+	payload := []byte(strings.Repeat("Hello, world! ", 10))
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Data: payload})
+	assert.NoError(t, err)
+	sum := sha256.Sum256(payload)
+	assert.Equal(t, hex.EncodeToString(sum[:]), created.Hash)
+
+	raw, err := store.Get(ctx, created.Hash)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", raw.KeyID)
+	assert.NotContains(t, string(raw.Payload), "Hello")
+
+	checked, err := service.CheckHash(ctx, &pb.HashRequest{Data: payload})
+	assert.NoError(t, err)
+	assert.True(t, checked.Exists)
+
+	again, err := service.CreateHash(ctx, &pb.HashRequest{Data: payload})
+	assert.NoError(t, err)
+	assert.False(t, again.Created)
+
+	got, err := service.GetHash(ctx, &pb.HashRequest{Payload: created.Hash})
+	assert.NoError(t, err)
+	assert.Equal(t, payload, got.Payload)
+}
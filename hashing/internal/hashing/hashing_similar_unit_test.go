@@ -0,0 +1,177 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Документ, его исправленная версия и не связанный с ним текст
+const (
+	testDocument = "The hashing service stores payloads by their digest and reports whether a payload already exists. " +
+		"Exact digests change completely when a single character of the document is edited, so near duplicates " +
+		"such as revised drafts, reformatted letters or documents with a fixed typo are stored again as new payloads."
+	testEditedDocument = "The hashing service stores payloads by their digest and reports whether a payload already exists. " +
+		"Exact digests change completely when a single character of the document is edited, so near duplicates " +
+		"such as revised drafts, reformatted letters or documents with a corrected typo are stored again as new payloads."
+	testOtherDocument = "Completely different text about cooking pasta with tomatoes, garlic, olive oil and fresh basil " +
+		"leaves from the garden, served with parmesan cheese and a glass of red wine."
+)
+
+/*
+Этот тест проверяет, что отпечаток SimHash не зависит от регистра и разделителей слов, мало меняется
+при правке одного слова и сильно отличается у разных текстов, а у бинарных данных тоже есть отпечаток.
+*/
+func TestSimHash(t *testing.T) {
+	assert.Equal(t, simHash([]byte("Hello, World!")), simHash([]byte("hello world")))
+	assert.LessOrEqual(t, storage.HammingDistance(simHash([]byte(testDocument)), simHash([]byte(testEditedDocument))), DefaultSimilarDistance)
+	assert.Greater(t, storage.HammingDistance(simHash([]byte(testDocument)), simHash([]byte(testOtherDocument))), storage.MaxSimilarDistance)
+
+	assert.NotZero(t, simHash([]byte{0xff, 0x00, 0xfe, 0x01, 0x02}))
+	assert.NotZero(t, simHash([]byte("!")))
+	assert.Zero(t, simHash(nil))
+}
+
+/*
+Этот тест проверяет, что FindSimilar находит хеши похожих данных, созданные с simhash, вместе с расстоянием,
+упорядочивает их по расстоянию и не находит непохожие данные и хеши, созданные без simhash.
+*/
+func TestFindSimilar(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	document, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testDocument, Simhash: true})
+	assert.NoError(t, err)
+	other, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testOtherDocument, Simhash: true, Algorithm: "sha512"})
+	assert.NoError(t, err)
+	_, err = service.CreateHash(ctx, &pb.HashRequest{Payload: testDocument + " Not indexed."})
+	assert.NoError(t, err)
+
+	meta, err := service.GetHashMetadata(ctx, &pb.HashLookupRequest{Hash: document.GetHash()})
+	assert.NoError(t, err)
+	assert.Len(t, meta.GetSimhash(), 16)
+
+	resp, err := service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testDocument})
+	assert.NoError(t, err)
+	assert.Equal(t, meta.GetSimhash(), resp.GetFingerprint())
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, document.GetHash(), resp.GetMatches()[0].GetHash())
+		assert.Equal(t, "sha256", resp.GetMatches()[0].GetAlgorithm())
+		assert.Zero(t, resp.GetMatches()[0].GetDistance())
+	}
+
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Data: []byte(testEditedDocument), Encoding: EncodingBase58})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, encodeHash(EncodingBase58, document.GetHash()), resp.GetMatches()[0].GetHash())
+		assert.LessOrEqual(t, resp.GetMatches()[0].GetDistance(), int32(DefaultSimilarDistance))
+	}
+
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testOtherDocument, MaxDistance: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, other.GetHash(), resp.GetMatches()[0].GetHash())
+		assert.Equal(t, "sha512", resp.GetMatches()[0].GetAlgorithm())
+	}
+
+	// Ближайший хеш идет первым и остается при ограничении limit
+	edited, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testEditedDocument, Simhash: true})
+	assert.NoError(t, err)
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testEditedDocument, MaxDistance: int32(storage.MaxSimilarDistance)})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 2) {
+		assert.Equal(t, edited.GetHash(), resp.GetMatches()[0].GetHash())
+		assert.Equal(t, document.GetHash(), resp.GetMatches()[1].GetHash())
+	}
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testEditedDocument, Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, edited.GetHash(), resp.GetMatches()[0].GetHash())
+	}
+
+	// Удаленные хеши не находятся
+	_, err = service.DeleteHash(ctx, &pb.DeleteHashRequest{Hash: edited.GetHash()})
+	assert.NoError(t, err)
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testEditedDocument, Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, document.GetHash(), resp.GetMatches()[0].GetHash())
+	}
+}
+
+/*
+Этот тест проверяет, что отпечаток считается от данных после нормализации текста, как и сохраняемые данные.
+*/
+func TestFindSimilarNormalization(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+	normalization := &pb.TextNormalization{Form: "nfkc"}
+
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "ﬁle ﬁle ﬁle", Normalization: normalization, Simhash: true})
+	assert.NoError(t, err)
+
+	resp, err := service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: "ﬁle ﬁle ﬁle", Normalization: normalization, MaxDistance: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, created.GetHash(), resp.GetMatches()[0].GetHash())
+	}
+	resp, err = service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: "file", MaxDistance: 1})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetMatches(), 1)
+}
+
+/*
+Этот тест проверяет, что CreateHashStream с simhash индексирует тот же отпечаток, что и CreateHash,
+а данные сверх лимита хранения с simhash отклоняет, потому что отпечаток считается по всему документу.
+*/
+func TestCreateHashStreamSimhash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore(), WithMaxStoredPayloadSize(int64(len(testDocument))))
+
+	stream := &chunkStream{chunk: []byte(testDocument), count: 1, simhash: true}
+	assert.NoError(t, service.CreateHashStream(stream))
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: testDocument, Simhash: true})
+	assert.NoError(t, err)
+	assert.Equal(t, created.GetHash(), stream.resp.GetHash())
+
+	resp, err := service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: testEditedDocument})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetMatches(), 1) {
+		assert.Equal(t, stream.resp.GetHash(), resp.GetMatches()[0].GetHash())
+	}
+
+	err = service.CreateHashStream(&chunkStream{chunk: []byte(testDocument), count: 2, simhash: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+/*
+Этот тест проверяет ошибки FindSimilar: расстояние вне индекса, отрицательный limit, пустые данные,
+неизвестную кодировку и форму нормализации.
+*/
+func TestFindSimilarErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	requests := []*pb.FindSimilarRequest{
+		{Payload: "text", MaxDistance: storage.MaxSimilarDistance + 1},
+		{Payload: "text", MaxDistance: -1},
+		{Payload: "text", Limit: -1},
+		{},
+		{Payload: "text", Encoding: "base16"},
+		{Payload: "text", Normalization: &pb.TextNormalization{Form: "nfd"}},
+	}
+	for _, req := range requests {
+		_, err := service.FindSimilar(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	// Сообщение об ошибке называет значение по умолчанию, которое выбирает нулевое расстояние
+	_, err := service.FindSimilar(ctx, &pb.FindSimilarRequest{Payload: "text", MaxDistance: -1})
+	assert.Contains(t, status.Convert(err).Message(), "0 selects the default of 3")
+}
@@ -0,0 +1,131 @@
+package hashing
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+chunkStream - фиктивный поток CreateHashStream. Он отдает count частей, каждая из которых - один и тот же
+буфер chunk, поэтому сам поток не расходует память пропорционально объему данных.
+*/
+type chunkStream struct {
+	grpc.ServerStream
+	chunk     []byte
+	count     int
+	algorithm string
+	// canonicalJSON, normalization и simhash включают канонический JSON, нормализацию текста и отпечаток SimHash
+	canonicalJSON bool
+	normalization *pb.TextNormalization
+	simhash       bool
+	sent          int
+	resp          *pb.HashResponse
+	// ctx - контекст потока; nil означает context.Background()
+	ctx context.Context
+}
+
+func (s *chunkStream) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+func (s *chunkStream) Recv() (*pb.HashChunk, error) {
+	if s.sent == s.count {
+		return nil, io.EOF
+	}
+	s.sent++
+	return &pb.HashChunk{Data: s.chunk, Algorithm: s.algorithm, CanonicalJson: s.canonicalJSON, Normalization: s.normalization, Simhash: s.simhash}, nil
+}
+
+func (s *chunkStream) SendAndClose(resp *pb.HashResponse) error {
+	s.resp = resp
+	return nil
+}
+
+/*
+Этот тест проверяет, что хеш, созданный потоком из нескольких частей, совпадает с хешем CreateHash
+от тех же данных целиком, а небольшие данные сохраняются и доступны через GetHash.
+*/
+func TestCreateHashStream(t *testing.T) {
+	service := NewHashingService(storage.NewMemoryStore())
+
+	stream := &chunkStream{chunk: []byte("test"), count: 3, algorithm: "sha512"}
+	err := service.CreateHashStream(stream)
+	assert.NoError(t, err)
+
+	createResp, err := service.CreateHash(context.Background(), &pb.HashRequest{Payload: "testtesttest", Algorithm: "sha512"})
+	assert.NoError(t, err)
+	assert.Equal(t, createResp.GetHash(), stream.resp.GetHash())
+	assert.Equal(t, "sha512", stream.resp.GetAlgorithm())
+
+	getResp, err := service.GetHash(context.Background(), &pb.HashRequest{Payload: stream.resp.GetHash()})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("testtesttest"), getResp.GetPayload())
+
+	// Неизвестный алгоритм в первой части
+	err = service.CreateHashStream(&chunkStream{chunk: []byte("test"), count: 1, algorithm: "md5"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+/*
+Этот тест проверяет, что при хешировании 256 МБ данных потоком память не растет пропорционально объему:
+суммарно выделяется намного меньше, чем размер данных, а сами данные сверх лимита не сохраняются.
+*/
+func TestCreateHashStreamLargePayloadMemoryBounded(t *testing.T) {
+	const (
+		chunkSize = 1 << 20
+		count     = 256
+	)
+
+	store := storage.NewMemoryStore()
+	service := NewHashingService(store)
+
+	chunk := make([]byte, chunkSize)
+	for i := range chunk {
+		chunk[i] = byte(i)
+	}
+
+	// Ожидаемый хеш считаем заранее, чтобы не учитывать его в замере памяти
+	expected := sha256.New()
+	for i := 0; i < count; i++ {
+		expected.Write(chunk)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	stream := &chunkStream{chunk: chunk, count: count}
+	err := service.CreateHashStream(stream)
+
+	runtime.ReadMemStats(&after)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", expected.Sum(nil)), stream.resp.GetHash())
+
+	// Выделено меньше 1/8 объема данных: буферизуется не больше лимита хранения
+	allocated := after.TotalAlloc - before.TotalAlloc
+	assert.Less(t, allocated, uint64(chunkSize*count/8), "allocated %d bytes", allocated)
+
+	record, err := store.Get(context.Background(), stream.resp.GetHash())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(chunkSize*count), record.Size)
+	assert.False(t, record.PayloadStored())
+
+	_, err = service.GetHash(context.Background(), &pb.HashRequest{Payload: stream.resp.GetHash()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
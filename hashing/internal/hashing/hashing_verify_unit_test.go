@@ -0,0 +1,102 @@
+package hashing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"final-project-kodzimo-hashing/internal/storage"
+	pb "final-project-kodzimo-shared/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Хеши строки "test"
+const (
+	testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testSHA1   = "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+)
+
+/*
+Этот тест проверяет, что VerifyHash сравнивает пересчитанный хеш с ожидаемым, берет алгоритм
+из самоописывающего префикса или из запроса и возвращает его в ответе.
+*/
+func TestVerifyHash(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	tests := []struct {
+		name      string
+		req       *pb.VerifyHashRequest
+		match     bool
+		algorithm string
+	}{
+		{"default algorithm", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: testSHA256}, true, "sha256"},
+		{"uppercase hex", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: strings.ToUpper(testSHA256)}, true, "sha256"},
+		{"self-describing", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: "sha1:" + testSHA1}, true, "sha1"},
+		{"algorithm in request", &pb.VerifyHashRequest{Data: []byte("test"), ExpectedHash: testSHA1, Algorithm: "sha1"}, true, "sha1"},
+		{"prefix and algorithm agree", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: "SHA1:" + testSHA1, Algorithm: "sha1"}, true, "sha1"},
+		{"other payload", &pb.VerifyHashRequest{Payload: "other", ExpectedHash: "sha256:" + testSHA256}, false, "sha256"},
+		{"other algorithm", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: "sha512:" + testSHA256}, false, "sha512"},
+		{"truncated", &pb.VerifyHashRequest{Payload: "test", ExpectedHash: testSHA256[:32]}, false, "sha256"},
+	}
+	for _, tt := range tests {
+		resp, err := service.VerifyHash(ctx, tt.req)
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.match, resp.GetMatch(), tt.name)
+		assert.Equal(t, tt.algorithm, resp.GetAlgorithm(), tt.name)
+	}
+
+	// Хранилище не используется
+	page, err := service.store.List(ctx, storage.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
+
+/*
+Этот тест проверяет, что VerifyHash проверяет хеши с ключом той версией ключа, которая указана в запросе.
+*/
+func TestVerifyHashKeyed(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	_, err := service.CreateKey(ctx, &pb.CreateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+	created, err := service.CreateHash(ctx, &pb.HashRequest{Payload: "alice@example.com", Key: "emails"})
+	assert.NoError(t, err)
+	_, err = service.RotateKey(ctx, &pb.RotateKeyRequest{Name: "emails"})
+	assert.NoError(t, err)
+
+	resp, err := service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "alice@example.com", ExpectedHash: created.GetHash(), Key: "emails"})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetMatch())
+	assert.Equal(t, int64(2), resp.GetKeyVersion())
+
+	resp, err = service.VerifyHash(ctx, &pb.VerifyHashRequest{Payload: "alice@example.com", ExpectedHash: created.GetHash(), Key: "emails", KeyVersion: 1})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetMatch())
+	assert.Equal(t, "emails", resp.GetKey())
+}
+
+/*
+Этот тест проверяет ошибки VerifyHash: некорректный или пустой ожидаемый хеш, неизвестный алгоритм
+и алгоритм запроса, который не совпадает с префиксом хеша.
+*/
+func TestVerifyHashErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewHashingService(storage.NewMemoryStore())
+
+	requests := []*pb.VerifyHashRequest{
+		{Payload: "test"},
+		{Payload: "test", ExpectedHash: "not hex"},
+		{Payload: "test", ExpectedHash: "sha256:"},
+		{Payload: "test", ExpectedHash: "md5:" + testSHA256},
+		{Payload: "test", ExpectedHash: "sha1:" + testSHA1, Algorithm: "sha256"},
+	}
+	for _, req := range requests {
+		_, err := service.VerifyHash(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+}
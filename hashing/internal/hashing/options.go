@@ -0,0 +1,85 @@
+package hashing
+
+import "time"
+
+/*
+Настройки HashingService передаются в NewHashingService как функциональные опции, чтобы добавление
+новой настройки не меняло сигнатуру конструктора и существующие вызовы.
+*/
+
+// DefaultMaxStoredPayloadSize совпадает с ограничением gRPC на размер одного сообщения (4 МБ).
+const DefaultMaxStoredPayloadSize = 4 << 20
+
+type Option func(*HashingService)
+
+/*
+WithMaxStoredPayloadSize задает максимальный размер payload, который сохраняется в хранилище при
+потоковом создании хеша. Для больших данных сохраняется только запись о хеше без самих данных.
+*/
+func WithMaxStoredPayloadSize(size int64) Option {
+	return func(s *HashingService) {
+		s.maxStoredPayloadSize = size
+	}
+}
+
+/*
+WithDefaultTTL задает политику хранения: срок жизни записей, для которых TTL не указан в запросе.
+Нулевое значение (по умолчанию) означает бессрочное хранение.
+*/
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(s *HashingService) {
+		s.defaultTTL = ttl
+	}
+}
+
+/*
+WithHashFilter включает фильтр Блума перед CheckHash (см. HashFilter): capacity - число ключей первой
+ступени, falsePositiveRate - допустимая вероятность ложного срабатывания. Фильтр нужно построить
+по хранилищу вызовом RebuildFilter; до этого запросы идут в хранилище.
+*/
+func WithHashFilter(capacity uint, falsePositiveRate float64) Option {
+	return func(s *HashingService) {
+		s.filter = NewHashFilter(capacity, falsePositiveRate)
+	}
+}
+
+/*
+WithPayloadCache включает LRU-кеш данных для GetHash (см. PayloadCache), ограниченный maxEntries записями
+и maxBytes байтами. Нулевое значение снимает соответствующее ограничение.
+*/
+func WithPayloadCache(maxEntries int, maxBytes int64) Option {
+	return func(s *HashingService) {
+		s.cache = NewPayloadCache(maxEntries, maxBytes)
+	}
+}
+
+// WithGCGracePeriod задает, сколько хранится хеш после освобождения последней ссылки (см. ReleaseHash).
+func WithGCGracePeriod(period time.Duration) Option {
+	return func(s *HashingService) {
+		s.gcGracePeriod = period
+	}
+}
+
+// WithTombstoneRetention задает, сколько хранится надгробие удаленного хеша. Ноль - надгробия хранятся бессрочно.
+func WithTombstoneRetention(retention time.Duration) Option {
+	return func(s *HashingService) {
+		s.tombstoneRetention = retention
+	}
+}
+
+/*
+WithPasswordPolicy задает алгоритм и параметры, с которыми HashPassword хеширует пароли по умолчанию.
+Хеши, посчитанные другим алгоритмом или с более слабыми параметрами, VerifyPassword отмечает needs_rehash.
+*/
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(s *HashingService) {
+		s.passwordPolicy = policy
+	}
+}
+
+// WithPasswordConcurrency задает, сколько хешей паролей HashPassword и VerifyPassword вычисляют одновременно.
+func WithPasswordConcurrency(concurrency int) Option {
+	return func(s *HashingService) {
+		s.passwordConcurrency = concurrency
+	}
+}
@@ -0,0 +1,555 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+BoltStore - встроенное хранилище в одном файле на диске (bbolt). Не требует отдельного сервера,
+поэтому удобно для запуска Hashing Service без Redis. Записи хранятся в JSON. Индекс отпечатков SimHash
+хранится в отдельном bucket пустыми значениями под ключами "<часть отпечатка>/<хеш>" (см. simhash.go).
+*/
+
+var (
+	hashesBucket     = []byte("hashes")
+	namespacesBucket = []byte("namespaces")
+	macKeysBucket    = []byte("mac-keys")
+	simHashBucket    = []byte("simhash")
+)
+
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{hashesBucket, namespacesBucket, macKeysBucket, simHashBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Save(ctx context.Context, hash string, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := addToBoltSimHashIndex(tx, hash, record.SimHash); err != nil {
+			return err
+		}
+		return tx.Bucket(hashesBucket).Put([]byte(hash), data)
+	})
+}
+
+// Create проверяет и сохраняет запись в одной транзакции на запись, поэтому проверка атомарна.
+func (s *BoltStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var existing *Record
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(hashesBucket)
+
+		stored, err := getBoltRecord(bucket, hash)
+		if err == nil && !stored.Deleted() {
+			existing = stored
+			return nil
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := addToBoltSimHashIndex(tx, hash, record.SimHash); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(hash), data)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, existing == nil, nil
+}
+
+func (s *BoltStore) Get(ctx context.Context, hash string) (*Record, error) {
+	var record *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getBoltRecord(tx.Bucket(hashesBucket), hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (s *BoltStore) RecordAccess(ctx context.Context, hash string, at time.Time) error {
+	return s.updateRecord(hash, func(record *Record) error {
+		record.ReadCount++
+		record.LastAccessAt = at
+		return nil
+	})
+}
+
+func (s *BoltStore) SetExpiration(ctx context.Context, hash string, expiresAt time.Time) error {
+	return s.updateRecord(hash, func(record *Record) error {
+		record.ExpiresAt = expiresAt
+		return nil
+	})
+}
+
+func (s *BoltStore) Delete(ctx context.Context, hash string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(hashesBucket)
+
+		if _, err := getBoltRecord(bucket, hash); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(hash))
+	})
+}
+
+func (s *BoltStore) SetPayload(ctx context.Context, hash string, record *Record) error {
+	return s.updateRecord(hash, func(existing *Record) error {
+		if existing.Deleted() {
+			return ErrNotFound
+		}
+		existing.Payload = record.Payload
+		existing.Codec = record.Codec
+		existing.KeyID = record.KeyID
+		existing.DataKey = record.DataKey
+		return nil
+	})
+}
+
+func (s *BoltStore) AddReference(ctx context.Context, hash, owner string) (int64, error) {
+	var references int64
+	err := s.updateRecord(hash, func(record *Record) error {
+		if record.Deleted() {
+			return ErrNotFound
+		}
+		if record.Owners == nil {
+			record.Owners = make(map[string]int64)
+		}
+		record.Owners[owner]++
+		record.References++
+		record.ReleasedAt = time.Time{}
+		references = record.References
+		return nil
+	})
+	return references, err
+}
+
+func (s *BoltStore) ReleaseReference(ctx context.Context, hash, owner string, at time.Time) (int64, error) {
+	var references int64
+	err := s.updateRecord(hash, func(record *Record) error {
+		if record.Owners[owner] <= 0 {
+			return ErrNoReference
+		}
+		releaseReference(record, owner, at)
+		references = record.References
+		return nil
+	})
+	return references, err
+}
+
+func (s *BoltStore) DeleteUnreferenced(ctx context.Context, hash string, releasedBefore time.Time) (bool, error) {
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(hashesBucket)
+
+		record, err := getBoltRecord(bucket, hash)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil || !record.Unreferenced(releasedBefore) {
+			return err
+		}
+		deleted = true
+		return bucket.Delete([]byte(hash))
+	})
+	return deleted && err == nil, err
+}
+
+/*
+List обходит хеши курсором bbolt в порядке ключей. Курсор страницы - последний хеш предыдущей страницы.
+*/
+func (s *BoltStore) List(ctx context.Context, query ListQuery) (*ListPage, error) {
+	page := &ListPage{}
+	prefix := []byte(query.Prefix)
+	now := time.Now()
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(hashesBucket).Cursor()
+
+		key, data := c.Seek(prefix)
+		if query.Cursor != "" && query.Cursor >= query.Prefix {
+			key, data = c.Seek([]byte(query.Cursor))
+			if key != nil && string(key) == query.Cursor {
+				key, data = c.Next()
+			}
+		}
+
+		for ; key != nil && bytes.HasPrefix(key, prefix); key, data = c.Next() {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if record.Expired(now) || !query.matches(string(key), &record) {
+				continue
+			}
+			if query.limited(len(page.Entries)) {
+				page.NextCursor = page.Entries[len(page.Entries)-1].Hash
+				break
+			}
+
+			record.Payload = nil
+			page.Entries = append(page.Entries, ListEntry{Hash: string(key), Record: &record})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// FindByPrefix использует то, что ключи bbolt хранятся отсортированными в B+-дереве: Seek сразу
+// переходит к первому ключу с префиксом.
+func (s *BoltStore) FindByPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	var hashes []string
+	now := time.Now()
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(hashesBucket).Cursor()
+		for key, data := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, data = c.Next() {
+			if len(hashes) == limit {
+				break
+			}
+
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if !record.Expired(now) && !record.Deleted() {
+				hashes = append(hashes, string(key))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (s *BoltStore) FindSimilar(ctx context.Context, prefix string, fingerprint uint64, maxDistance int) ([]SimilarEntry, error) {
+	seen := make(map[string]bool)
+	var entries []SimilarEntry
+	var stale [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		hashes := tx.Bucket(hashesBucket)
+		cursor := tx.Bucket(simHashBucket).Cursor()
+		for _, band := range simHashBands(fingerprint) {
+			bandPrefix := []byte(band + "/")
+			for key, _ := cursor.Seek(bandPrefix); key != nil && bytes.HasPrefix(key, bandPrefix); key, _ = cursor.Next() {
+				hash := string(key[len(bandPrefix):])
+				if !similarKey(hash, prefix) {
+					continue
+				}
+				record, err := getBoltRecord(hashes, hash)
+				if errors.Is(err, ErrNotFound) || err == nil && !indexedUnder(record, band) {
+					stale = append(stale, append([]byte(nil), key...))
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if seen[hash] || !similarTo(hash, prefix, record, fingerprint, maxDistance) {
+					continue
+				}
+				seen[hash] = true
+
+				record.Payload = nil
+				entries = append(entries, SimilarEntry{Hash: hash, Record: record, Distance: HammingDistance(record.SimHash, fingerprint)})
+			}
+		}
+		return nil
+	})
+	if err != nil || len(stale) == 0 {
+		return entries, err
+	}
+
+	// Устаревшие элементы индекса удаляются отдельной транзакцией, только если запись не переиндексирована
+	// после поиска
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		hashes := tx.Bucket(hashesBucket)
+		index := tx.Bucket(simHashBucket)
+		for _, key := range stale {
+			band, hash, _ := strings.Cut(string(key), "/")
+			record, err := getBoltRecord(hashes, hash)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			if err == nil && indexedUnder(record, band) {
+				continue
+			}
+			if err := index.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *BoltStore) Count(ctx context.Context, prefix string) (int64, error) {
+	var count int64
+	now := time.Now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(hashesBucket).Cursor()
+		for key, data := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, data = c.Next() {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if !record.Expired(now) && !record.Deleted() {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *BoltStore) CreateNamespace(ctx context.Context, namespace *Namespace) error {
+	data, err := json.Marshal(namespace)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(namespacesBucket)
+		if bucket.Get([]byte(namespace.Name)) != nil {
+			return ErrNamespaceExists
+		}
+		return bucket.Put([]byte(namespace.Name), data)
+	})
+}
+
+func (s *BoltStore) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	var namespace Namespace
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(namespacesBucket).Get([]byte(name))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &namespace)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &namespace, nil
+}
+
+func (s *BoltStore) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	var namespaces []*Namespace
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(namespacesBucket).ForEach(func(key, data []byte) error {
+			var namespace Namespace
+			if err := json.Unmarshal(data, &namespace); err != nil {
+				return err
+			}
+			namespaces = append(namespaces, &namespace)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+func (s *BoltStore) DeleteNamespace(ctx context.Context, name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(namespacesBucket)
+		if bucket.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+func (s *BoltStore) SaveMACKey(ctx context.Context, key *MACKey, previousVersion int64) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(macKeysBucket)
+		var existing *MACKey
+		if stored := bucket.Get([]byte(key.Name)); stored != nil {
+			existing = &MACKey{}
+			if err := json.Unmarshal(stored, existing); err != nil {
+				return err
+			}
+		}
+		if err := checkMACKeyVersion(existing, previousVersion); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key.Name), data)
+	})
+}
+
+func (s *BoltStore) GetMACKey(ctx context.Context, name string) (*MACKey, error) {
+	var key MACKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(macKeysBucket).Get([]byte(name))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *BoltStore) ListMACKeys(ctx context.Context) ([]*MACKey, error) {
+	var keys []*MACKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(macKeysBucket).ForEach(func(name, data []byte) error {
+			var key MACKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return err
+			}
+			keys = append(keys, &key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *BoltStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(hashesBucket)
+
+		// Удалять ключи во время обхода курсором нельзя, поэтому сначала собираем их
+		var expired [][]byte
+		err := bucket.ForEach(func(key, data []byte) error {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if record.Expired(now) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
+
+/*
+updateRecord читает запись, изменяет ее функцией update и сохраняет обратно. Транзакции bbolt на запись
+выполняются по одной, поэтому чтение и обновление атомарны. Ошибка update отменяет изменение.
+*/
+func (s *BoltStore) updateRecord(hash string, update func(record *Record) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(hashesBucket)
+
+		record, err := getBoltRecord(bucket, hash)
+		if err != nil {
+			return err
+		}
+		if err := update(record); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(hash), data)
+	})
+}
+
+// addToBoltSimHashIndex добавляет хеш в индекс отпечатков.
+func addToBoltSimHashIndex(tx *bolt.Tx, hash string, fingerprint uint64) error {
+	if fingerprint == 0 {
+		return nil
+	}
+	index := tx.Bucket(simHashBucket)
+	for _, band := range simHashBands(fingerprint) {
+		if err := index.Put([]byte(band+"/"+hash), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBoltRecord читает запись из bucket. Запись с истекшим сроком жизни считается отсутствующей.
+func getBoltRecord(bucket *bolt.Bucket, hash string) (*Record, error) {
+	data := bucket.Get([]byte(hash))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+Сжатие данных. Хранилища сохраняют Payload как есть, а сжимает и распаковывает его обертка Compress.
+Кодек, которым сжаты данные, сохраняется в самой записи (Record.Codec), поэтому записи, сохраненные
+без сжатия или другим кодеком, читаются без миграции. Сжатые данные сохраненных записей можно
+перепаковать другим кодеком, не останавливая сервис (см. Recompress).
+*/
+
+// Поддерживаемые кодеки. CodecNone - данные хранятся без сжатия.
+const (
+	CodecNone = ""
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+// DefaultCompressionThreshold - размер данных в байтах, начиная с которого они сжимаются.
+const DefaultCompressionThreshold = 1024
+
+// Кодировщик и декодер zstd потокобезопасны при использовании EncodeAll и DecodeAll.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ValidCodec сообщает, поддерживается ли кодек.
+func ValidCodec(codec string) bool {
+	switch codec {
+	case CodecNone, CodecGzip, CodecZstd:
+		return true
+	}
+	return false
+}
+
+// compressPayload сжимает данные кодеком codec.
+func compressPayload(codec string, payload []byte) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return payload, nil
+	case CodecGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecZstd:
+		return zstdEncoder.EncodeAll(payload, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+}
+
+// DecompressPayload распаковывает данные, сжатые кодеком codec.
+func DecompressPayload(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return data, nil
+	case CodecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case CodecZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+}
+
+/*
+EncodePayload сжимает данные кодеком codec, если они не меньше threshold байт и сжатие уменьшает их
+размер, и возвращает сохраняемые данные вместе с фактически использованным кодеком.
+*/
+func EncodePayload(codec string, threshold int, payload []byte) ([]byte, string, error) {
+	if codec == CodecNone || len(payload) == 0 || len(payload) < threshold {
+		return payload, CodecNone, nil
+	}
+
+	compressed, err := compressPayload(codec, payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(compressed) >= len(payload) {
+		return payload, CodecNone, nil
+	}
+	return compressed, codec, nil
+}
+
+/*
+CompressionFromEnv возвращает кодек и порог сжатия из переменных окружения COMPRESSION (gzip или zstd,
+пустое значение - без сжатия) и COMPRESSION_THRESHOLD (по умолчанию DefaultCompressionThreshold).
+*/
+func CompressionFromEnv() (string, int, error) {
+	loadEnv()
+
+	codec := os.Getenv("COMPRESSION")
+	if !ValidCodec(codec) {
+		return "", 0, fmt.Errorf("unknown codec %q", codec)
+	}
+
+	threshold := DefaultCompressionThreshold
+	if value := os.Getenv("COMPRESSION_THRESHOLD"); value != "" {
+		var err error
+		if threshold, err = strconv.Atoi(value); err != nil {
+			return "", 0, fmt.Errorf("invalid COMPRESSION_THRESHOLD: %w", err)
+		}
+	}
+	return codec, threshold, nil
+}
+
+/*
+Compress возвращает Store, который сжимает данные записей кодеком codec при сохранении и распаковывает
+при чтении. Данные меньше threshold байт сохраняются без сжатия. Остальные методы, включая List (он
+не возвращает данные), передаются исходному хранилищу. С пустым codec новые данные не сжимаются, но
+сжатые ранее по-прежнему распаковываются, поэтому хранилище стоит оборачивать всегда.
+*/
+func Compress(store Store, codec string, threshold int) (Store, error) {
+	if !ValidCodec(codec) {
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+	return &compressedStore{Store: store, codec: codec, threshold: threshold}, nil
+}
+
+type compressedStore struct {
+	Store
+	codec     string
+	threshold int
+}
+
+// encode возвращает копию записи со сжатыми данными. Исходная запись не меняется: вызывающий код
+// сравнивает ее с существующей (см. HashingService.CreateHash).
+func (s *compressedStore) encode(record *Record) (*Record, error) {
+	payload, codec, err := EncodePayload(s.codec, s.threshold, record.Payload)
+	if err != nil {
+		return nil, err
+	}
+	encoded := *record
+	encoded.Payload = payload
+	encoded.Codec = codec
+	return &encoded, nil
+}
+
+// decode распаковывает данные прочитанной записи.
+func decode(record *Record) (*Record, error) {
+	if record.Codec == CodecNone {
+		return record, nil
+	}
+	payload, err := DecompressPayload(record.Codec, record.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	record.Payload = payload
+	record.Codec = CodecNone
+	return record, nil
+}
+
+func (s *compressedStore) Save(ctx context.Context, hash string, record *Record) error {
+	encoded, err := s.encode(record)
+	if err != nil {
+		return err
+	}
+	return s.Store.Save(ctx, hash, encoded)
+}
+
+func (s *compressedStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	encoded, err := s.encode(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, created, err := s.Store.Create(ctx, hash, encoded)
+	if err != nil || created {
+		return existing, created, err
+	}
+	existing, err = decode(existing)
+	return existing, false, err
+}
+
+func (s *compressedStore) Get(ctx context.Context, hash string) (*Record, error) {
+	record, err := s.Store.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return decode(record)
+}
+
+// RecompressStats - итоги Recompress.
+type RecompressStats struct {
+	// Scanned - число просмотренных записей с данными
+	Scanned int
+	// Recompressed - число записей, данные которых перепакованы
+	Recompressed int
+	// BytesBefore и BytesAfter - размер перепакованных данных в хранилище до и после
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+/*
+Recompress перепаковывает данные всех записей исходного хранилища (всех пространств имен) кодеком codec
+с порогом threshold: сжимает несжатые, распаковывает сжатые, если codec пустой, и пересжимает сжатые
+другим кодеком. Записи обходятся страницами по batch записей, после каждой страницы вызывается pause -
+через нее вызывающий код ограничивает нагрузку на хранилище и может прервать обход ошибкой.
+*/
+func Recompress(ctx context.Context, store HashStore, codec string, threshold, batch int, pause func(RecompressStats) error) (RecompressStats, error) {
+	var stats RecompressStats
+	if !ValidCodec(codec) {
+		return stats, fmt.Errorf("unknown codec %q", codec)
+	}
+
+	query := ListQuery{Limit: batch}
+	for {
+		page, err := store.List(ctx, query)
+		if err != nil {
+			return stats, err
+		}
+
+		for _, entry := range page.Entries {
+			record, err := store.Get(ctx, entry.Hash)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return stats, err
+			}
+			if record.Deleted() || len(record.Payload) == 0 {
+				continue
+			}
+			stats.Scanned++
+
+			payload, err := DecompressPayload(record.Codec, record.Payload)
+			if err != nil {
+				return stats, fmt.Errorf("failed to decompress %s: %w", entry.Hash, err)
+			}
+			encoded, encodedCodec, err := EncodePayload(codec, threshold, payload)
+			if err != nil {
+				return stats, err
+			}
+			if encodedCodec == record.Codec {
+				continue
+			}
+			stats.BytesBefore += int64(len(record.Payload))
+
+			record.Payload, record.Codec = encoded, encodedCodec
+			err = store.SetPayload(ctx, entry.Hash, record)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return stats, err
+			}
+			stats.Recompressed++
+			stats.BytesAfter += int64(len(encoded))
+		}
+
+		if page.NextCursor == "" {
+			return stats, nil
+		}
+		query.Cursor = page.NextCursor
+
+		if pause != nil {
+			if err := pause(stats); err != nil {
+				return stats, err
+			}
+		}
+	}
+}
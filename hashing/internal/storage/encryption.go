@@ -0,0 +1,497 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+/*
+Шифрование данных (envelope encryption). Данные каждой записи шифруются AES-GCM собственным случайным
+ключом данных, а ключ данных - ключом шифрования из Keyring. В записи хранятся зашифрованные данные,
+зашифрованный ключ данных (Record.DataKey) и идентификатор ключа шифрования (Record.KeyID). Хеш
+считается по исходным данным, поэтому поиск по хешу работает как раньше.
+
+При смене ключа (новый основной ключ в Keyring) данные перешифровывать не нужно: Reencrypt
+перешифровывает основным ключом только ключи данных. Записи без KeyID сохранены до включения
+шифрования и читаются как есть, пока их не зашифрует Reencrypt.
+*/
+
+// dataKeySize - размер ключа данных (AES-256).
+const dataKeySize = 32
+
+// ErrUnknownKey возвращается при чтении записи, зашифрованной ключом, которого нет в Keyring.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring - ключи шифрования по идентификаторам. Новые записи шифруются основным ключом (Primary).
+type Keyring struct {
+	Primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring создает Keyring из ключей AES длиной 16, 24 или 32 байта.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	keyring := &Keyring{Primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" {
+			return nil, errors.New("empty key id")
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keyring.keys[id] = aead
+	}
+	if _, ok := keyring.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+	return keyring, nil
+}
+
+/*
+LoadKeyringFile загружает Keyring из JSON-файла вида
+`{"primary": "2024-06", "keys": {"2024-01": "<base64>", "2024-06": "<base64>"}}`.
+*/
+func LoadKeyringFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Primary string            `json:"primary"`
+		Keys    map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse keyring file %s: %w", path, err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		if keys[id], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("keyring file %s: key %q: %w", path, id, err)
+		}
+	}
+	return NewKeyring(file.Primary, keys)
+}
+
+/*
+ParseKeyring разбирает ключи из строки вида "2024-01:<base64>,2024-06:<base64>". Если primary пустой,
+основным считается последний ключ списка.
+*/
+func ParseKeyring(value, primary string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	last := ""
+	for _, entry := range strings.Split(value, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key %q: expected <id>:<base64>", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+		last = id
+	}
+	if primary == "" {
+		primary = last
+	}
+	return NewKeyring(primary, keys)
+}
+
+/*
+KeyringFromEnv загружает Keyring из файла ENCRYPTION_KEYRING_FILE или из переменной ENCRYPTION_KEYS
+(основной ключ задается ENCRYPTION_PRIMARY_KEY). Если ни одна переменная не задана, возвращает nil -
+шифрование выключено.
+*/
+func KeyringFromEnv() (*Keyring, error) {
+	loadEnv()
+
+	if path := os.Getenv("ENCRYPTION_KEYRING_FILE"); path != "" {
+		return LoadKeyringFile(path)
+	}
+	if keys := os.Getenv("ENCRYPTION_KEYS"); keys != "" {
+		return ParseKeyring(keys, os.Getenv("ENCRYPTION_PRIMARY_KEY"))
+	}
+	return nil, nil
+}
+
+// KeyIDs возвращает идентификаторы ключей по порядку.
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует plaintext со случайным nonce и возвращает nonce вместе с шифротекстом.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open расшифровывает результат seal.
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+/*
+Ключ данных шифруется вместе с идентификатором ключа шифрования, а данные - вместе с ключом записи
+в хранилище, поэтому зашифрованные данные нельзя незаметно переставить в другую запись.
+*/
+
+// wrapDataKey шифрует ключ данных основным ключом.
+func (k *Keyring) wrapDataKey(dataKey []byte) ([]byte, error) {
+	return seal(k.keys[k.Primary], dataKey, []byte(k.Primary))
+}
+
+// unwrapDataKey расшифровывает ключ данных ключом keyID.
+func (k *Keyring) unwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// encrypt шифрует данные записи hash новым ключом данных и заполняет Payload, KeyID и DataKey.
+func (k *Keyring) encrypt(hash string, record *Record, payload []byte) error {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := seal(aead, payload, []byte(hash))
+	if err != nil {
+		return err
+	}
+	wrapped, err := k.wrapDataKey(dataKey)
+	if err != nil {
+		return err
+	}
+
+	record.Payload = sealed
+	record.KeyID = k.Primary
+	record.DataKey = wrapped
+	return nil
+}
+
+// decrypt возвращает расшифрованные данные записи hash.
+func (k *Keyring) decrypt(hash string, record *Record) ([]byte, error) {
+	dataKey, err := k.unwrapDataKey(record.KeyID, record.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, record.Payload, []byte(hash))
+}
+
+/*
+Encrypt возвращает Store, который шифрует данные записей при сохранении и расшифровывает при чтении.
+Записи без KeyID возвращаются как есть. Сжатие (Compress) должно оборачивать зашифрованное хранилище,
+а не наоборот: зашифрованные данные не сжимаются.
+*/
+func Encrypt(store Store, keyring *Keyring) Store {
+	return &encryptedStore{Store: store, keyring: keyring}
+}
+
+type encryptedStore struct {
+	Store
+	keyring *Keyring
+}
+
+// encode возвращает копию записи с зашифрованными данными. Записи без данных не шифруются.
+func (s *encryptedStore) encode(hash string, record *Record) (*Record, error) {
+	encoded := *record
+	encoded.KeyID, encoded.DataKey = "", nil
+	if len(record.Payload) == 0 {
+		return &encoded, nil
+	}
+	if err := s.keyring.encrypt(hash, &encoded, record.Payload); err != nil {
+		return nil, err
+	}
+	return &encoded, nil
+}
+
+// decode расшифровывает данные прочитанной записи.
+func (s *encryptedStore) decode(hash string, record *Record) (*Record, error) {
+	if record.KeyID == "" {
+		return record, nil
+	}
+	payload, err := s.keyring.decrypt(hash, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+	record.Payload = payload
+	record.KeyID, record.DataKey = "", nil
+	return record, nil
+}
+
+func (s *encryptedStore) Save(ctx context.Context, hash string, record *Record) error {
+	encoded, err := s.encode(hash, record)
+	if err != nil {
+		return err
+	}
+	return s.Store.Save(ctx, hash, encoded)
+}
+
+func (s *encryptedStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	encoded, err := s.encode(hash, record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, created, err := s.Store.Create(ctx, hash, encoded)
+	if err != nil || created {
+		return existing, created, err
+	}
+	existing, err = s.decode(hash, existing)
+	return existing, false, err
+}
+
+func (s *encryptedStore) Get(ctx context.Context, hash string) (*Record, error) {
+	record, err := s.Store.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(hash, record)
+}
+
+func (s *encryptedStore) SetPayload(ctx context.Context, hash string, record *Record) error {
+	encoded, err := s.encode(hash, record)
+	if err != nil {
+		return err
+	}
+	return s.Store.SetPayload(ctx, hash, encoded)
+}
+
+/*
+Секреты ключей хеширования (MACKeyStore) короткие, поэтому шифруются ключом шифрования напрямую, без
+ключа данных, вместе с именем и версией ключа хеширования.
+*/
+
+func macSecretData(keyID string, key *MACKey, version int64) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%d", keyID, key.Name, version))
+}
+
+// encodeMACKey возвращает копию ключа с секретами, зашифрованными основным ключом.
+func (s *encryptedStore) encodeMACKey(key *MACKey) (*MACKey, error) {
+	encoded := *key
+	encoded.Versions = make([]MACKeyVersion, len(key.Versions))
+	for i, version := range key.Versions {
+		if version.KeyID != "" {
+			return nil, fmt.Errorf("secret of key %q version %d is already encrypted", key.Name, version.Version)
+		}
+		sealed, err := seal(s.keyring.keys[s.keyring.Primary], version.Secret, macSecretData(s.keyring.Primary, key, version.Version))
+		if err != nil {
+			return nil, err
+		}
+		version.Secret, version.KeyID = sealed, s.keyring.Primary
+		encoded.Versions[i] = version
+	}
+	return &encoded, nil
+}
+
+// decodeMACKey расшифровывает секреты прочитанного ключа.
+func (s *encryptedStore) decodeMACKey(key *MACKey) (*MACKey, error) {
+	for i, version := range key.Versions {
+		if version.KeyID == "" {
+			continue
+		}
+		aead, ok := s.keyring.keys[version.KeyID]
+		if !ok {
+			return nil, fmt.Errorf("failed to decrypt secret of key %q: %w %q", key.Name, ErrUnknownKey, version.KeyID)
+		}
+		secret, err := open(aead, version.Secret, macSecretData(version.KeyID, key, version.Version))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret of key %q: %w", key.Name, err)
+		}
+		key.Versions[i].Secret, key.Versions[i].KeyID = secret, ""
+	}
+	return key, nil
+}
+
+func (s *encryptedStore) SaveMACKey(ctx context.Context, key *MACKey, previousVersion int64) error {
+	encoded, err := s.encodeMACKey(key)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveMACKey(ctx, encoded, previousVersion)
+}
+
+func (s *encryptedStore) GetMACKey(ctx context.Context, name string) (*MACKey, error) {
+	key, err := s.Store.GetMACKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeMACKey(key)
+}
+
+func (s *encryptedStore) ListMACKeys(ctx context.Context) ([]*MACKey, error) {
+	keys, err := s.Store.ListMACKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if _, err := s.decodeMACKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+/*
+ReencryptMACKeys шифрует основным ключом keyring секреты всех ключей хеширования, зашифрованные другими
+ключами или сохраненные без шифрования, и возвращает число обновленных ключей хеширования.
+*/
+func ReencryptMACKeys(ctx context.Context, store Store, keyring *Keyring) (int, error) {
+	keys, err := store.ListMACKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := Encrypt(store, keyring)
+	updated := 0
+	for _, key := range keys {
+		current := true
+		for _, version := range key.Versions {
+			current = current && version.KeyID == keyring.Primary
+		}
+		if current {
+			continue
+		}
+
+		decoded, err := encrypted.GetMACKey(ctx, key.Name)
+		if err != nil {
+			return updated, err
+		}
+		// Ключ, измененный после чтения, уже сохранен с основным ключом шифрования
+		err = encrypted.SaveMACKey(ctx, decoded, decoded.CurrentVersion())
+		if errors.Is(err, ErrKeyVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// ReencryptStats - итоги Reencrypt.
+type ReencryptStats struct {
+	// Scanned - число просмотренных записей с данными
+	Scanned int
+	// Reencrypted - число записей, ключ данных которых перешифрован основным ключом
+	Reencrypted int
+	// Encrypted - число записей, сохраненных без шифрования и зашифрованных заново
+	Encrypted int
+}
+
+/*
+Reencrypt переводит все записи исходного хранилища (всех пространств имен) на основной ключ keyring:
+ключи данных, зашифрованные другими ключами, перешифровываются основным ключом (сами данные не меняются),
+а записи без шифрования шифруются. Записи обходятся страницами по batch записей, после каждой страницы
+вызывается pause. После успешного обхода старые ключи можно убрать из Keyring.
+*/
+func Reencrypt(ctx context.Context, store HashStore, keyring *Keyring, batch int, pause func(ReencryptStats) error) (ReencryptStats, error) {
+	var stats ReencryptStats
+
+	query := ListQuery{
+		Limit: batch,
+		Filter: func(hash string, record *Record) bool {
+			return record.KeyID != keyring.Primary && !record.Deleted()
+		},
+	}
+	for {
+		page, err := store.List(ctx, query)
+		if err != nil {
+			return stats, err
+		}
+
+		for _, entry := range page.Entries {
+			record, err := store.Get(ctx, entry.Hash)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return stats, err
+			}
+			if record.Deleted() || len(record.Payload) == 0 || record.KeyID == keyring.Primary {
+				continue
+			}
+			stats.Scanned++
+
+			encrypted := record.KeyID != ""
+			if encrypted {
+				dataKey, err := keyring.unwrapDataKey(record.KeyID, record.DataKey)
+				if err != nil {
+					return stats, fmt.Errorf("failed to decrypt data key of %s: %w", entry.Hash, err)
+				}
+				if record.DataKey, err = keyring.wrapDataKey(dataKey); err != nil {
+					return stats, err
+				}
+				record.KeyID = keyring.Primary
+			} else if err := keyring.encrypt(entry.Hash, record, record.Payload); err != nil {
+				return stats, err
+			}
+
+			err = store.SetPayload(ctx, entry.Hash, record)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return stats, err
+			}
+			if encrypted {
+				stats.Reencrypted++
+			} else {
+				stats.Encrypted++
+			}
+		}
+
+		if page.NextCursor == "" {
+			return stats, nil
+		}
+		query.Cursor = page.NextCursor
+
+		if pause != nil {
+			if err := pause(stats); err != nil {
+				return stats, err
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/*
+Секретные ключи для хеширования с ключом (HMAC). У ключа есть имя и версии: новые хеши считаются текущей
+(последней) версией, а старые версии остаются, чтобы хеши, полученные ими, можно было проверить.
+Ключи общие для всех пространств имен.
+*/
+
+// ErrKeyExists возвращается SaveMACKey при создании ключа, если ключ с таким именем уже есть.
+var ErrKeyExists = errors.New("key already exists")
+
+// ErrKeyVersionConflict возвращается SaveMACKey, если ключ изменился после того, как его прочитали.
+var ErrKeyVersionConflict = errors.New("key version conflict")
+
+// MACKey - секретный ключ с версиями в порядке возрастания.
+type MACKey struct {
+	Name      string
+	CreatedAt time.Time
+	Versions  []MACKeyVersion
+}
+
+// MACKeyVersion - версия ключа.
+type MACKeyVersion struct {
+	Version int64
+	Secret  []byte
+	// KeyID - ключ шифрования, которым зашифрован Secret (см. Encrypt). Пустой - секрет не зашифрован.
+	KeyID     string
+	CreatedAt time.Time
+}
+
+// CurrentVersion возвращает номер текущей версии ключа (0, если версий нет).
+func (k *MACKey) CurrentVersion() int64 {
+	if len(k.Versions) == 0 {
+		return 0
+	}
+	return k.Versions[len(k.Versions)-1].Version
+}
+
+// Version возвращает версию ключа по номеру. Номер 0 означает текущую версию.
+func (k *MACKey) Version(version int64) (MACKeyVersion, bool) {
+	if version == 0 {
+		version = k.CurrentVersion()
+	}
+	for _, v := range k.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return MACKeyVersion{}, false
+}
+
+// MACKeyStore хранит секретные ключи.
+type MACKeyStore interface {
+	// SaveMACKey сохраняет ключ, если текущая версия сохраненного ключа равна previousVersion. Нулевая
+	// previousVersion создает ключ: если он уже есть, возвращается ErrKeyExists. Если ключ изменился,
+	// возвращается ErrKeyVersionConflict.
+	SaveMACKey(ctx context.Context, key *MACKey, previousVersion int64) error
+	// GetMACKey возвращает ключ по имени или ErrNotFound.
+	GetMACKey(ctx context.Context, name string) (*MACKey, error)
+	// ListMACKeys возвращает все ключи, упорядоченные по имени.
+	ListMACKeys(ctx context.Context) ([]*MACKey, error)
+}
+
+// checkMACKeyVersion сравнивает текущую версию сохраненного ключа existing (nil - ключа нет) с previousVersion.
+func checkMACKeyVersion(existing *MACKey, previousVersion int64) error {
+	switch {
+	case existing == nil && previousVersion == 0:
+		return nil
+	case existing == nil:
+		return ErrNotFound
+	case previousVersion == 0:
+		return ErrKeyExists
+	case existing.CurrentVersion() != previousVersion:
+		return ErrKeyVersionConflict
+	}
+	return nil
+}
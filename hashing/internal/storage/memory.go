@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+MemoryStore - потокобезопасное хранилище в памяти процесса. Данные не переживают перезапуск,
+поэтому оно подходит для тестов и локальной разработки. Отсортированный список хешей index служит
+индексом для поиска по префиксу и постраничного обхода, а simHashes - индексом отпечатков SimHash
+(часть отпечатка -> множество хешей, см. simhash.go).
+*/
+
+type MemoryStore struct {
+	mu         sync.RWMutex
+	records    map[string]Record
+	index      []string
+	simHashes  map[string]map[string]struct{}
+	namespaces map[string]Namespace
+	macKeys    map[string]MACKey
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:    make(map[string]Record),
+		simHashes:  make(map[string]map[string]struct{}),
+		namespaces: make(map[string]Namespace),
+		macKeys:    make(map[string]MACKey),
+	}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, hash string, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[hash]; !ok {
+		s.addToIndex(hash)
+	}
+	s.addToSimHashIndex(hash, record.SimHash)
+	s.records[hash] = copyRecord(record)
+	return nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.lookup(hash); ok && !existing.Deleted() {
+		copied := copyRecord(&existing)
+		return &copied, false, nil
+	}
+
+	if _, ok := s.records[hash]; !ok {
+		s.addToIndex(hash)
+	}
+	s.addToSimHashIndex(hash, record.SimHash)
+	s.records[hash] = copyRecord(record)
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, hash string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.lookup(hash)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := copyRecord(&record)
+	return &copied, nil
+}
+
+func (s *MemoryStore) RecordAccess(ctx context.Context, hash string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.lookup(hash)
+	if !ok {
+		return ErrNotFound
+	}
+
+	record.ReadCount++
+	record.LastAccessAt = at
+	s.records[hash] = record
+	return nil
+}
+
+func (s *MemoryStore) SetExpiration(ctx context.Context, hash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.lookup(hash)
+	if !ok {
+		return ErrNotFound
+	}
+
+	record.ExpiresAt = expiresAt
+	s.records[hash] = record
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(hash); !ok {
+		return ErrNotFound
+	}
+
+	delete(s.records, hash)
+	s.removeFromIndex(hash)
+	return nil
+}
+
+func (s *MemoryStore) SetPayload(ctx context.Context, hash string, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.lookup(hash)
+	if !ok || existing.Deleted() {
+		return ErrNotFound
+	}
+
+	existing.Payload = append([]byte(nil), record.Payload...)
+	existing.Codec = record.Codec
+	existing.KeyID = record.KeyID
+	existing.DataKey = append([]byte(nil), record.DataKey...)
+	s.records[hash] = existing
+	return nil
+}
+
+func (s *MemoryStore) AddReference(ctx context.Context, hash, owner string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.lookup(hash)
+	if !ok || existing.Deleted() {
+		return 0, ErrNotFound
+	}
+
+	// Записи в map изменяются копированием, чтобы не затронуть Owners у уже возвращенных копий
+	record := copyRecord(&existing)
+	if record.Owners == nil {
+		record.Owners = make(map[string]int64)
+	}
+	record.Owners[owner]++
+	record.References++
+	record.ReleasedAt = time.Time{}
+	s.records[hash] = record
+	return record.References, nil
+}
+
+func (s *MemoryStore) ReleaseReference(ctx context.Context, hash, owner string, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.lookup(hash)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if existing.Owners[owner] <= 0 {
+		return 0, ErrNoReference
+	}
+
+	record := copyRecord(&existing)
+	releaseReference(&record, owner, at)
+	s.records[hash] = record
+	return record.References, nil
+}
+
+func (s *MemoryStore) DeleteUnreferenced(ctx context.Context, hash string, releasedBefore time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.lookup(hash)
+	if !ok || !record.Unreferenced(releasedBefore) {
+		return false, nil
+	}
+
+	delete(s.records, hash)
+	s.removeFromIndex(hash)
+	return true, nil
+}
+
+/*
+List обходит хеши в лексикографическом порядке по индексу. Курсор - последний хеш предыдущей страницы,
+поэтому обход не зависит от записей, добавленных или удаленных между страницами.
+*/
+func (s *MemoryStore) List(ctx context.Context, query ListQuery) (*ListPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := sort.SearchStrings(s.index, query.Prefix)
+	if query.Cursor != "" {
+		start = max(start, sort.SearchStrings(s.index, query.Cursor+"\x00"))
+	}
+
+	page := &ListPage{}
+	for _, hash := range s.index[start:] {
+		if !strings.HasPrefix(hash, query.Prefix) {
+			break
+		}
+
+		record, ok := s.lookup(hash)
+		if !ok || !query.matches(hash, &record) {
+			continue
+		}
+		if query.limited(len(page.Entries)) {
+			page.NextCursor = page.Entries[len(page.Entries)-1].Hash
+			break
+		}
+
+		record.Payload = nil
+		page.Entries = append(page.Entries, ListEntry{Hash: hash, Record: &record})
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) FindByPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hashes []string
+	for _, hash := range s.index[sort.SearchStrings(s.index, prefix):] {
+		if !strings.HasPrefix(hash, prefix) || len(hashes) == limit {
+			break
+		}
+		if record, ok := s.lookup(hash); ok && !record.Deleted() {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+func (s *MemoryStore) FindSimilar(ctx context.Context, prefix string, fingerprint uint64, maxDistance int) ([]SimilarEntry, error) {
+	// Блокировка на запись: устаревшие элементы индекса удаляются при поиске
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var entries []SimilarEntry
+	for _, band := range simHashBands(fingerprint) {
+		for hash := range s.simHashes[band] {
+			record, ok := s.lookup(hash)
+			if !ok || !indexedUnder(&record, band) {
+				delete(s.simHashes[band], hash)
+				continue
+			}
+			if seen[hash] || !similarTo(hash, prefix, &record, fingerprint, maxDistance) {
+				continue
+			}
+			seen[hash] = true
+
+			record.Payload = nil
+			entries = append(entries, SimilarEntry{Hash: hash, Record: &record, Distance: HammingDistance(record.SimHash, fingerprint)})
+		}
+		if len(s.simHashes[band]) == 0 {
+			delete(s.simHashes, band)
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) Count(ctx context.Context, prefix string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for i := sort.SearchStrings(s.index, prefix); i < len(s.index) && strings.HasPrefix(s.index[i], prefix); i++ {
+		if record, ok := s.lookup(s.index[i]); ok && !record.Deleted() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) CreateNamespace(ctx context.Context, namespace *Namespace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.namespaces[namespace.Name]; ok {
+		return ErrNamespaceExists
+	}
+	s.namespaces[namespace.Name] = *namespace
+	return nil
+}
+
+func (s *MemoryStore) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	namespace, ok := s.namespaces[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &namespace, nil
+}
+
+func (s *MemoryStore) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	namespaces := make([]*Namespace, 0, len(s.namespaces))
+	for _, namespace := range s.namespaces {
+		namespaces = append(namespaces, &namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+	return namespaces, nil
+}
+
+func (s *MemoryStore) DeleteNamespace(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.namespaces[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.namespaces, name)
+	return nil
+}
+
+func (s *MemoryStore) SaveMACKey(ctx context.Context, key *MACKey, previousVersion int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var existing *MACKey
+	if stored, ok := s.macKeys[key.Name]; ok {
+		existing = &stored
+	}
+	if err := checkMACKeyVersion(existing, previousVersion); err != nil {
+		return err
+	}
+	s.macKeys[key.Name] = copyMACKey(key)
+	return nil
+}
+
+func (s *MemoryStore) GetMACKey(ctx context.Context, name string) (*MACKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.macKeys[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := copyMACKey(&key)
+	return &copied, nil
+}
+
+func (s *MemoryStore) ListMACKeys(ctx context.Context) ([]*MACKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*MACKey, 0, len(s.macKeys))
+	for _, key := range s.macKeys {
+		copied := copyMACKey(&key)
+		keys = append(keys, &copied)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+func (s *MemoryStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for hash, record := range s.records {
+		if record.Expired(now) {
+			delete(s.records, hash)
+			s.removeFromIndex(hash)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// lookup возвращает запись, если она есть и ее срок жизни не истек. Вызывается под блокировкой.
+func (s *MemoryStore) lookup(hash string) (Record, bool) {
+	record, ok := s.records[hash]
+	if !ok || record.Expired(time.Now()) {
+		return Record{}, false
+	}
+	return record, true
+}
+
+// addToIndex и removeFromIndex поддерживают index отсортированным. Вызываются под блокировкой.
+func (s *MemoryStore) addToIndex(hash string) {
+	i := sort.SearchStrings(s.index, hash)
+	s.index = append(s.index, "")
+	copy(s.index[i+1:], s.index[i:])
+	s.index[i] = hash
+}
+
+func (s *MemoryStore) removeFromIndex(hash string) {
+	i := sort.SearchStrings(s.index, hash)
+	if i < len(s.index) && s.index[i] == hash {
+		s.index = append(s.index[:i], s.index[i+1:]...)
+	}
+}
+
+// addToSimHashIndex добавляет хеш в индекс отпечатков. Вызывается под блокировкой.
+func (s *MemoryStore) addToSimHashIndex(hash string, fingerprint uint64) {
+	if fingerprint == 0 {
+		return
+	}
+	for _, band := range simHashBands(fingerprint) {
+		if s.simHashes[band] == nil {
+			s.simHashes[band] = make(map[string]struct{})
+		}
+		s.simHashes[band][hash] = struct{}{}
+	}
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// copyRecord копирует запись вместе с payload и ссылками, чтобы вызывающий код не мог изменить данные
+// в хранилище.
+func copyRecord(record *Record) Record {
+	copied := *record
+	copied.Payload = append([]byte(nil), record.Payload...)
+	copied.DataKey = append([]byte(nil), record.DataKey...)
+	if record.Owners != nil {
+		copied.Owners = make(map[string]int64, len(record.Owners))
+		for owner, count := range record.Owners {
+			copied.Owners[owner] = count
+		}
+	}
+	return copied
+}
+
+// copyMACKey копирует ключ вместе со списком версий.
+func copyMACKey(key *MACKey) MACKey {
+	copied := *key
+	copied.Versions = append([]MACKeyVersion(nil), key.Versions...)
+	return copied
+}
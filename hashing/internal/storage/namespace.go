@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+/*
+Пространства имен (namespaces) разделяют записи разных команд в одном хранилище. Записи пространства
+хранятся под ключами "ns/<имя>/<хеш>", а записи пространства по умолчанию - под самим хешем, как до
+появления пространств, поэтому существующие записи попадают в пространство по умолчанию. Записи первых версий
+в старом формате Redis переводит при первом чтении и через RedisStore.MigrateLegacy. Ключи с префиксом
+"ns/" не пересекаются с хешами: хеш состоит только из шестнадцатеричных цифр.
+*/
+
+// DefaultNamespace - пространство имен запросов, в которых оно не указано.
+const DefaultNamespace = "default"
+
+const namespaceKeyPrefix = "ns/"
+
+// ErrNamespaceExists возвращается CreateNamespace, если пространство с таким именем уже есть.
+var ErrNamespaceExists = errors.New("namespace already exists")
+
+// Namespace - описание пространства имен с квотами. Нулевая квота означает отсутствие ограничения.
+type Namespace struct {
+	Name           string
+	CreatedAt      time.Time
+	MaxHashes      int64
+	MaxPayloadSize int64
+}
+
+// NamespaceStore хранит описания пространств имен. Пространство по умолчанию в нем не хранится.
+type NamespaceStore interface {
+	// CreateNamespace сохраняет новое пространство или возвращает ErrNamespaceExists.
+	CreateNamespace(ctx context.Context, namespace *Namespace) error
+	// GetNamespace возвращает пространство по имени или ErrNotFound.
+	GetNamespace(ctx context.Context, name string) (*Namespace, error)
+	// ListNamespaces возвращает все пространства, упорядоченные по имени.
+	ListNamespaces(ctx context.Context) ([]*Namespace, error)
+	// DeleteNamespace удаляет описание пространства или возвращает ErrNotFound. Записи не удаляются.
+	DeleteNamespace(ctx context.Context, name string) error
+}
+
+// Store - хранилище записей о хешах вместе с описаниями пространств имен и секретными ключами.
+type Store interface {
+	HashStore
+	NamespaceStore
+	MACKeyStore
+}
+
+// namespaceKeys возвращает префикс ключей записей пространства.
+func namespaceKeys(namespace string) string {
+	if namespace == DefaultNamespace {
+		return ""
+	}
+	return namespaceKeyPrefix + namespace + "/"
+}
+
+// Key возвращает ключ, под которым хранится запись hash пространства namespace.
+func Key(namespace, hash string) string {
+	return namespaceKeys(namespace) + hash
+}
+
+/*
+InNamespace возвращает HashStore, в котором видны только записи пространства namespace: к хешам добавляется
+префикс ключей пространства, а из результатов List, FindByPrefix и FindSimilar он убирается. Close у такого хранилища
+ничего не делает - закрывать нужно исходное хранилище.
+*/
+func InNamespace(store HashStore, namespace string) HashStore {
+	return &namespacedStore{store: store, prefix: namespaceKeys(namespace)}
+}
+
+type namespacedStore struct {
+	store  HashStore
+	prefix string
+}
+
+func (s *namespacedStore) Save(ctx context.Context, hash string, record *Record) error {
+	return s.store.Save(ctx, s.prefix+hash, record)
+}
+
+func (s *namespacedStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	return s.store.Create(ctx, s.prefix+hash, record)
+}
+
+func (s *namespacedStore) Get(ctx context.Context, hash string) (*Record, error) {
+	return s.store.Get(ctx, s.prefix+hash)
+}
+
+func (s *namespacedStore) RecordAccess(ctx context.Context, hash string, at time.Time) error {
+	return s.store.RecordAccess(ctx, s.prefix+hash, at)
+}
+
+func (s *namespacedStore) SetExpiration(ctx context.Context, hash string, expiresAt time.Time) error {
+	return s.store.SetExpiration(ctx, s.prefix+hash, expiresAt)
+}
+
+func (s *namespacedStore) Delete(ctx context.Context, hash string) error {
+	return s.store.Delete(ctx, s.prefix+hash)
+}
+
+func (s *namespacedStore) SetPayload(ctx context.Context, hash string, record *Record) error {
+	return s.store.SetPayload(ctx, s.prefix+hash, record)
+}
+
+func (s *namespacedStore) AddReference(ctx context.Context, hash, owner string) (int64, error) {
+	return s.store.AddReference(ctx, s.prefix+hash, owner)
+}
+
+func (s *namespacedStore) ReleaseReference(ctx context.Context, hash, owner string, at time.Time) (int64, error) {
+	return s.store.ReleaseReference(ctx, s.prefix+hash, owner, at)
+}
+
+func (s *namespacedStore) DeleteUnreferenced(ctx context.Context, hash string, releasedBefore time.Time) (bool, error) {
+	return s.store.DeleteUnreferenced(ctx, s.prefix+hash, releasedBefore)
+}
+
+func (s *namespacedStore) List(ctx context.Context, query ListQuery) (*ListPage, error) {
+	filter := query.Filter
+	query.Prefix = s.prefix + query.Prefix
+	query.Filter = func(key string, record *Record) bool {
+		// В пространство по умолчанию не попадают записи других пространств
+		if s.prefix == "" && strings.HasPrefix(key, namespaceKeyPrefix) {
+			return false
+		}
+		return filter == nil || filter(strings.TrimPrefix(key, s.prefix), record)
+	}
+
+	page, err := s.store.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Entries {
+		page.Entries[i].Hash = strings.TrimPrefix(page.Entries[i].Hash, s.prefix)
+	}
+	return page, nil
+}
+
+func (s *namespacedStore) FindByPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	keys, err := s.store.FindByPrefix(ctx, s.prefix+prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if s.prefix == "" && strings.HasPrefix(key, namespaceKeyPrefix) {
+			continue
+		}
+		hashes = append(hashes, strings.TrimPrefix(key, s.prefix))
+	}
+	return hashes, nil
+}
+
+func (s *namespacedStore) FindSimilar(ctx context.Context, prefix string, fingerprint uint64, maxDistance int) ([]SimilarEntry, error) {
+	entries, err := s.store.FindSimilar(ctx, s.prefix+prefix, fingerprint, maxDistance)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entries[i].Hash = strings.TrimPrefix(entries[i].Hash, s.prefix)
+	}
+	return entries, nil
+}
+
+func (s *namespacedStore) Count(ctx context.Context, prefix string) (int64, error) {
+	total, err := s.store.Count(ctx, s.prefix+prefix)
+	if err != nil || s.prefix != "" || prefix != "" {
+		return total, err
+	}
+
+	// Пространство по умолчанию - все ключи, кроме ключей других пространств
+	namespaced, err := s.store.Count(ctx, namespaceKeyPrefix)
+	return total - namespaced, err
+}
+
+func (s *namespacedStore) Close() error {
+	return nil
+}
@@ -1,21 +1,26 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/joho/godotenv"
 )
 
 func ConnectToRedis() (*redis.Client, error) {
 	// Загружаем переменные из .env файла
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
+	loadEnv()
 
 	redisHost := os.Getenv("REDIS_HOST")
 	redisPort := os.Getenv("REDIS_PORT")
@@ -38,3 +43,944 @@ func ConnectToRedis() (*redis.Client, error) {
 
 	return client, nil
 }
+
+// Поля записи о хеше в Redis
+const (
+	payloadField     = "payload"
+	contentTypeField = "content_type"
+	algorithmField   = "algorithm"
+	createdAtField   = "created_at"
+	sizeField        = "size"
+	lastAccessField  = "last_access_at"
+	readCountField   = "read_count"
+	expiresAtField   = "expires_at"
+	deletedAtField   = "deleted_at"
+	referencesField  = "references"
+	releasedAtField  = "released_at"
+	codecField       = "codec"
+	keyIDField       = "key_id"
+	dataKeyField     = "data_key"
+	macKeyField      = "mac_key"
+	macVersionField  = "mac_key_version"
+	normalizeField   = "normalization"
+	simHashField     = "simhash"
+	// Число ссылок каждого владельца хранится в поле ownerFieldPrefix + владелец
+	ownerFieldPrefix = "owner:"
+)
+
+/*
+recordAccessScript обновляет статистику чтений только у существующей записи. HINCRBY сам по себе создал бы
+пустой ключ для отсутствующего хеша, поэтому проверка и обновление выполняются атомарно в Lua.
+*/
+var recordAccessScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+redis.call("HSET", KEYS[1], ARGV[2], ARGV[3])
+return 1
+`)
+
+/*
+setExpirationScript меняет срок жизни существующей записи: сохраняет его в поле записи и выставляет ключу
+PEXPIREAT, либо снимает ограничение через PERSIST, если новый срок нулевой.
+*/
+var setExpirationScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+if ARGV[3] == "0" then
+	redis.call("PERSIST", KEYS[1])
+else
+	redis.call("PEXPIREAT", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+/*
+createScript сохраняет запись, только если ключа нет или в нем надгробие (поле deleted_at не нулевое),
+и добавляет хеш в индекс и в множества индекса отпечатков (KEYS[3] и дальше). Если запись уже есть,
+возвращает ее поля (HGETALL), иначе - пустой список.
+ARGV: имя поля deleted_at, срок жизни для PEXPIREAT (0 - бессрочно), затем поля записи для HSET.
+*/
+var createScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	local deleted = redis.call("HGET", KEYS[1], ARGV[1])
+	if not deleted or deleted == "0" then
+		return redis.call("HGETALL", KEYS[1])
+	end
+	redis.call("DEL", KEYS[1])
+end
+redis.call("HSET", KEYS[1], unpack(ARGV, 3))
+if ARGV[2] ~= "0" then
+	redis.call("PEXPIREAT", KEYS[1], ARGV[2])
+end
+redis.call("ZADD", KEYS[2], 0, KEYS[1])
+for i = 3, #KEYS do
+	redis.call("SADD", KEYS[i], KEYS[1])
+end
+return {}
+`)
+
+/*
+setPayloadScript заменяет данные и поля их хранения у существующей записи, которая не является надгробием.
+Возвращает 0, если записи нет. ARGV: поле deleted_at, затем поля и значения для HSET.
+*/
+var setPayloadScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local deleted = redis.call("HGET", KEYS[1], ARGV[1])
+if deleted and deleted ~= "0" then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV, 2))
+return 1
+`)
+
+/*
+addReferenceScript увеличивает счетчики ссылок существующей записи, которая не является надгробием, и
+сбрасывает время освобождения. Возвращает общее число ссылок или -1, если записи нет.
+ARGV: поле deleted_at, поле владельца, поле references, поле released_at.
+*/
+var addReferenceScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+local deleted = redis.call("HGET", KEYS[1], ARGV[1])
+if deleted and deleted ~= "0" then
+	return -1
+end
+redis.call("HINCRBY", KEYS[1], ARGV[2], 1)
+redis.call("HSET", KEYS[1], ARGV[4], 0)
+return redis.call("HINCRBY", KEYS[1], ARGV[3], 1)
+`)
+
+/*
+releaseReferenceScript освобождает ссылку владельца и, если ссылок не осталось, запоминает время
+освобождения. Возвращает оставшееся число ссылок, -1, если записи нет, или -2, если у владельца нет ссылки.
+ARGV: поле владельца, поле references, поле released_at, время освобождения.
+*/
+var releaseReferenceScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+local count = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if count <= 0 then
+	return -2
+end
+if count == 1 then
+	redis.call("HDEL", KEYS[1], ARGV[1])
+else
+	redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+end
+local references = redis.call("HINCRBY", KEYS[1], ARGV[2], -1)
+if references <= 0 then
+	redis.call("HSET", KEYS[1], ARGV[3], ARGV[4])
+end
+return references
+`)
+
+/*
+deleteUnreferencedScript удаляет запись вместе с ее элементом индекса, только если ссылок на нее нет,
+а последняя освобождена не позже заданного времени. ARGV: поле references, поле released_at, время.
+*/
+var deleteUnreferencedScript = redis.NewScript(`
+local references = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+local released = tonumber(redis.call("HGET", KEYS[1], ARGV[2]) or "0")
+if references > 0 or released == 0 or released > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("ZREM", KEYS[2], KEYS[1])
+return 1
+`)
+
+/*
+RedisStore хранит каждую запись как Redis hash (HSET) с ключом, равным хешу. Значения полей Redis
+бинарно-безопасны, поэтому payload сохраняется и читается байт в байт.
+
+Для поиска по префиксу все хеши дополнительно хранятся в sorted set hashIndexKey с нулевым score:
+ZRANGEBYLEX находит хеши с префиксом без обхода всех ключей, а ZLEXCOUNT считает их. Надгробия в индекс
+не входят. Redis удаляет истекшие записи сам и не трогает индекс, поэтому такие хеши убираются из индекса
+при поиске и очистке (PurgeExpired).
+
+Индекс отпечатков SimHash - множества simHashIndexKey + часть отпечатка (см. simhash.go) с хешами записей.
+*/
+
+const hashIndexKey = "hash-index"
+
+const simHashIndexKey = "simhash/"
+
+// Описания пространств имен хранятся в строковых ключах namespaceConfigKey + имя в JSON.
+const namespaceConfigKey = "namespace/"
+
+// Секретные ключи хранятся в строковых ключах macKeyConfigKey + имя в JSON.
+const macKeyConfigKey = "mac-key/"
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Save(ctx context.Context, hash string, record *Record) error {
+	// DEL и HSET в одной транзакции, чтобы от старой записи не осталось лишних полей.
+	// Срок жизни записи Redis отслеживает сам через PEXPIREAT.
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, hash)
+		pipe.HSet(ctx, hash, recordFields(record)...)
+		if !record.ExpiresAt.IsZero() {
+			pipe.PExpireAt(ctx, hash, record.ExpiresAt)
+		}
+		if record.Deleted() {
+			pipe.ZRem(ctx, hashIndexKey, hash)
+		} else {
+			pipe.ZAdd(ctx, hashIndexKey, &redis.Z{Member: hash})
+		}
+		for _, key := range simHashKeys(record.SimHash) {
+			pipe.SAdd(ctx, key, hash)
+		}
+		return nil
+	})
+	return err
+}
+
+/*
+Записи первых версий сервиса хранились строковыми ключами (SET <sha256> <payload>), без полей записи и вне
+индекса. Get и Create переводят такую запись в Redis hash при первом обращении (ошибка WRONGTYPE), а
+MigrateLegacy переводит сразу все такие записи, чтобы они появились в List, Count и поиске по префиксу.
+*/
+
+// legacyAlgorithm - алгоритм записей первых версий сервиса: они хешировали данные только SHA-256.
+const legacyAlgorithm = "sha256"
+
+// legacyKeyPattern - ключи записей первых версий: хеш SHA-256 в hex.
+var legacyKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+/*
+migrateLegacyScript атомарно переводит строковый ключ KEYS[1] в Redis hash с данными, алгоритмом и размером
+и добавляет его в индекс KEYS[2]. Ключи другого типа не трогает и возвращает 0.
+ARGV: поля payload, algorithm и size, затем алгоритм записи.
+*/
+var migrateLegacyScript = redis.NewScript(`
+if redis.call("TYPE", KEYS[1]).ok ~= "string" then
+	return 0
+end
+local payload = redis.call("GET", KEYS[1])
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], ARGV[1], payload, ARGV[2], ARGV[4], ARGV[3], string.len(payload))
+redis.call("ZADD", KEYS[2], 0, KEYS[1])
+return 1
+`)
+
+// isWrongType сообщает, что команда обратилась к ключу другого типа - например, к записи первых версий.
+func isWrongType(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "WRONGTYPE")
+}
+
+// migrateLegacy переводит запись первых версий под ключом hash в Redis hash; возвращает false, если ее нет.
+func (s *RedisStore) migrateLegacy(ctx context.Context, hash string) (bool, error) {
+	if !legacyKeyPattern.MatchString(hash) {
+		return false, nil
+	}
+	migrated, err := migrateLegacyScript.Run(ctx, s.client, []string{hash, hashIndexKey},
+		payloadField, algorithmField, sizeField, legacyAlgorithm).Int()
+	return migrated == 1, err
+}
+
+// MigrateLegacy переводит все записи первых версий сервиса в Redis hash и возвращает их число.
+func (s *RedisStore) MigrateLegacy(ctx context.Context) (int, error) {
+	var migrated int
+	iter := s.client.ScanType(ctx, 0, "*", 0, "string").Iterator()
+	for iter.Next(ctx) {
+		ok, err := s.migrateLegacy(ctx, iter.Val())
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+	return migrated, iter.Err()
+}
+
+func (s *RedisStore) Create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	existing, created, err := s.create(ctx, hash, record)
+	if isWrongType(err) {
+		if migrated, migrateErr := s.migrateLegacy(ctx, hash); migrateErr != nil || !migrated {
+			return nil, false, cmp.Or(migrateErr, err)
+		}
+		existing, created, err = s.create(ctx, hash, record)
+	}
+	return existing, created, err
+}
+
+func (s *RedisStore) create(ctx context.Context, hash string, record *Record) (*Record, bool, error) {
+	var expiresAtMillis int64
+	if !record.ExpiresAt.IsZero() {
+		expiresAtMillis = record.ExpiresAt.UnixMilli()
+	}
+
+	args := append([]interface{}{deletedAtField, expiresAtMillis}, recordFields(record)...)
+	result, err := createScript.Run(ctx, s.client, append([]string{hash, hashIndexKey}, simHashKeys(record.SimHash)...), args...).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(result) == 0 {
+		return nil, true, nil
+	}
+
+	// HGETALL в Lua возвращает поля и значения одним списком
+	fields := make(map[string]string, len(result)/2)
+	for i := 0; i+1 < len(result); i += 2 {
+		field, _ := result[i].(string)
+		value, _ := result[i+1].(string)
+		fields[field] = value
+	}
+	return parseRecord(fields), false, nil
+}
+
+// recordFields возвращает пары "поле, значение" для HSET.
+func recordFields(record *Record) []interface{} {
+	fields := []interface{}{
+		payloadField, record.Payload,
+		codecField, record.Codec,
+		keyIDField, record.KeyID,
+		dataKeyField, record.DataKey,
+		contentTypeField, record.ContentType,
+		algorithmField, record.Algorithm,
+		macKeyField, record.MACKey,
+		macVersionField, record.MACKeyVersion,
+		normalizeField, record.Normalization,
+		simHashField, record.SimHash,
+		createdAtField, unixNano(record.CreatedAt),
+		sizeField, record.Size,
+		lastAccessField, unixNano(record.LastAccessAt),
+		readCountField, record.ReadCount,
+		expiresAtField, unixNano(record.ExpiresAt),
+		deletedAtField, unixNano(record.DeletedAt),
+		referencesField, record.References,
+		releasedAtField, unixNano(record.ReleasedAt),
+	}
+	for owner, count := range record.Owners {
+		fields = append(fields, ownerFieldPrefix+owner, count)
+	}
+	return fields
+}
+
+func (s *RedisStore) Get(ctx context.Context, hash string) (*Record, error) {
+	fields, err := s.client.HGetAll(ctx, hash).Result()
+	if isWrongType(err) {
+		if migrated, migrateErr := s.migrateLegacy(ctx, hash); migrateErr != nil || !migrated {
+			return nil, cmp.Or(migrateErr, err)
+		}
+		fields, err = s.client.HGetAll(ctx, hash).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// HGetAll возвращает пустой map, если ключа нет
+	if len(fields) == 0 {
+		return nil, ErrNotFound
+	}
+
+	record := parseRecord(fields)
+	// Redis удаляет ключ с задержкой до миллисекунды, поэтому проверяем срок и здесь
+	if record.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// parseRecord собирает запись из полей Redis hash.
+func parseRecord(fields map[string]string) *Record {
+	payload := []byte(fields[payloadField])
+	var dataKey []byte
+	if value := fields[dataKeyField]; value != "" {
+		dataKey = []byte(value)
+	}
+	size, err := strconv.ParseInt(fields[sizeField], 10, 64)
+	if err != nil {
+		size = int64(len(payload))
+	}
+
+	readCount, _ := strconv.ParseInt(fields[readCountField], 10, 64)
+	references, _ := strconv.ParseInt(fields[referencesField], 10, 64)
+	macKeyVersion, _ := strconv.ParseInt(fields[macVersionField], 10, 64)
+	simHash, _ := strconv.ParseUint(fields[simHashField], 10, 64)
+
+	var owners map[string]int64
+	for field, value := range fields {
+		if owner, ok := strings.CutPrefix(field, ownerFieldPrefix); ok {
+			if owners == nil {
+				owners = make(map[string]int64)
+			}
+			owners[owner], _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return &Record{
+		Payload:       payload,
+		Codec:         fields[codecField],
+		KeyID:         fields[keyIDField],
+		DataKey:       dataKey,
+		ContentType:   fields[contentTypeField],
+		Algorithm:     fields[algorithmField],
+		MACKey:        fields[macKeyField],
+		MACKeyVersion: macKeyVersion,
+		Normalization: fields[normalizeField],
+		SimHash:       simHash,
+		CreatedAt:     parseUnixNano(fields[createdAtField]),
+		Size:          size,
+		LastAccessAt:  parseUnixNano(fields[lastAccessField]),
+		ReadCount:     readCount,
+		ExpiresAt:     parseUnixNano(fields[expiresAtField]),
+		DeletedAt:     parseUnixNano(fields[deletedAtField]),
+		Owners:        owners,
+		References:    references,
+		ReleasedAt:    parseUnixNano(fields[releasedAtField]),
+	}
+}
+
+func (s *RedisStore) RecordAccess(ctx context.Context, hash string, at time.Time) error {
+	updated, err := recordAccessScript.Run(ctx, s.client, []string{hash}, readCountField, lastAccessField, at.UnixNano()).Int()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) SetExpiration(ctx context.Context, hash string, expiresAt time.Time) error {
+	var expiresAtMillis int64
+	if !expiresAt.IsZero() {
+		expiresAtMillis = expiresAt.UnixMilli()
+	}
+
+	updated, err := setExpirationScript.Run(ctx, s.client, []string{hash}, expiresAtField, unixNano(expiresAt), expiresAtMillis).Int()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) SetPayload(ctx context.Context, hash string, record *Record) error {
+	updated, err := setPayloadScript.Run(ctx, s.client, []string{hash}, deletedAtField,
+		payloadField, record.Payload, codecField, record.Codec,
+		keyIDField, record.KeyID, dataKeyField, record.DataKey).Int()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) AddReference(ctx context.Context, hash, owner string) (int64, error) {
+	references, err := addReferenceScript.Run(ctx, s.client, []string{hash},
+		deletedAtField, ownerFieldPrefix+owner, referencesField, releasedAtField).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if references < 0 {
+		return 0, ErrNotFound
+	}
+	return references, nil
+}
+
+func (s *RedisStore) ReleaseReference(ctx context.Context, hash, owner string, at time.Time) (int64, error) {
+	references, err := releaseReferenceScript.Run(ctx, s.client, []string{hash},
+		ownerFieldPrefix+owner, referencesField, releasedAtField, at.UnixNano()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	switch references {
+	case -1:
+		return 0, ErrNotFound
+	case -2:
+		return 0, ErrNoReference
+	}
+	return references, nil
+}
+
+func (s *RedisStore) DeleteUnreferenced(ctx context.Context, hash string, releasedBefore time.Time) (bool, error) {
+	deleted, err := deleteUnreferencedScript.Run(ctx, s.client, []string{hash, hashIndexKey},
+		referencesField, releasedAtField, releasedBefore.UnixNano()).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, hash string) error {
+	var del *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, hash)
+		pipe.ZRem(ctx, hashIndexKey, hash)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) FindByPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	var hashes []string
+	var offset int64
+	for len(hashes) < limit {
+		candidates, err := s.client.ZRangeByLex(ctx, hashIndexKey, &redis.ZRangeBy{
+			Min:    "[" + prefix,
+			Max:    "[" + prefix + "\xff",
+			Offset: offset,
+			Count:  int64(limit - len(hashes)),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		offset += int64(len(candidates))
+
+		// Проверяем, что записи еще существуют, и убираем из индекса истекшие
+		stale, err := s.missingKeys(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range candidates {
+			if !slices.Contains(stale, interface{}(hash)) {
+				hashes = append(hashes, hash)
+			}
+		}
+		if len(stale) > 0 {
+			if err := s.client.ZRem(ctx, hashIndexKey, stale...).Err(); err != nil {
+				return nil, err
+			}
+			offset -= int64(len(stale))
+		}
+	}
+	return hashes, nil
+}
+
+// similarFields - поля записи, которые читает FindSimilar.
+var similarFields = []string{
+	algorithmField, createdAtField, expiresAtField, deletedAtField,
+	macKeyField, macVersionField, simHashField,
+}
+
+/*
+FindSimilar читает части отпечатка из множеств индекса, отбрасывает хеши других префиксов и пространств и
+читает у оставшихся кандидатов одним конвейером HMGET только поля similarFields, без payload.
+*/
+func (s *RedisStore) FindSimilar(ctx context.Context, prefix string, fingerprint uint64, maxDistance int) ([]SimilarEntry, error) {
+	// Хеши-кандидаты и части отпечатка, под которыми они найдены
+	candidates := make(map[string][]string)
+	var hashes []string
+	for _, band := range simHashBands(fingerprint) {
+		members, err := s.client.SMembers(ctx, simHashIndexKey+band).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range members {
+			if !similarKey(hash, prefix) {
+				continue
+			}
+			if candidates[hash] == nil {
+				hashes = append(hashes, hash)
+			}
+			candidates[hash] = append(candidates[hash], band)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.SliceCmd, len(hashes))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, hash := range hashes {
+			cmds[i] = pipe.HMGet(ctx, hash, similarFields...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SimilarEntry
+	stale := s.client.Pipeline()
+	for i, hash := range hashes {
+		fields := make(map[string]string, len(similarFields))
+		for j, value := range cmds[i].Val() {
+			if value, ok := value.(string); ok {
+				fields[similarFields[j]] = value
+			}
+		}
+		var record *Record
+		if len(fields) > 0 {
+			record = parseRecord(fields)
+		}
+
+		// Убираем из индекса хеши удаленных, истекших и перезаписанных записей
+		for _, band := range candidates[hash] {
+			if record == nil || record.Expired(time.Now()) || !indexedUnder(record, band) {
+				stale.SRem(ctx, simHashIndexKey+band, hash)
+			}
+		}
+		if record == nil || record.Expired(time.Now()) || !similarTo(hash, prefix, record, fingerprint, maxDistance) {
+			continue
+		}
+
+		record.Payload = nil
+		entries = append(entries, SimilarEntry{Hash: hash, Record: record, Distance: HammingDistance(record.SimHash, fingerprint)})
+	}
+	if stale.Len() > 0 {
+		if _, err := stale.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// simHashKeys возвращает ключи множеств индекса отпечатков, в которые попадает запись с отпечатком fingerprint.
+func simHashKeys(fingerprint uint64) []string {
+	if fingerprint == 0 {
+		return nil
+	}
+	keys := simHashBands(fingerprint)
+	for i := range keys {
+		keys[i] = simHashIndexKey + keys[i]
+	}
+	return keys
+}
+
+// Count считает хеши по индексу за O(log n): надгробий в нем нет, а истекшие записи убирает PurgeExpired.
+func (s *RedisStore) Count(ctx context.Context, prefix string) (int64, error) {
+	return s.client.ZLexCount(ctx, hashIndexKey, "["+prefix, "["+prefix+"\xff").Result()
+}
+
+/*
+PurgeExpired убирает из индекса hashIndexKey хеши, записи которых Redis уже удалил по сроку жизни,
+чтобы они не учитывались в Count.
+*/
+func (s *RedisStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	const batchSize = 1000
+
+	purged := 0
+	for start := int64(0); ; {
+		hashes, err := s.client.ZRange(ctx, hashIndexKey, start, start+batchSize-1).Result()
+		if err != nil {
+			return purged, err
+		}
+		if len(hashes) == 0 {
+			return purged, nil
+		}
+
+		stale, err := s.missingKeys(ctx, hashes)
+		if err != nil {
+			return purged, err
+		}
+		if len(stale) > 0 {
+			if err := s.client.ZRem(ctx, hashIndexKey, stale...).Err(); err != nil {
+				return purged, err
+			}
+		}
+		purged += len(stale)
+		start += int64(len(hashes) - len(stale))
+	}
+}
+
+// missingKeys возвращает ключи, которых уже нет в Redis.
+func (s *RedisStore) missingKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	exists := make([]*redis.IntCmd, len(keys))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			exists[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []interface{}
+	for i, key := range keys {
+		if exists[i].Val() == 0 {
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
+
+func (s *RedisStore) CreateNamespace(ctx context.Context, namespace *Namespace) error {
+	data, err := json.Marshal(namespace)
+	if err != nil {
+		return err
+	}
+
+	created, err := s.client.SetNX(ctx, namespaceConfigKey+namespace.Name, data, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrNamespaceExists
+	}
+	return nil
+}
+
+func (s *RedisStore) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	data, err := s.client.Get(ctx, namespaceConfigKey+name).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var namespace Namespace
+	if err := json.Unmarshal(data, &namespace); err != nil {
+		return nil, err
+	}
+	return &namespace, nil
+}
+
+func (s *RedisStore) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	var names []string
+	iter := s.client.Scan(ctx, 0, namespaceConfigKey+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		names = append(names, strings.TrimPrefix(iter.Val(), namespaceConfigKey))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	namespaces := make([]*Namespace, 0, len(names))
+	for _, name := range names {
+		namespace, err := s.GetNamespace(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+func (s *RedisStore) DeleteNamespace(ctx context.Context, name string) error {
+	deleted, err := s.client.Del(ctx, namespaceConfigKey+name).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+/*
+SaveMACKey сравнивает версию и сохраняет ключ в транзакции с WATCH: если ключ изменился между чтением
+и записью, транзакция не выполняется и возвращается ErrKeyVersionConflict.
+*/
+func (s *RedisStore) SaveMACKey(ctx context.Context, key *MACKey, previousVersion int64) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	redisKey := macKeyConfigKey + key.Name
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+		existing, err := s.getMACKey(ctx, tx, redisKey)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := checkMACKeyVersion(existing, previousVersion); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, redisKey, data, 0)
+			return nil
+		})
+		return err
+	}, redisKey)
+	if err == redis.TxFailedErr {
+		return ErrKeyVersionConflict
+	}
+	return err
+}
+
+func (s *RedisStore) GetMACKey(ctx context.Context, name string) (*MACKey, error) {
+	return s.getMACKey(ctx, s.client, macKeyConfigKey+name)
+}
+
+// getMACKey читает ключ клиентом или транзакцией client. Для отсутствующего ключа возвращает nil и ErrNotFound.
+func (s *RedisStore) getMACKey(ctx context.Context, client redis.Cmdable, redisKey string) (*MACKey, error) {
+	data, err := client.Get(ctx, redisKey).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key MACKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *RedisStore) ListMACKeys(ctx context.Context) ([]*MACKey, error) {
+	var names []string
+	iter := s.client.Scan(ctx, 0, macKeyConfigKey+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		names = append(names, strings.TrimPrefix(iter.Val(), macKeyConfigKey))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	keys := make([]*MACKey, 0, len(names))
+	for _, name := range names {
+		key, err := s.GetMACKey(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// metadataFields - поля записи без payload, которые читает List.
+var metadataFields = []string{
+	contentTypeField, algorithmField, createdAtField, sizeField,
+	lastAccessField, readCountField, expiresAtField, deletedAtField,
+	referencesField, releasedAtField, codecField, keyIDField,
+	macKeyField, macVersionField, normalizeField, simHashField,
+}
+
+// listScanCount - подсказка COUNT для SCAN, когда размер страницы не ограничен.
+const listScanCount = 1000
+
+/*
+List обходит ключи через SCAN с MATCH по префиксу, курсор страницы - курсор SCAN. Поля записей читаются
+одним конвейером HMGET без payload, индекс hashIndexKey отсекается по типу ключа. SCAN не позволяет остановиться посреди пачки ключей, поэтому страница
+может содержать немного больше записей, чем Limit.
+*/
+func (s *RedisStore) List(ctx context.Context, query ListQuery) (*ListPage, error) {
+	var cursor uint64
+	if query.Cursor != "" {
+		var err error
+		cursor, err = strconv.ParseUint(query.Cursor, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidCursor, query.Cursor)
+		}
+	}
+
+	page := &ListPage{}
+	now := time.Now()
+	for {
+		count := int64(listScanCount)
+		if query.Limit > 0 {
+			count = int64(max(query.Limit-len(page.Entries), 1))
+		}
+		keys, next, err := s.client.ScanType(ctx, cursor, query.Prefix+"*", count, "hash").Result()
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := s.listEntries(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Record.Expired(now) && query.matches(entry.Hash, entry.Record) {
+				page.Entries = append(page.Entries, entry)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return page, nil
+		}
+		if query.limited(len(page.Entries)) {
+			page.NextCursor = strconv.FormatUint(cursor, 10)
+			return page, nil
+		}
+	}
+}
+
+// listEntries читает поля записей по ключам. Ключи, удаленные после SCAN, пропускаются.
+func (s *RedisStore) listEntries(ctx context.Context, keys []string) ([]ListEntry, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.SliceCmd, len(keys))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.HMGet(ctx, key, metadataFields...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ListEntry, 0, len(keys))
+	for i, cmd := range cmds {
+		fields := make(map[string]string, len(metadataFields))
+		for j, value := range cmd.Val() {
+			if value, ok := value.(string); ok {
+				fields[metadataFields[j]] = value
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		entries = append(entries, ListEntry{Hash: keys[i], Record: parseRecord(fields)})
+	}
+	return entries, nil
+}
+
+// unixNano переводит время в наносекунды; нулевое время сохраняется как 0.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// parseUnixNano разбирает время, сохраненное в наносекундах. Отсутствующее поле (например, у записей,
+// сохраненных до его появления) дает нулевое время.
+func parseUnixNano(value string) time.Time {
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
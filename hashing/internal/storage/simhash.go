@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+/*
+Индекс отпечатков SimHash для поиска похожих данных (см. HashStore.FindSimilar). 64-битный отпечаток
+делится на SimHashBands частей по 8 бит, и запись попадает в индекс под каждой из них. Если отпечатки
+отличаются не больше чем на SimHashBands-1 бит, то хотя бы одна часть у них совпадает (принцип Дирихле),
+поэтому для поиска достаточно собрать записи с совпадающими частями и посчитать расстояние только для них.
+Индекс не обновляется при удалении и перезаписи: лишние элементы отсеиваются проверкой отпечатка записи
+и убираются из индекса при поиске.
+*/
+
+// SimHashBands - число частей отпечатка в индексе.
+const SimHashBands = 8
+
+// MaxSimilarDistance - наибольшее расстояние Хэмминга, для которого поиск по индексу находит все записи.
+const MaxSimilarDistance = SimHashBands - 1
+
+// SimilarEntry - запись, найденная FindSimilar, и расстояние Хэмминга между отпечатками.
+type SimilarEntry struct {
+	Hash     string
+	Record   *Record
+	Distance int
+}
+
+// HammingDistance возвращает число различающихся битов отпечатков.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// simHashBands возвращает ключи частей отпечатка в индексе: номер части и ее значение.
+func simHashBands(fingerprint uint64) []string {
+	bands := make([]string, SimHashBands)
+	for i := range bands {
+		bands[i] = fmt.Sprintf("%d:%02x", i, byte(fingerprint>>(8*i)))
+	}
+	return bands
+}
+
+// similarKey сообщает, может ли ключ key попасть в результат FindSimilar с префиксом prefix.
+func similarKey(key, prefix string) bool {
+	if prefix == "" {
+		return !strings.HasPrefix(key, namespaceKeyPrefix)
+	}
+	return strings.HasPrefix(key, prefix)
+}
+
+// similarTo сообщает, подходит ли запись под запрос FindSimilar.
+func similarTo(key, prefix string, record *Record, fingerprint uint64, maxDistance int) bool {
+	return similarKey(key, prefix) && record.SimHash != 0 && !record.Deleted() &&
+		HammingDistance(record.SimHash, fingerprint) <= maxDistance
+}
+
+// indexedUnder сообщает, должна ли запись находиться в индексе под частью band.
+func indexedUnder(record *Record, band string) bool {
+	if record.SimHash == 0 || record.Deleted() {
+		return false
+	}
+	for _, b := range simHashBands(record.SimHash) {
+		if b == band {
+			return true
+		}
+	}
+	return false
+}
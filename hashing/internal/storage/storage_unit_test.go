@@ -0,0 +1,1048 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Все реализации HashStore должны вести себя одинаково, поэтому тесты ниже прогоняются для каждого
+хранилища. Redis подменяется на miniredis, чтобы тесты не требовали запущенного сервера.
+*/
+
+func newTestStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	redisStore := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	boltStore, err := OpenBoltStore(filepath.Join(t.TempDir(), "hashes.db"))
+	if err != nil {
+		t.Fatalf("failed to open bolt store: %v", err)
+	}
+
+	stores := map[string]Store{
+		BackendRedis:  redisStore,
+		BackendMemory: NewMemoryStore(),
+		BackendBolt:   boltStore,
+	}
+	t.Cleanup(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	})
+	return stores
+}
+
+/*
+Этот тест проверяет, что сохраненная запись читается обратно без изменений, а отсутствующий
+хеш возвращает ErrNotFound.
+*/
+func TestHashStoreSaveGet(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			record := &Record{Payload: []byte("test"), Algorithm: "sha256", Normalization: "nfc,trim", CreatedAt: time.Unix(1700000000, 0), Size: 4}
+
+			err := store.Save(ctx, "hash", record)
+			assert.NoError(t, err)
+
+			got, err := store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Equal(t, record.Payload, got.Payload)
+			assert.Equal(t, record.Algorithm, got.Algorithm)
+			assert.Equal(t, record.Normalization, got.Normalization)
+			assert.True(t, record.CreatedAt.Equal(got.CreatedAt))
+			assert.Equal(t, record.Size, got.Size)
+			assert.True(t, got.PayloadStored())
+
+			_, err = store.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что произвольные бинарные данные (нулевые байты, невалидный UTF-8) сохраняются
+во всех хранилищах без искажений вместе с типом содержимого.
+*/
+func TestHashStoreBinaryPayload(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe, 0x0d, 0x0a, 0x1a, 0x00}
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			record := &Record{Payload: payload, ContentType: "image/png", Algorithm: "sha256", Size: int64(len(payload))}
+			assert.NoError(t, store.Save(ctx, "binary", record))
+
+			got, err := store.Get(ctx, "binary")
+			assert.NoError(t, err)
+			assert.Equal(t, payload, got.Payload)
+			assert.Equal(t, "image/png", got.ContentType)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что RecordAccess увеличивает счетчик чтений и запоминает время последнего чтения,
+а для отсутствующей записи возвращает ErrNotFound и не создает ее.
+*/
+func TestHashStoreRecordAccess(t *testing.T) {
+	ctx := context.Background()
+	firstRead := time.Unix(1700000100, 0)
+	secondRead := time.Unix(1700000200, 0)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			record := &Record{Payload: []byte("test"), Algorithm: "sha256", CreatedAt: time.Unix(1700000000, 0), Size: 4}
+			assert.NoError(t, store.Save(ctx, "hash", record))
+
+			got, err := store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Zero(t, got.ReadCount)
+			assert.True(t, got.LastAccessAt.IsZero())
+
+			assert.NoError(t, store.RecordAccess(ctx, "hash", firstRead))
+			assert.NoError(t, store.RecordAccess(ctx, "hash", secondRead))
+
+			got, err = store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), got.ReadCount)
+			assert.True(t, secondRead.Equal(got.LastAccessAt))
+			assert.Equal(t, []byte("test"), got.Payload)
+
+			err = store.RecordAccess(ctx, "missing", firstRead)
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = store.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что во всех хранилищах запись с истекшим сроком жизни ведет себя как отсутствующая,
+а SetExpiration продлевает срок, делает запись бессрочной или сразу завершает ее жизнь.
+*/
+func TestHashStoreExpiration(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			expired := &Record{Payload: []byte("old"), Algorithm: "sha256", Size: 3, ExpiresAt: time.Now().Add(-time.Second)}
+			assert.NoError(t, store.Save(ctx, "expired", expired))
+
+			_, err := store.Get(ctx, "expired")
+			assert.ErrorIs(t, err, ErrNotFound)
+			assert.ErrorIs(t, store.RecordAccess(ctx, "expired", time.Now()), ErrNotFound)
+			assert.ErrorIs(t, store.SetExpiration(ctx, "expired", time.Time{}), ErrNotFound)
+
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+			live := &Record{Payload: []byte("test"), Algorithm: "sha256", Size: 4, ExpiresAt: expiresAt}
+			assert.NoError(t, store.Save(ctx, "live", live))
+
+			got, err := store.Get(ctx, "live")
+			assert.NoError(t, err)
+			assert.True(t, expiresAt.Equal(got.ExpiresAt))
+
+			// Нулевое время делает запись бессрочной
+			assert.NoError(t, store.SetExpiration(ctx, "live", time.Time{}))
+			got, err = store.Get(ctx, "live")
+			assert.NoError(t, err)
+			assert.True(t, got.ExpiresAt.IsZero())
+
+			// Срок в прошлом завершает жизнь записи
+			assert.NoError(t, store.SetExpiration(ctx, "live", time.Now().Add(-time.Second)))
+			_, err = store.Get(ctx, "live")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что Delete удаляет запись, а надгробие (запись с DeletedAt) сохраняется и читается
+во всех хранилищах как удаленная запись.
+*/
+func TestHashStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Unix(1700000300, 0)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.Save(ctx, "hash", &Record{Payload: []byte("test"), Algorithm: "sha256", Size: 4}))
+
+			assert.NoError(t, store.Delete(ctx, "hash"))
+			_, err := store.Get(ctx, "hash")
+			assert.ErrorIs(t, err, ErrNotFound)
+			assert.ErrorIs(t, store.Delete(ctx, "hash"), ErrNotFound)
+
+			assert.NoError(t, store.Save(ctx, "tombstone", &Record{Algorithm: "sha256", DeletedAt: deletedAt}))
+			got, err := store.Get(ctx, "tombstone")
+			assert.NoError(t, err)
+			assert.True(t, got.Deleted())
+			assert.True(t, deletedAt.Equal(got.DeletedAt))
+			assert.Empty(t, got.Payload)
+		})
+	}
+}
+
+/*
+Этот тест проверяет атомарное создание: Create сохраняет новую запись и запись поверх надгробия или
+истекшей записи, а существующую не перезаписывает и возвращает ее. Из параллельных Create одного хеша
+запись создает ровно один.
+*/
+func TestHashStoreCreate(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Unix(1700000000, 0)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := &Record{Payload: []byte("first"), ContentType: "text/plain", Algorithm: "sha256", CreatedAt: createdAt, Size: 5}
+			existing, created, err := store.Create(ctx, "hash", first)
+			assert.NoError(t, err)
+			assert.True(t, created)
+			assert.Nil(t, existing)
+
+			existing, created, err = store.Create(ctx, "hash", &Record{Payload: []byte("second"), Algorithm: "sha256", Size: 6})
+			assert.NoError(t, err)
+			assert.False(t, created)
+			assert.Equal(t, []byte("first"), existing.Payload)
+			assert.Equal(t, "text/plain", existing.ContentType)
+			assert.True(t, createdAt.Equal(existing.CreatedAt))
+
+			got, err := store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("first"), got.Payload)
+
+			// Надгробие заменяется новой записью
+			assert.NoError(t, store.Save(ctx, "tombstone", &Record{Algorithm: "sha256", DeletedAt: createdAt}))
+			_, created, err = store.Create(ctx, "tombstone", &Record{Payload: []byte("restored"), Algorithm: "sha256", Size: 8})
+			assert.NoError(t, err)
+			assert.True(t, created)
+			got, err = store.Get(ctx, "tombstone")
+			assert.NoError(t, err)
+			assert.False(t, got.Deleted())
+			assert.Equal(t, []byte("restored"), got.Payload)
+
+			// Истекшая запись считается отсутствующей
+			assert.NoError(t, store.Save(ctx, "expired", &Record{Payload: []byte("old"), Algorithm: "sha256", ExpiresAt: time.Now().Add(-time.Minute)}))
+			_, created, err = store.Create(ctx, "expired", &Record{Payload: []byte("new"), Algorithm: "sha256", ExpiresAt: time.Now().Add(time.Hour)})
+			assert.NoError(t, err)
+			assert.True(t, created)
+
+			hashes, err := store.FindByPrefix(ctx, "tomb", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"tombstone"}, hashes)
+
+			var wg sync.WaitGroup
+			var createdCount atomic.Int32
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, created, err := store.Create(ctx, "concurrent", &Record{Payload: []byte("test"), Algorithm: "sha256", Size: 4})
+					assert.NoError(t, err)
+					if created {
+						createdCount.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), createdCount.Load())
+		})
+	}
+}
+
+/*
+Этот тест проверяет счетчики ссылок: AddReference и ReleaseReference ведут счет по владельцам, после
+освобождения последней ссылки запоминается время, а DeleteUnreferenced удаляет запись только без ссылок
+и только после заданного момента.
+*/
+func TestHashStoreReferences(t *testing.T) {
+	ctx := context.Background()
+	releasedAt := time.Unix(1700000500, 0)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, created, err := store.Create(ctx, "hash", &Record{
+				Payload: []byte("test"), Algorithm: "sha256", Size: 4,
+				Owners: map[string]int64{"billing": 1}, References: 1,
+			})
+			assert.NoError(t, err)
+			assert.True(t, created)
+
+			references, err := store.AddReference(ctx, "hash", "reports")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), references)
+			references, err = store.AddReference(ctx, "hash", "billing")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), references)
+
+			got, err := store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]int64{"billing": 2, "reports": 1}, got.Owners)
+			assert.Equal(t, int64(3), got.References)
+
+			_, err = store.ReleaseReference(ctx, "hash", "unknown", releasedAt)
+			assert.ErrorIs(t, err, ErrNoReference)
+			_, err = store.AddReference(ctx, "missing", "billing")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			for _, owner := range []string{"billing", "reports", "billing"} {
+				deleted, err := store.DeleteUnreferenced(ctx, "hash", releasedAt.Add(time.Hour))
+				assert.NoError(t, err)
+				assert.False(t, deleted)
+
+				_, err = store.ReleaseReference(ctx, "hash", owner, releasedAt)
+				assert.NoError(t, err)
+			}
+
+			got, err = store.Get(ctx, "hash")
+			assert.NoError(t, err)
+			assert.Empty(t, got.Owners)
+			assert.Zero(t, got.References)
+			assert.True(t, releasedAt.Equal(got.ReleasedAt))
+
+			// Время освобождения попадает и в List
+			page, err := store.List(ctx, ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			if assert.Len(t, page.Entries, 1) {
+				assert.True(t, releasedAt.Equal(page.Entries[0].Record.ReleasedAt))
+			}
+
+			// Льготный период еще не прошел
+			deleted, err := store.DeleteUnreferenced(ctx, "hash", releasedAt.Add(-time.Second))
+			assert.NoError(t, err)
+			assert.False(t, deleted)
+
+			deleted, err = store.DeleteUnreferenced(ctx, "hash", releasedAt.Add(time.Second))
+			assert.NoError(t, err)
+			assert.True(t, deleted)
+			_, err = store.Get(ctx, "hash")
+			assert.ErrorIs(t, err, ErrNotFound)
+			hashes, err := store.FindByPrefix(ctx, "hash", 10)
+			assert.NoError(t, err)
+			assert.Empty(t, hashes)
+
+			// Запись без ссылок, на которую их никогда не брали, не удаляется
+			assert.NoError(t, store.Save(ctx, "unmanaged", &Record{Payload: []byte("test"), Algorithm: "sha256", Size: 4}))
+			deleted, err = store.DeleteUnreferenced(ctx, "unmanaged", releasedAt.Add(time.Hour))
+			assert.NoError(t, err)
+			assert.False(t, deleted)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что постраничный обход List возвращает каждую подходящую запись ровно один раз,
+учитывает префикс и фильтр, пропускает записи с истекшим сроком, не читает исходные данные, но читает
+остальные поля записи, а без ограничения размера страницы возвращает все записи сразу.
+*/
+func TestHashStoreList(t *testing.T) {
+	ctx := context.Background()
+	hashes := []string{"aa01", "aa02", "aa03", "ab01", "ab02", "b001", "b002"}
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, hash := range hashes {
+				algorithm := "sha256"
+				if hash == "ab02" {
+					algorithm = "sha512"
+				}
+				assert.NoError(t, store.Save(ctx, hash, &Record{Payload: []byte("test"), Algorithm: algorithm, Size: 4, Normalization: "crlf,trim", SimHash: 0xfeed}))
+			}
+			assert.NoError(t, store.Save(ctx, "aa04", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(-time.Second)}))
+
+			listAll := func(query ListQuery) []string {
+				var listed []string
+				for pages := 0; pages < 100; pages++ {
+					page, err := store.List(ctx, query)
+					assert.NoError(t, err)
+					for _, entry := range page.Entries {
+						listed = append(listed, entry.Hash)
+						assert.Empty(t, entry.Record.Payload)
+						assert.Equal(t, int64(4), entry.Record.Size)
+						assert.Equal(t, "crlf,trim", entry.Record.Normalization)
+						assert.Equal(t, uint64(0xfeed), entry.Record.SimHash)
+					}
+					if page.NextCursor == "" {
+						return listed
+					}
+					query.Cursor = page.NextCursor
+				}
+				t.Fatal("listing did not finish")
+				return nil
+			}
+
+			assert.ElementsMatch(t, hashes, listAll(ListQuery{Limit: 2}))
+			assert.ElementsMatch(t, []string{"aa01", "aa02", "aa03"}, listAll(ListQuery{Limit: 2, Prefix: "aa"}))
+			assert.ElementsMatch(t, []string{"ab01"}, listAll(ListQuery{Limit: 1, Prefix: "ab", Filter: func(hash string, record *Record) bool {
+				return record.Algorithm == "sha256"
+			}}))
+			assert.Empty(t, listAll(ListQuery{Limit: 2, Prefix: "c"}))
+
+			// Без ограничения все записи возвращаются одной страницей
+			for _, limit := range []int{0, -1} {
+				page, err := store.List(ctx, ListQuery{Limit: limit})
+				assert.NoError(t, err)
+				assert.Empty(t, page.NextCursor)
+				listed := make([]string, 0, len(page.Entries))
+				for _, entry := range page.Entries {
+					listed = append(listed, entry.Hash)
+				}
+				assert.ElementsMatch(t, hashes, listed)
+			}
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что Count учитывает только действующие записи с префиксом: надгробия и записи
+с истекшим сроком жизни после очистки не считаются.
+*/
+func TestHashStoreCount(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.Save(ctx, "aa01", &Record{Payload: []byte("test"), Algorithm: "sha256"}))
+			assert.NoError(t, store.Save(ctx, "aa02", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(time.Hour)}))
+			assert.NoError(t, store.Save(ctx, "aa03", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(-time.Second)}))
+			assert.NoError(t, store.Save(ctx, "aa04", &Record{Algorithm: "sha256", DeletedAt: time.Now()}))
+			assert.NoError(t, store.Save(ctx, "b001", &Record{Payload: []byte("test"), Algorithm: "sha256"}))
+			if purger, ok := store.(ExpiredPurger); ok {
+				_, err := purger.PurgeExpired(ctx, time.Now())
+				assert.NoError(t, err)
+			}
+
+			for prefix, expected := range map[string]int64{"": 3, "aa": 2, "b": 1, "c": 0} {
+				count, err := store.Count(ctx, prefix)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, count, prefix)
+			}
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что записи первых версий сервиса (строковые ключи SET <sha256> <payload>) читаются
+через Get и Create и переводятся в Redis hash, а MigrateLegacy переводит остальные и они появляются в List.
+*/
+func TestRedisStoreLegacyRecords(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer store.Close()
+
+	first, second, third := strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)
+	for _, hash := range []string{first, second, third} {
+		assert.NoError(t, mr.Set(hash, "legacy "+hash[:1]))
+	}
+	assert.NoError(t, mr.Set(macKeyConfigKey+"emails", "{}"))
+
+	got, err := store.Get(ctx, first)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy a", string(got.Payload))
+	assert.Equal(t, legacyAlgorithm, got.Algorithm)
+	assert.Equal(t, int64(8), got.Size)
+
+	existing, created, err := store.Create(ctx, second, &Record{Payload: []byte("legacy b"), Algorithm: legacyAlgorithm, Size: 8})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "legacy b", string(existing.Payload))
+
+	migrated, err := store.MigrateLegacy(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+	value, err := mr.Get(macKeyConfigKey + "emails")
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", value)
+
+	page, err := store.List(ctx, ListQuery{})
+	assert.NoError(t, err)
+	listed := make([]string, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		listed = append(listed, entry.Hash)
+	}
+	assert.ElementsMatch(t, []string{first, second, third}, listed)
+	count, err := store.Count(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+/*
+Этот тест проверяет поиск хешей по префиксу: результат упорядочен, ограничен limit и не содержит
+удаленных и истекших записей и надгробий.
+*/
+func TestHashStoreFindByPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, hash := range []string{"ab0002", "aa0002", "aa0001", "ab0001"} {
+				assert.NoError(t, store.Save(ctx, hash, &Record{Algorithm: "sha256"}))
+			}
+			assert.NoError(t, store.Save(ctx, "aa0000", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(-time.Second)}))
+			assert.NoError(t, store.Save(ctx, "aa0003", &Record{Algorithm: "sha256", DeletedAt: time.Now()}))
+
+			hashes, err := store.FindByPrefix(ctx, "aa", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"aa0001", "aa0002"}, hashes)
+
+			hashes, err = store.FindByPrefix(ctx, "a", 3)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"aa0001", "aa0002", "ab0001"}, hashes)
+
+			assert.NoError(t, store.Delete(ctx, "aa0001"))
+			hashes, err = store.FindByPrefix(ctx, "aa", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"aa0002"}, hashes)
+
+			hashes, err = store.FindByPrefix(ctx, "c", 10)
+			assert.NoError(t, err)
+			assert.Empty(t, hashes)
+		})
+	}
+}
+
+/*
+Этот тест проверяет поиск по индексу отпечатков SimHash: находятся записи на расстоянии не больше
+заданного, но не записи без отпечатка, надгробия, удаленные, истекшие и перезаписанные записи
+и записи других пространств имен. У найденных записей заполнены алгоритм и ключ хеширования.
+*/
+func TestHashStoreFindSimilar(t *testing.T) {
+	ctx := context.Background()
+	const fingerprint = uint64(0x0123456789abcdef)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			similar := func(store HashStore, maxDistance int) map[string]int {
+				entries, err := store.FindSimilar(ctx, "", fingerprint, maxDistance)
+				assert.NoError(t, err)
+				distances := make(map[string]int)
+				for _, entry := range entries {
+					assert.Nil(t, entry.Record.Payload)
+					distances[entry.Hash] = entry.Distance
+				}
+				return distances
+			}
+
+			assert.NoError(t, store.Save(ctx, "aa01", &Record{Payload: []byte("a"), Algorithm: "hmac-sha256", MACKey: "k1", MACKeyVersion: 2, SimHash: fingerprint}))
+			assert.NoError(t, store.Save(ctx, "aa02", &Record{Algorithm: "sha256", SimHash: fingerprint ^ 0x0101010000000000}))
+			assert.NoError(t, store.Save(ctx, "aa03", &Record{Algorithm: "sha256", SimHash: ^fingerprint}))
+			assert.NoError(t, store.Save(ctx, "aa04", &Record{Algorithm: "sha256"}))
+			_, created, err := store.Create(ctx, "aa05", &Record{Algorithm: "sha256", SimHash: fingerprint ^ 1})
+			assert.NoError(t, err)
+			assert.True(t, created)
+			assert.NoError(t, store.Save(ctx, "aa06", &Record{Algorithm: "sha256", SimHash: fingerprint, ExpiresAt: time.Now().Add(-time.Second)}))
+			assert.NoError(t, store.Save(ctx, "aa07", &Record{Algorithm: "sha256", SimHash: fingerprint, DeletedAt: time.Now()}))
+			assert.NoError(t, InNamespace(store, "team-a").Save(ctx, "aa08", &Record{Algorithm: "sha256", SimHash: fingerprint}))
+
+			got, err := store.Get(ctx, "aa01")
+			assert.NoError(t, err)
+			assert.Equal(t, fingerprint, got.SimHash)
+
+			defaultStore := InNamespace(store, DefaultNamespace)
+			assert.Equal(t, map[string]int{"aa01": 0, "aa02": 3, "aa05": 1}, similar(defaultStore, 3))
+			assert.Equal(t, map[string]int{"aa01": 0, "aa05": 1}, similar(defaultStore, 2))
+			assert.Equal(t, map[string]int{"aa08": 0}, similar(InNamespace(store, "team-a"), MaxSimilarDistance))
+			assert.Equal(t, map[string]int{"aa01": 0, "aa02": 3, "aa05": 1}, similar(store, 3))
+
+			entries, err := store.FindSimilar(ctx, "aa01", fingerprint, 0)
+			assert.NoError(t, err)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "hmac-sha256", entries[0].Record.Algorithm)
+				assert.Equal(t, "k1", entries[0].Record.MACKey)
+				assert.Equal(t, int64(2), entries[0].Record.MACKeyVersion)
+			}
+
+			assert.NoError(t, store.Delete(ctx, "aa01"))
+			assert.NoError(t, store.Save(ctx, "aa02", &Record{Algorithm: "sha256", SimHash: ^fingerprint}))
+			assert.Equal(t, map[string]int{"aa05": 1}, similar(defaultStore, 3))
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что записи разных пространств имен не видны друг другу ни при чтении, ни в списке,
+ни при поиске по префиксу и подсчете, а записи пространства по умолчанию хранятся под самим хешем.
+*/
+func TestInNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			defaultStore := InNamespace(store, DefaultNamespace)
+			teamA := InNamespace(store, "team-a")
+			teamB := InNamespace(store, "team-b")
+
+			assert.NoError(t, defaultStore.Save(ctx, "aa01", &Record{Payload: []byte("default"), Algorithm: "sha256"}))
+			assert.NoError(t, teamA.Save(ctx, "aa01", &Record{Payload: []byte("a"), Algorithm: "sha256"}))
+			assert.NoError(t, teamA.Save(ctx, "aa02", &Record{Payload: []byte("a"), Algorithm: "sha256"}))
+
+			// Пространство по умолчанию совместимо с записями без пространств
+			got, err := store.Get(ctx, "aa01")
+			assert.NoError(t, err)
+			assert.Equal(t, "default", string(got.Payload))
+
+			got, err = teamA.Get(ctx, "aa01")
+			assert.NoError(t, err)
+			assert.Equal(t, "a", string(got.Payload))
+			_, err = teamB.Get(ctx, "aa01")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			page, err := defaultStore.List(ctx, ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			if assert.Len(t, page.Entries, 1) {
+				assert.Equal(t, "aa01", page.Entries[0].Hash)
+			}
+			page, err = teamA.List(ctx, ListQuery{Limit: 10, Prefix: "aa"})
+			assert.NoError(t, err)
+			assert.Len(t, page.Entries, 2)
+
+			hashes, err := teamA.FindByPrefix(ctx, "aa", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"aa01", "aa02"}, hashes)
+			hashes, err = teamB.FindByPrefix(ctx, "aa", 10)
+			assert.NoError(t, err)
+			assert.Empty(t, hashes)
+
+			for namespace, expected := range map[HashStore]int64{defaultStore: 1, teamA: 2, teamB: 0} {
+				count, err := namespace.Count(ctx, "")
+				assert.NoError(t, err)
+				assert.Equal(t, expected, count)
+			}
+		})
+	}
+}
+
+/*
+Этот тест проверяет хранение описаний пространств имен во всех хранилищах.
+*/
+func TestNamespaceStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			createdAt := time.Unix(1700000000, 0)
+			assert.NoError(t, store.CreateNamespace(ctx, &Namespace{Name: "team-b", CreatedAt: createdAt, MaxHashes: 10}))
+			assert.NoError(t, store.CreateNamespace(ctx, &Namespace{Name: "team-a", MaxPayloadSize: 1024}))
+			assert.ErrorIs(t, store.CreateNamespace(ctx, &Namespace{Name: "team-a"}), ErrNamespaceExists)
+
+			namespace, err := store.GetNamespace(ctx, "team-b")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(10), namespace.MaxHashes)
+			assert.True(t, createdAt.Equal(namespace.CreatedAt))
+
+			namespaces, err := store.ListNamespaces(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, namespaces, 2) {
+				assert.Equal(t, "team-a", namespaces[0].Name)
+				assert.Equal(t, int64(1024), namespaces[0].MaxPayloadSize)
+				assert.Equal(t, "team-b", namespaces[1].Name)
+			}
+
+			assert.NoError(t, store.DeleteNamespace(ctx, "team-b"))
+			assert.ErrorIs(t, store.DeleteNamespace(ctx, "team-b"), ErrNotFound)
+			_, err = store.GetNamespace(ctx, "team-b")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// Описания пространств не попадают в список записей
+			page, err := InNamespace(store, DefaultNamespace).List(ctx, ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, page.Entries)
+		})
+	}
+}
+
+/*
+Этот тест проверяет хранение ключей хеширования во всех хранилищах: создание, смену версии только
+поверх прочитанной версии и то, что ключи не попадают в список записей.
+*/
+func TestMACKeyStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			v1 := MACKeyVersion{Version: 1, Secret: []byte("secret-1"), CreatedAt: time.Unix(1700000000, 0)}
+			key := &MACKey{Name: "emails", CreatedAt: v1.CreatedAt, Versions: []MACKeyVersion{v1}}
+			assert.NoError(t, store.SaveMACKey(ctx, key, 0))
+			assert.ErrorIs(t, store.SaveMACKey(ctx, key, 0), ErrKeyExists)
+			assert.ErrorIs(t, store.SaveMACKey(ctx, &MACKey{Name: "phones"}, 1), ErrNotFound)
+
+			rotated := &MACKey{Name: "emails", CreatedAt: key.CreatedAt, Versions: []MACKeyVersion{v1, {Version: 2, Secret: []byte("secret-2")}}}
+			assert.NoError(t, store.SaveMACKey(ctx, rotated, 1))
+			// Ключ уже сменили, повторная смена поверх версии 1 - конфликт
+			assert.ErrorIs(t, store.SaveMACKey(ctx, rotated, 1), ErrKeyVersionConflict)
+
+			got, err := store.GetMACKey(ctx, "emails")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), got.CurrentVersion())
+			version, ok := got.Version(1)
+			assert.True(t, ok)
+			assert.Equal(t, []byte("secret-1"), version.Secret)
+			assert.True(t, v1.CreatedAt.Equal(version.CreatedAt))
+			_, ok = got.Version(3)
+			assert.False(t, ok)
+
+			_, err = store.GetMACKey(ctx, "phones")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.NoError(t, store.SaveMACKey(ctx, &MACKey{Name: "cards", Versions: []MACKeyVersion{{Version: 1}}}, 0))
+			keys, err := store.ListMACKeys(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, keys, 2) {
+				assert.Equal(t, "cards", keys[0].Name)
+				assert.Equal(t, "emails", keys[1].Name)
+			}
+
+			page, err := InNamespace(store, DefaultNamespace).List(ctx, ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, page.Entries)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что PurgeExpired удаляет из хранилищ в памяти и bbolt только записи с истекшим сроком.
+*/
+func TestPurgeExpired(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		purger, ok := store.(ExpiredPurger)
+		if !ok {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.Save(ctx, "expired", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(-time.Second)}))
+			assert.NoError(t, store.Save(ctx, "live", &Record{Algorithm: "sha256", ExpiresAt: time.Now().Add(time.Hour)}))
+			assert.NoError(t, store.Save(ctx, "forever", &Record{Algorithm: "sha256"}))
+
+			purged, err := purger.PurgeExpired(ctx, time.Now())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			_, err = store.Get(ctx, "live")
+			assert.NoError(t, err)
+			_, err = store.Get(ctx, "forever")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что изменение записи после Save или Get не меняет данные внутри хранилища в памяти.
+*/
+func TestMemoryStoreCopiesRecords(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	record := &Record{Payload: []byte("test"), Algorithm: "sha256"}
+	assert.NoError(t, store.Save(ctx, "hash", record))
+	record.Payload[0] = 'X'
+
+	got, err := store.Get(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", string(got.Payload))
+
+	got.Payload[0] = 'Y'
+	got, err = store.Get(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", string(got.Payload))
+}
+
+/*
+Этот тест проверяет, что Compress сжимает данные не меньше порога, сохраняет кодек в записи и читает
+обратно исходные данные, а записи, сохраненные без сжатия, читаются как раньше.
+*/
+func TestCompress(t *testing.T) {
+	ctx := context.Background()
+	large := []byte(strings.Repeat("compressible text ", 100))
+
+	for name, store := range newTestStores(t) {
+		for _, codec := range []string{CodecGzip, CodecZstd} {
+			t.Run(name+"/"+codec, func(t *testing.T) {
+				compressed, err := Compress(store, codec, 64)
+				assert.NoError(t, err)
+
+				// Старая запись без сжатия
+				err = store.Save(ctx, "old-"+codec, &Record{Payload: large, Size: int64(len(large))})
+				assert.NoError(t, err)
+
+				_, created, err := compressed.Create(ctx, "large-"+codec, &Record{Payload: large, Size: int64(len(large))})
+				assert.NoError(t, err)
+				assert.True(t, created)
+				err = compressed.Save(ctx, "small-"+codec, &Record{Payload: []byte("test"), Size: 4})
+				assert.NoError(t, err)
+
+				raw, err := store.Get(ctx, "large-"+codec)
+				assert.NoError(t, err)
+				assert.Equal(t, codec, raw.Codec)
+				assert.Less(t, len(raw.Payload), len(large))
+
+				raw, err = store.Get(ctx, "small-"+codec)
+				assert.NoError(t, err)
+				assert.Equal(t, CodecNone, raw.Codec)
+
+				for _, hash := range []string{"old-", "large-"} {
+					got, err := compressed.Get(ctx, hash+codec)
+					assert.NoError(t, err)
+					assert.Equal(t, large, got.Payload)
+					assert.True(t, got.PayloadStored())
+				}
+
+				// Существующая запись из Create тоже возвращается распакованной
+				existing, created, err := compressed.Create(ctx, "large-"+codec, &Record{Payload: large, Size: int64(len(large))})
+				assert.NoError(t, err)
+				assert.False(t, created)
+				assert.Equal(t, large, existing.Payload)
+			})
+		}
+	}
+
+	_, err := Compress(NewMemoryStore(), "lz4", 0)
+	assert.Error(t, err)
+}
+
+/*
+Этот тест проверяет, что Recompress сжимает старые записи, пересжимает записи другим кодеком,
+не трогает надгробия и не меняет данные, которые читает сервис.
+*/
+func TestRecompress(t *testing.T) {
+	ctx := context.Background()
+	large := []byte(strings.Repeat("compressible text ", 100))
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			gzipped, err := Compress(store, CodecGzip, 64)
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.Save(ctx, "a", &Record{Payload: large, Size: int64(len(large))}))
+			assert.NoError(t, gzipped.Save(ctx, "b", &Record{Payload: large, Size: int64(len(large))}))
+			assert.NoError(t, store.Save(ctx, "c", &Record{Payload: []byte("test"), Size: 4}))
+			assert.NoError(t, store.Save(ctx, "d", &Record{Size: 4, DeletedAt: time.Now()}))
+
+			// Страницы по одной записи, чтобы обход продолжался после pause
+			stats, err := Recompress(ctx, store, CodecZstd, 64, 1, func(RecompressStats) error { return nil })
+			assert.NoError(t, err)
+			assert.Equal(t, 3, stats.Scanned)
+			assert.Equal(t, 2, stats.Recompressed)
+			assert.Less(t, stats.BytesAfter, stats.BytesBefore)
+
+			zstd, err := Compress(store, CodecZstd, 64)
+			assert.NoError(t, err)
+			for _, hash := range []string{"a", "b"} {
+				raw, err := store.Get(ctx, hash)
+				assert.NoError(t, err)
+				assert.Equal(t, CodecZstd, raw.Codec)
+
+				got, err := zstd.Get(ctx, hash)
+				assert.NoError(t, err)
+				assert.Equal(t, large, got.Payload)
+			}
+
+			// Повторный запуск ничего не меняет
+			stats, err = Recompress(ctx, store, CodecZstd, 64, 100, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, stats.Recompressed)
+		})
+	}
+}
+
+// testKeyring создает Keyring из ключей с заданными идентификаторами и основным ключом primary.
+func testKeyring(t *testing.T, primary string, ids ...string) *Keyring {
+	t.Helper()
+
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	keyring, err := NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	return keyring
+}
+
+/*
+Этот тест проверяет, что Encrypt хранит данные зашифрованными вместе с идентификатором ключа, читает
+обратно исходные данные, читает записи, сохраненные без шифрования, и не расшифровывает данные,
+переставленные в другую запись.
+*/
+func TestEncrypt(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte(strings.Repeat("secret ", 200))
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			encrypted := Encrypt(store, testKeyring(t, "k1", "k1"))
+
+			assert.NoError(t, store.Save(ctx, "plain", &Record{Payload: payload, Size: int64(len(payload))}))
+			_, created, err := encrypted.Create(ctx, "a", &Record{Payload: payload, Size: int64(len(payload))})
+			assert.NoError(t, err)
+			assert.True(t, created)
+			assert.NoError(t, encrypted.Save(ctx, "b", &Record{Payload: payload, Size: int64(len(payload))}))
+
+			raw, err := store.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, "k1", raw.KeyID)
+			assert.NotEmpty(t, raw.DataKey)
+			assert.NotContains(t, string(raw.Payload), "secret")
+
+			for _, hash := range []string{"plain", "a", "b"} {
+				got, err := encrypted.Get(ctx, hash)
+				assert.NoError(t, err)
+				assert.Equal(t, payload, got.Payload)
+			}
+
+			existing, created, err := encrypted.Create(ctx, "a", &Record{Payload: payload, Size: int64(len(payload))})
+			assert.NoError(t, err)
+			assert.False(t, created)
+			assert.Equal(t, payload, existing.Payload)
+
+			// Сжатие поверх шифрования
+			compressed, err := Compress(encrypted, CodecZstd, 64)
+			assert.NoError(t, err)
+			assert.NoError(t, compressed.Save(ctx, "c", &Record{Payload: payload, Size: int64(len(payload))}))
+			got, err := compressed.Get(ctx, "c")
+			assert.NoError(t, err)
+			assert.Equal(t, payload, got.Payload)
+
+			// Данные записи a, переставленные в запись b, не расшифровываются
+			assert.NoError(t, store.Save(ctx, "b", raw))
+			_, err = encrypted.Get(ctx, "b")
+			assert.Error(t, err)
+
+			_, err = Encrypt(store, testKeyring(t, "k2", "k2")).Get(ctx, "a")
+			assert.ErrorIs(t, err, ErrUnknownKey)
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что Encrypt хранит секреты ключей хеширования зашифрованными, а ReencryptMACKeys
+после смены основного ключа шифрования перешифровывает их, не меняя сами секреты.
+*/
+func TestEncryptMACKeys(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("hmac-secret")
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			encrypted := Encrypt(store, testKeyring(t, "k1", "k1"))
+			key := &MACKey{Name: "emails", Versions: []MACKeyVersion{{Version: 1, Secret: secret}}}
+			assert.NoError(t, encrypted.SaveMACKey(ctx, key, 0))
+
+			raw, err := store.GetMACKey(ctx, "emails")
+			assert.NoError(t, err)
+			assert.Equal(t, "k1", raw.Versions[0].KeyID)
+			assert.NotEqual(t, secret, raw.Versions[0].Secret)
+
+			got, err := encrypted.GetMACKey(ctx, "emails")
+			assert.NoError(t, err)
+			assert.Equal(t, secret, got.Versions[0].Secret)
+
+			keyring := testKeyring(t, "k2", "k1", "k2")
+			updated, err := ReencryptMACKeys(ctx, store, keyring)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, updated)
+
+			raw, err = store.GetMACKey(ctx, "emails")
+			assert.NoError(t, err)
+			assert.Equal(t, "k2", raw.Versions[0].KeyID)
+
+			// Старый ключ шифрования больше не нужен
+			keys, err := Encrypt(store, testKeyring(t, "k2", "k0", "k2")).ListMACKeys(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, keys, 1) {
+				assert.Equal(t, secret, keys[0].Versions[0].Secret)
+			}
+		})
+	}
+}
+
+/*
+Этот тест проверяет, что после смены основного ключа Reencrypt переводит на него все записи, включая
+сохраненные без шифрования, и после этого старый ключ можно убрать из Keyring.
+*/
+func TestReencrypt(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte("secret")
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			old := Encrypt(store, testKeyring(t, "k1", "k1"))
+			assert.NoError(t, old.Save(ctx, "a", &Record{Payload: payload, Size: 6}))
+			assert.NoError(t, old.Save(ctx, "b", &Record{Payload: payload, Size: 6}))
+			assert.NoError(t, store.Save(ctx, "c", &Record{Payload: payload, Size: 6}))
+			assert.NoError(t, store.Save(ctx, "d", &Record{Size: 6, DeletedAt: time.Now()}))
+
+			rotated := testKeyring(t, "k2", "k1", "k2")
+			stats, err := Reencrypt(ctx, store, rotated, 1, func(ReencryptStats) error { return nil })
+			assert.NoError(t, err)
+			assert.Equal(t, ReencryptStats{Scanned: 3, Reencrypted: 2, Encrypted: 1}, stats)
+
+			current := Encrypt(store, testKeyring(t, "k2", "k0", "k2"))
+			for _, hash := range []string{"a", "b", "c"} {
+				raw, err := store.Get(ctx, hash)
+				assert.NoError(t, err)
+				assert.Equal(t, "k2", raw.KeyID)
+
+				got, err := current.Get(ctx, hash)
+				assert.NoError(t, err)
+				assert.Equal(t, payload, got.Payload)
+			}
+
+			// Повторный запуск ничего не меняет
+			stats, err = Reencrypt(ctx, store, rotated, 100, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, ReencryptStats{}, stats)
+		})
+	}
+}
+
+/*
+Этот тест проверяет загрузку Keyring из файла и из строки переменной окружения.
+*/
+func TestLoadKeyring(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	err := os.WriteFile(path, []byte(`{"primary": "k1", "keys": {"k1": "`+key1+`", "k2": "`+key2+`"}}`), 0o600)
+	assert.NoError(t, err)
+
+	keyring, err := LoadKeyringFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", keyring.Primary)
+	assert.Equal(t, []string{"k1", "k2"}, keyring.KeyIDs())
+
+	keyring, err = ParseKeyring("k1:"+key1+", k2:"+key2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", keyring.Primary)
+
+	keyring, err = ParseKeyring("k1:"+key1+",k2:"+key2, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", keyring.Primary)
+
+	_, err = ParseKeyring("k1:"+key1, "k3")
+	assert.Error(t, err)
+	_, err = ParseKeyring("k1:"+base64.StdEncoding.EncodeToString([]byte("short")), "")
+	assert.Error(t, err)
+	_, err = ParseKeyring("k1", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+/*
+HashStore - интерфейс хранилища записей о хешах. HashingService работает только с этим интерфейсом,
+поэтому конкретное хранилище (Redis, память или файл на диске) выбирается конфигурацией, а в тестах
+можно использовать хранилище в памяти без запущенного Redis.
+*/
+
+// ErrNotFound возвращается, если записи с таким хешем нет в хранилище.
+var ErrNotFound = errors.New("record not found")
+
+// ErrInvalidCursor возвращается List, если курсор не был получен от этого хранилища.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrNoReference возвращается ReleaseReference, если у владельца нет ссылки на запись.
+var ErrNoReference = errors.New("owner holds no reference")
+
+// Record - запись, которая хранится по ключу-хешу.
+type Record struct {
+	Payload []byte
+	// Codec - кодек, которым сжат Payload в хранилище (см. Compress). Пустая строка - данные не сжаты.
+	Codec string
+	// KeyID - ключ шифрования, которым зашифрован DataKey, а DataKey - ключ данных, которым зашифрован
+	// Payload (см. Encrypt). Пустой KeyID - данные не зашифрованы.
+	KeyID       string
+	DataKey     []byte
+	ContentType string
+	Algorithm   string
+	// MACKey и MACKeyVersion - секретный ключ, с которым посчитан хеш (см. MACKeyStore). Пустой MACKey -
+	// хеш посчитан без ключа.
+	MACKey        string
+	MACKeyVersion int64
+	// Normalization - шаги нормализации текста через запятую, примененные к данным перед хешированием
+	// (см. HashingService.CreateHash). Пустая строка - данные хешировались как есть.
+	Normalization string
+	// SimHash - отпечаток SimHash данных для поиска похожих (см. FindSimilar). Ноль - отпечаток не посчитан.
+	SimHash   uint64
+	CreatedAt time.Time
+	// Size - размер исходных данных. Может быть больше len(Payload), если данные были слишком
+	// большими, чтобы сохранить их целиком (см. HashingService.CreateHashStream).
+	Size int64
+
+	// Статистика чтений, которую обновляет RecordAccess
+	LastAccessAt time.Time
+	ReadCount    int64
+
+	// ExpiresAt - время, после которого запись считается удаленной. Нулевое время - запись бессрочная.
+	ExpiresAt time.Time
+
+	// DeletedAt задан у надгробий (tombstone) - записей, которые остаются вместо удаленного хеша без
+	// исходных данных, чтобы хеш отличался от никогда не существовавшего.
+	DeletedAt time.Time
+
+	// Ссылки владельцев на запись: Owners - число ссылок каждого владельца, References - их сумма.
+	// ReleasedAt - время, когда была освобождена последняя ссылка. Записи, на которые никогда не брали
+	// ссылок, сборщиком мусора не удаляются.
+	Owners     map[string]int64
+	References int64
+	ReleasedAt time.Time
+}
+
+// PayloadStored сообщает, сохранены ли исходные данные целиком.
+func (r *Record) PayloadStored() bool {
+	return int64(len(r.Payload)) >= r.Size
+}
+
+// Deleted сообщает, является ли запись надгробием удаленного хеша.
+func (r *Record) Deleted() bool {
+	return !r.DeletedAt.IsZero()
+}
+
+// Unreferenced сообщает, что все ссылки на запись освобождены не позже releasedBefore.
+func (r *Record) Unreferenced(releasedBefore time.Time) bool {
+	return r.References <= 0 && !r.ReleasedAt.IsZero() && !r.ReleasedAt.After(releasedBefore)
+}
+
+// releaseReference освобождает одну ссылку владельца owner, у которого она есть.
+func releaseReference(record *Record, owner string, at time.Time) {
+	record.Owners[owner]--
+	if record.Owners[owner] <= 0 {
+		delete(record.Owners, owner)
+	}
+	record.References--
+	if record.References <= 0 {
+		record.ReleasedAt = at
+	}
+}
+
+// Expired сообщает, истек ли срок жизни записи к моменту now.
+func (r *Record) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+type HashStore interface {
+	// Save сохраняет запись по хешу, перезаписывая существующую.
+	Save(ctx context.Context, hash string, record *Record) error
+	// Create атомарно сохраняет запись, если хеша нет или от него осталось только надгробие, и возвращает
+	// true. Если запись уже есть, ничего не меняет и возвращает ее и false.
+	Create(ctx context.Context, hash string, record *Record) (*Record, bool, error)
+	// Get возвращает запись по хешу или ErrNotFound. Записи с истекшим сроком жизни не возвращаются.
+	Get(ctx context.Context, hash string) (*Record, error)
+	// RecordAccess атомарно увеличивает счетчик чтений записи и запоминает время чтения.
+	// Для отсутствующей записи возвращает ErrNotFound и ничего не создает.
+	RecordAccess(ctx context.Context, hash string, at time.Time) error
+	// SetExpiration меняет срок жизни существующей записи. Нулевое время делает запись бессрочной.
+	SetExpiration(ctx context.Context, hash string, expiresAt time.Time) error
+	// Delete удаляет запись по хешу. Для отсутствующей записи возвращает ErrNotFound.
+	Delete(ctx context.Context, hash string) error
+	// SetPayload атомарно заменяет данные записи и способ их хранения (Payload, Codec, KeyID и DataKey)
+	// значениями из record, не трогая остальные поля (см. Recompress и Reencrypt). Для отсутствующей
+	// записи или надгробия возвращает ErrNotFound.
+	SetPayload(ctx context.Context, hash string, record *Record) error
+	// AddReference атомарно добавляет ссылку владельца owner и возвращает общее число ссылок.
+	// Для отсутствующей записи или надгробия возвращает ErrNotFound.
+	AddReference(ctx context.Context, hash, owner string) (int64, error)
+	// ReleaseReference атомарно освобождает ссылку владельца owner и возвращает оставшееся число ссылок.
+	// Когда ссылок не остается, запоминает время at. Если у владельца нет ссылки, возвращает ErrNoReference.
+	ReleaseReference(ctx context.Context, hash, owner string, at time.Time) (int64, error)
+	// DeleteUnreferenced атомарно удаляет запись, если она Unreferenced(releasedBefore), и сообщает,
+	// была ли она удалена.
+	DeleteUnreferenced(ctx context.Context, hash string, releasedBefore time.Time) (bool, error)
+	// List возвращает страницу записей без исходных данных (Payload не заполняется).
+	List(ctx context.Context, query ListQuery) (*ListPage, error)
+	// FindByPrefix возвращает по индексу до limit хешей, начинающихся с prefix, в лексикографическом порядке.
+	// Надгробия и записи с истекшим сроком жизни не возвращаются.
+	FindByPrefix(ctx context.Context, prefix string, limit int) ([]string, error)
+	// FindSimilar возвращает по индексу отпечатков записи с префиксом prefix, отпечаток SimHash которых
+	// отличается от fingerprint не больше чем на maxDistance бит (не больше MaxSimilarDistance), в любом
+	// порядке. Payload не заполняется, надгробия и записи с истекшим сроком жизни не возвращаются.
+	// Пустой prefix - записи пространства по умолчанию, без записей других пространств (см. InNamespace).
+	FindSimilar(ctx context.Context, prefix string, fingerprint uint64, maxDistance int) ([]SimilarEntry, error)
+	// Count возвращает число записей с префиксом prefix без надгробий. Записи с истекшим сроком жизни
+	// могут учитываться, пока их не удалит очистка (см. ExpiredPurger).
+	Count(ctx context.Context, prefix string) (int64, error)
+	// Close освобождает ресурсы хранилища.
+	Close() error
+}
+
+/*
+ListQuery - параметры постраничного обхода записей. Cursor - строка из ListPage.NextCursor предыдущей
+страницы, ее формат зависит от хранилища. Записи с истекшим сроком жизни в обход не попадают.
+*/
+type ListQuery struct {
+	Cursor string
+	// Limit - размер страницы. Redis обходит ключи через SCAN и может вернуть немного больше записей.
+	// Ноль или отрицательное значение - без ограничения: все записи возвращаются одной страницей.
+	Limit  int
+	Prefix string
+	// Filter отбирает записи, которые попадут на страницу. nil - все записи.
+	Filter func(hash string, record *Record) bool
+}
+
+type ListEntry struct {
+	Hash   string
+	Record *Record
+}
+
+type ListPage struct {
+	Entries []ListEntry
+	// NextCursor - курсор следующей страницы. Пустая строка означает, что обход завершен.
+	NextCursor string
+}
+
+// limited сообщает, что страница из count записей уже заполнена и следующая запись на нее не попадает.
+func (q ListQuery) limited(count int) bool {
+	return q.Limit > 0 && count >= q.Limit
+}
+
+// matches сообщает, подходит ли запись под фильтр запроса.
+func (q ListQuery) matches(hash string, record *Record) bool {
+	return q.Filter == nil || q.Filter(hash, record)
+}
+
+/*
+ExpiredPurger реализуют хранилища, которым нужна периодическая очистка после истечения срока жизни записей.
+Такие записи уже не видны через HashStore, а PurgeExpired освобождает занятое ими место и убирает их
+из индекса.
+*/
+type ExpiredPurger interface {
+	PurgeExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+/*
+LegacyMigrator реализуют хранилища, в которых могут остаться записи первых версий сервиса в старом формате.
+MigrateLegacy переводит их в текущий формат и возвращает число переведенных записей.
+*/
+type LegacyMigrator interface {
+	MigrateLegacy(ctx context.Context) (int, error)
+}
+
+// Поддерживаемые значения переменной окружения STORAGE_BACKEND
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+const defaultBoltPath = "hashes.db"
+
+/*
+ConnectToStore создает хранилище, выбранное переменной окружения STORAGE_BACKEND (по умолчанию redis).
+Для хранилища bolt путь к файлу задается переменной BOLT_PATH.
+*/
+
+func ConnectToStore() (Store, error) {
+	loadEnv()
+
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", BackendRedis:
+		client, err := ConnectToRedis()
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(client), nil
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendBolt:
+		path := os.Getenv("BOLT_PATH")
+		if path == "" {
+			path = defaultBoltPath
+		}
+		return OpenBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+var loadEnvOnce sync.Once
+
+// loadEnv загружает переменные из .env файла. Если файла нет, используются переменные окружения процесса.
+func loadEnv() {
+	loadEnvOnce.Do(func() {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("no .env file loaded, using process environment: %v", err)
+		}
+	})
+}
@@ -23,11 +23,35 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type HashingClient interface {
 	// Checks if the payload's hash already exists
-	CheckHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error)
+	CheckHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*CheckHashResponse, error)
 	// Returns the hash for an existing payload
 	GetHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error)
 	// Creates and stores a hash for a new payload
 	CreateHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error)
+	// Creates and stores a hash for a payload sent as a stream of chunks
+	CreateHashStream(ctx context.Context, opts ...grpc.CallOption) (Hashing_CreateHashStreamClient, error)
+	// Returns metadata of a stored hash without its payload
+	GetHashMetadata(ctx context.Context, in *HashLookupRequest, opts ...grpc.CallOption) (*HashMetadata, error)
+	// Extends or changes the lifetime of a stored hash
+	TouchHash(ctx context.Context, in *TouchHashRequest, opts ...grpc.CallOption) (*HashMetadata, error)
+	// Deletes a stored hash and its payload, optionally leaving a tombstone
+	DeleteHash(ctx context.Context, in *DeleteHashRequest, opts ...grpc.CallOption) (*DeleteHashResponse, error)
+	// Returns a page of stored hashes with their metadata
+	ListHashes(ctx context.Context, in *ListHashesRequest, opts ...grpc.CallOption) (*ListHashesResponse, error)
+	// Releases a reference taken by CreateHash with an owner; hashes without references
+	// are garbage collected after a grace period
+	ReleaseHash(ctx context.Context, in *ReleaseHashRequest, opts ...grpc.CallOption) (*ReleaseHashResponse, error)
+	// Hashes a password with a slow salted algorithm (argon2id, bcrypt or scrypt) and returns
+	// a PHC string. Nothing is stored: the caller keeps the returned hash
+	HashPassword(ctx context.Context, in *HashPasswordRequest, opts ...grpc.CallOption) (*HashPasswordResponse, error)
+	// Verifies a password against a PHC string returned by HashPassword
+	VerifyPassword(ctx context.Context, in *VerifyPasswordRequest, opts ...grpc.CallOption) (*VerifyPasswordResponse, error)
+	// Recomputes the hash of a payload and compares it with an expected hash in constant time.
+	// Nothing is read from or written to storage
+	VerifyHash(ctx context.Context, in *VerifyHashRequest, opts ...grpc.CallOption) (*VerifyHashResponse, error)
+	// Finds stored hashes whose SimHash fingerprint is within a Hamming distance of the payload's
+	// fingerprint (near-duplicates). Only hashes created with simhash are indexed
+	FindSimilar(ctx context.Context, in *FindSimilarRequest, opts ...grpc.CallOption) (*FindSimilarResponse, error)
 }
 
 type hashingClient struct {
@@ -38,8 +62,8 @@ func NewHashingClient(cc grpc.ClientConnInterface) HashingClient {
 	return &hashingClient{cc}
 }
 
-func (c *hashingClient) CheckHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error) {
-	out := new(HashResponse)
+func (c *hashingClient) CheckHash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*CheckHashResponse, error) {
+	out := new(CheckHashResponse)
 	err := c.cc.Invoke(ctx, "/proto.Hashing/CheckHash", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -65,16 +89,155 @@ func (c *hashingClient) CreateHash(ctx context.Context, in *HashRequest, opts ..
 	return out, nil
 }
 
+func (c *hashingClient) CreateHashStream(ctx context.Context, opts ...grpc.CallOption) (Hashing_CreateHashStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Hashing_ServiceDesc.Streams[0], "/proto.Hashing/CreateHashStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hashingCreateHashStreamClient{stream}
+	return x, nil
+}
+
+type Hashing_CreateHashStreamClient interface {
+	Send(*HashChunk) error
+	CloseAndRecv() (*HashResponse, error)
+	grpc.ClientStream
+}
+
+type hashingCreateHashStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *hashingCreateHashStreamClient) Send(m *HashChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hashingCreateHashStreamClient) CloseAndRecv() (*HashResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(HashResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hashingClient) GetHashMetadata(ctx context.Context, in *HashLookupRequest, opts ...grpc.CallOption) (*HashMetadata, error) {
+	out := new(HashMetadata)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/GetHashMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) TouchHash(ctx context.Context, in *TouchHashRequest, opts ...grpc.CallOption) (*HashMetadata, error) {
+	out := new(HashMetadata)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/TouchHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) DeleteHash(ctx context.Context, in *DeleteHashRequest, opts ...grpc.CallOption) (*DeleteHashResponse, error) {
+	out := new(DeleteHashResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/DeleteHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) ListHashes(ctx context.Context, in *ListHashesRequest, opts ...grpc.CallOption) (*ListHashesResponse, error) {
+	out := new(ListHashesResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/ListHashes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) ReleaseHash(ctx context.Context, in *ReleaseHashRequest, opts ...grpc.CallOption) (*ReleaseHashResponse, error) {
+	out := new(ReleaseHashResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/ReleaseHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) HashPassword(ctx context.Context, in *HashPasswordRequest, opts ...grpc.CallOption) (*HashPasswordResponse, error) {
+	out := new(HashPasswordResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/HashPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) VerifyPassword(ctx context.Context, in *VerifyPasswordRequest, opts ...grpc.CallOption) (*VerifyPasswordResponse, error) {
+	out := new(VerifyPasswordResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/VerifyPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) VerifyHash(ctx context.Context, in *VerifyHashRequest, opts ...grpc.CallOption) (*VerifyHashResponse, error) {
+	out := new(VerifyHashResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/VerifyHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingClient) FindSimilar(ctx context.Context, in *FindSimilarRequest, opts ...grpc.CallOption) (*FindSimilarResponse, error) {
+	out := new(FindSimilarResponse)
+	err := c.cc.Invoke(ctx, "/proto.Hashing/FindSimilar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HashingServer is the server API for Hashing service.
 // All implementations must embed UnimplementedHashingServer
 // for forward compatibility
 type HashingServer interface {
 	// Checks if the payload's hash already exists
-	CheckHash(context.Context, *HashRequest) (*HashResponse, error)
+	CheckHash(context.Context, *HashRequest) (*CheckHashResponse, error)
 	// Returns the hash for an existing payload
 	GetHash(context.Context, *HashRequest) (*HashResponse, error)
 	// Creates and stores a hash for a new payload
 	CreateHash(context.Context, *HashRequest) (*HashResponse, error)
+	// Creates and stores a hash for a payload sent as a stream of chunks
+	CreateHashStream(Hashing_CreateHashStreamServer) error
+	// Returns metadata of a stored hash without its payload
+	GetHashMetadata(context.Context, *HashLookupRequest) (*HashMetadata, error)
+	// Extends or changes the lifetime of a stored hash
+	TouchHash(context.Context, *TouchHashRequest) (*HashMetadata, error)
+	// Deletes a stored hash and its payload, optionally leaving a tombstone
+	DeleteHash(context.Context, *DeleteHashRequest) (*DeleteHashResponse, error)
+	// Returns a page of stored hashes with their metadata
+	ListHashes(context.Context, *ListHashesRequest) (*ListHashesResponse, error)
+	// Releases a reference taken by CreateHash with an owner; hashes without references
+	// are garbage collected after a grace period
+	ReleaseHash(context.Context, *ReleaseHashRequest) (*ReleaseHashResponse, error)
+	// Hashes a password with a slow salted algorithm (argon2id, bcrypt or scrypt) and returns
+	// a PHC string. Nothing is stored: the caller keeps the returned hash
+	HashPassword(context.Context, *HashPasswordRequest) (*HashPasswordResponse, error)
+	// Verifies a password against a PHC string returned by HashPassword
+	VerifyPassword(context.Context, *VerifyPasswordRequest) (*VerifyPasswordResponse, error)
+	// Recomputes the hash of a payload and compares it with an expected hash in constant time.
+	// Nothing is read from or written to storage
+	VerifyHash(context.Context, *VerifyHashRequest) (*VerifyHashResponse, error)
+	// Finds stored hashes whose SimHash fingerprint is within a Hamming distance of the payload's
+	// fingerprint (near-duplicates). Only hashes created with simhash are indexed
+	FindSimilar(context.Context, *FindSimilarRequest) (*FindSimilarResponse, error)
 	mustEmbedUnimplementedHashingServer()
 }
 
@@ -82,7 +245,7 @@ type HashingServer interface {
 type UnimplementedHashingServer struct {
 }
 
-func (UnimplementedHashingServer) CheckHash(context.Context, *HashRequest) (*HashResponse, error) {
+func (UnimplementedHashingServer) CheckHash(context.Context, *HashRequest) (*CheckHashResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckHash not implemented")
 }
 func (UnimplementedHashingServer) GetHash(context.Context, *HashRequest) (*HashResponse, error) {
@@ -91,6 +254,36 @@ func (UnimplementedHashingServer) GetHash(context.Context, *HashRequest) (*HashR
 func (UnimplementedHashingServer) CreateHash(context.Context, *HashRequest) (*HashResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateHash not implemented")
 }
+func (UnimplementedHashingServer) CreateHashStream(Hashing_CreateHashStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateHashStream not implemented")
+}
+func (UnimplementedHashingServer) GetHashMetadata(context.Context, *HashLookupRequest) (*HashMetadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHashMetadata not implemented")
+}
+func (UnimplementedHashingServer) TouchHash(context.Context, *TouchHashRequest) (*HashMetadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TouchHash not implemented")
+}
+func (UnimplementedHashingServer) DeleteHash(context.Context, *DeleteHashRequest) (*DeleteHashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteHash not implemented")
+}
+func (UnimplementedHashingServer) ListHashes(context.Context, *ListHashesRequest) (*ListHashesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListHashes not implemented")
+}
+func (UnimplementedHashingServer) ReleaseHash(context.Context, *ReleaseHashRequest) (*ReleaseHashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseHash not implemented")
+}
+func (UnimplementedHashingServer) HashPassword(context.Context, *HashPasswordRequest) (*HashPasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HashPassword not implemented")
+}
+func (UnimplementedHashingServer) VerifyPassword(context.Context, *VerifyPasswordRequest) (*VerifyPasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyPassword not implemented")
+}
+func (UnimplementedHashingServer) VerifyHash(context.Context, *VerifyHashRequest) (*VerifyHashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyHash not implemented")
+}
+func (UnimplementedHashingServer) FindSimilar(context.Context, *FindSimilarRequest) (*FindSimilarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindSimilar not implemented")
+}
 func (UnimplementedHashingServer) mustEmbedUnimplementedHashingServer() {}
 
 // UnsafeHashingServer may be embedded to opt out of forward compatibility for this service.
@@ -158,6 +351,194 @@ func _Hashing_CreateHash_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Hashing_CreateHashStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HashingServer).CreateHashStream(&hashingCreateHashStreamServer{stream})
+}
+
+type Hashing_CreateHashStreamServer interface {
+	SendAndClose(*HashResponse) error
+	Recv() (*HashChunk, error)
+	grpc.ServerStream
+}
+
+type hashingCreateHashStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *hashingCreateHashStreamServer) SendAndClose(m *HashResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hashingCreateHashStreamServer) Recv() (*HashChunk, error) {
+	m := new(HashChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Hashing_GetHashMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HashLookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).GetHashMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/GetHashMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).GetHashMetadata(ctx, req.(*HashLookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_TouchHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TouchHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).TouchHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/TouchHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).TouchHash(ctx, req.(*TouchHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_DeleteHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).DeleteHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/DeleteHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).DeleteHash(ctx, req.(*DeleteHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_ListHashes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHashesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).ListHashes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/ListHashes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).ListHashes(ctx, req.(*ListHashesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_ReleaseHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).ReleaseHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/ReleaseHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).ReleaseHash(ctx, req.(*ReleaseHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_HashPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HashPasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).HashPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/HashPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).HashPassword(ctx, req.(*HashPasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_VerifyPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyPasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).VerifyPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/VerifyPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).VerifyPassword(ctx, req.(*VerifyPasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_VerifyHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).VerifyHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/VerifyHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).VerifyHash(ctx, req.(*VerifyHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hashing_FindSimilar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindSimilarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingServer).FindSimilar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Hashing/FindSimilar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingServer).FindSimilar(ctx, req.(*FindSimilarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Hashing_ServiceDesc is the grpc.ServiceDesc for Hashing service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -177,6 +558,402 @@ var Hashing_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateHash",
 			Handler:    _Hashing_CreateHash_Handler,
 		},
+		{
+			MethodName: "GetHashMetadata",
+			Handler:    _Hashing_GetHashMetadata_Handler,
+		},
+		{
+			MethodName: "TouchHash",
+			Handler:    _Hashing_TouchHash_Handler,
+		},
+		{
+			MethodName: "DeleteHash",
+			Handler:    _Hashing_DeleteHash_Handler,
+		},
+		{
+			MethodName: "ListHashes",
+			Handler:    _Hashing_ListHashes_Handler,
+		},
+		{
+			MethodName: "ReleaseHash",
+			Handler:    _Hashing_ReleaseHash_Handler,
+		},
+		{
+			MethodName: "HashPassword",
+			Handler:    _Hashing_HashPassword_Handler,
+		},
+		{
+			MethodName: "VerifyPassword",
+			Handler:    _Hashing_VerifyPassword_Handler,
+		},
+		{
+			MethodName: "VerifyHash",
+			Handler:    _Hashing_VerifyHash_Handler,
+		},
+		{
+			MethodName: "FindSimilar",
+			Handler:    _Hashing_FindSimilar_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateHashStream",
+			Handler:       _Hashing_CreateHashStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hashing.proto",
+}
+
+// HashingAdminClient is the client API for HashingAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HashingAdminClient interface {
+	// Creates a namespace with optional quotas
+	CreateNamespace(ctx context.Context, in *CreateNamespaceRequest, opts ...grpc.CallOption) (*Namespace, error)
+	// Returns a namespace with its usage statistics
+	GetNamespace(ctx context.Context, in *GetNamespaceRequest, opts ...grpc.CallOption) (*Namespace, error)
+	// Returns all namespaces without statistics
+	ListNamespaces(ctx context.Context, in *ListNamespacesRequest, opts ...grpc.CallOption) (*ListNamespacesResponse, error)
+	// Deletes a namespace together with all its hashes
+	DeleteNamespace(ctx context.Context, in *DeleteNamespaceRequest, opts ...grpc.CallOption) (*DeleteNamespaceResponse, error)
+	// Creates a named secret key for keyed hashing with a random first version
+	CreateKey(ctx context.Context, in *CreateKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	// Adds a new random version to a key; new hashes use it, older versions stay available
+	RotateKey(ctx context.Context, in *RotateKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	// Returns a key with its versions; secrets are never returned
+	GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	// Returns all keys
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+}
+
+type hashingAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHashingAdminClient(cc grpc.ClientConnInterface) HashingAdminClient {
+	return &hashingAdminClient{cc}
+}
+
+func (c *hashingAdminClient) CreateNamespace(ctx context.Context, in *CreateNamespaceRequest, opts ...grpc.CallOption) (*Namespace, error) {
+	out := new(Namespace)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/CreateNamespace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) GetNamespace(ctx context.Context, in *GetNamespaceRequest, opts ...grpc.CallOption) (*Namespace, error) {
+	out := new(Namespace)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/GetNamespace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) ListNamespaces(ctx context.Context, in *ListNamespacesRequest, opts ...grpc.CallOption) (*ListNamespacesResponse, error) {
+	out := new(ListNamespacesResponse)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/ListNamespaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) DeleteNamespace(ctx context.Context, in *DeleteNamespaceRequest, opts ...grpc.CallOption) (*DeleteNamespaceResponse, error) {
+	out := new(DeleteNamespaceResponse)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/DeleteNamespace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) CreateKey(ctx context.Context, in *CreateKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/CreateKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) RotateKey(ctx context.Context, in *RotateKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/RotateKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/GetKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hashingAdminClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, "/proto.HashingAdmin/ListKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HashingAdminServer is the server API for HashingAdmin service.
+// All implementations must embed UnimplementedHashingAdminServer
+// for forward compatibility
+type HashingAdminServer interface {
+	// Creates a namespace with optional quotas
+	CreateNamespace(context.Context, *CreateNamespaceRequest) (*Namespace, error)
+	// Returns a namespace with its usage statistics
+	GetNamespace(context.Context, *GetNamespaceRequest) (*Namespace, error)
+	// Returns all namespaces without statistics
+	ListNamespaces(context.Context, *ListNamespacesRequest) (*ListNamespacesResponse, error)
+	// Deletes a namespace together with all its hashes
+	DeleteNamespace(context.Context, *DeleteNamespaceRequest) (*DeleteNamespaceResponse, error)
+	// Creates a named secret key for keyed hashing with a random first version
+	CreateKey(context.Context, *CreateKeyRequest) (*Key, error)
+	// Adds a new random version to a key; new hashes use it, older versions stay available
+	RotateKey(context.Context, *RotateKeyRequest) (*Key, error)
+	// Returns a key with its versions; secrets are never returned
+	GetKey(context.Context, *GetKeyRequest) (*Key, error)
+	// Returns all keys
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	mustEmbedUnimplementedHashingAdminServer()
+}
+
+// UnimplementedHashingAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedHashingAdminServer struct {
+}
+
+func (UnimplementedHashingAdminServer) CreateNamespace(context.Context, *CreateNamespaceRequest) (*Namespace, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateNamespace not implemented")
+}
+func (UnimplementedHashingAdminServer) GetNamespace(context.Context, *GetNamespaceRequest) (*Namespace, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNamespace not implemented")
+}
+func (UnimplementedHashingAdminServer) ListNamespaces(context.Context, *ListNamespacesRequest) (*ListNamespacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNamespaces not implemented")
+}
+func (UnimplementedHashingAdminServer) DeleteNamespace(context.Context, *DeleteNamespaceRequest) (*DeleteNamespaceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteNamespace not implemented")
+}
+func (UnimplementedHashingAdminServer) CreateKey(context.Context, *CreateKeyRequest) (*Key, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateKey not implemented")
+}
+func (UnimplementedHashingAdminServer) RotateKey(context.Context, *RotateKeyRequest) (*Key, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateKey not implemented")
+}
+func (UnimplementedHashingAdminServer) GetKey(context.Context, *GetKeyRequest) (*Key, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKey not implemented")
+}
+func (UnimplementedHashingAdminServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedHashingAdminServer) mustEmbedUnimplementedHashingAdminServer() {}
+
+// UnsafeHashingAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HashingAdminServer will
+// result in compilation errors.
+type UnsafeHashingAdminServer interface {
+	mustEmbedUnimplementedHashingAdminServer()
+}
+
+func RegisterHashingAdminServer(s grpc.ServiceRegistrar, srv HashingAdminServer) {
+	s.RegisterService(&HashingAdmin_ServiceDesc, srv)
+}
+
+func _HashingAdmin_CreateNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateNamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).CreateNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/CreateNamespace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).CreateNamespace(ctx, req.(*CreateNamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_GetNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).GetNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/GetNamespace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).GetNamespace(ctx, req.(*GetNamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_ListNamespaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNamespacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).ListNamespaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/ListNamespaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).ListNamespaces(ctx, req.(*ListNamespacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_DeleteNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteNamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).DeleteNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/DeleteNamespace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).DeleteNamespace(ctx, req.(*DeleteNamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_CreateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).CreateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/CreateKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).CreateKey(ctx, req.(*CreateKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_RotateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).RotateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/RotateKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).RotateKey(ctx, req.(*RotateKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).GetKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/GetKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).GetKey(ctx, req.(*GetKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HashingAdmin_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HashingAdminServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.HashingAdmin/ListKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HashingAdminServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HashingAdmin_ServiceDesc is the grpc.ServiceDesc for HashingAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HashingAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.HashingAdmin",
+	HandlerType: (*HashingAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateNamespace",
+			Handler:    _HashingAdmin_CreateNamespace_Handler,
+		},
+		{
+			MethodName: "GetNamespace",
+			Handler:    _HashingAdmin_GetNamespace_Handler,
+		},
+		{
+			MethodName: "ListNamespaces",
+			Handler:    _HashingAdmin_ListNamespaces_Handler,
+		},
+		{
+			MethodName: "DeleteNamespace",
+			Handler:    _HashingAdmin_DeleteNamespace_Handler,
+		},
+		{
+			MethodName: "CreateKey",
+			Handler:    _HashingAdmin_CreateKey_Handler,
+		},
+		{
+			MethodName: "RotateKey",
+			Handler:    _HashingAdmin_RotateKey_Handler,
+		},
+		{
+			MethodName: "GetKey",
+			Handler:    _HashingAdmin_GetKey_Handler,
+		},
+		{
+			MethodName: "ListKeys",
+			Handler:    _HashingAdmin_ListKeys_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "hashing.proto",